@@ -0,0 +1,225 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Analysis of variance tables, of the three usual types:
+//
+// Type I, or sequential: each term is added in turn, in the order given,
+// and its sum of squares is the reduction in the residual sum of squares
+// from adding it to the terms before it. This is R's anova(fit), and
+// these sums of squares are exactly the differences in m.Rss when the
+// variables are in that order in m.Vorder.
+//
+// Type II: each term is added last to the model of every other term
+// that does not contain it, as in car::Anova(fit, type=2). Which terms
+// contain which is given by Term.Factors; without it, no term contains
+// another, and Type II is the same as Type III.
+//
+// Type III: each term is added last to the model of every other term,
+// as in drop1(). The intercept is not tested.
+//
+// In every type, a term of several columns, such as the dummies for
+// one factor, is tested as a group, and the F statistics are all
+// against the residual mean square of the model of every term.
+
+type AnovaType int
+
+const (
+	ANOVA_TYPE_I   AnovaType = 1
+	ANOVA_TYPE_II  AnovaType = 2
+	ANOVA_TYPE_III AnovaType = 3
+)
+
+// AnovaRow is one term's line of an AnovaTable.
+type AnovaRow struct {
+	Term   Term
+	Df     float64
+	SumSq  float64
+	MeanSq float64
+	F      float64
+	Pvalue float64
+}
+
+// AnovaTable is returned by Anova().
+type AnovaTable struct {
+	Type  AnovaType
+	Wycol int
+	Rows  []*AnovaRow
+
+	// the residual line, for the model of every term
+	DfResid     float64
+	Rss         float64
+	MeanSqResid float64
+}
+
+// Anova(): the analysis of variance table of type typ for the wycol-th
+// y-target on the model made of terms, which must not share variables.
+// With terms nil, each x-variable is a term by itself, in order.
+// m.Vorder is restored before returning.
+func (m *MillerLSQ) Anova(wycol int, terms []Term, typ AnovaType) (res *AnovaTable, err error) {
+	if terms == nil {
+		terms = SingleTerms(Seq(m.Nxvar))
+	}
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("Anova() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	if typ < ANOVA_TYPE_I || typ > ANOVA_TYPE_III {
+		return nil, errors.New(fmt.Sprintf("Anova() error: unknown type %v", typ))
+	}
+	all := []int{}
+	for _, t := range terms {
+		if len(t.Vars) == 0 {
+			return nil, errors.New(fmt.Sprintf("Anova() error: term %s has no variables", t.Name))
+		}
+		all = append(all, t.Vars...)
+	}
+	err = m.checkSubset(all)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := m.startShuffle()
+	defer func() {
+		rerr := m.restoreOrder(saved)
+		if err == nil && rerr != nil {
+			res, err = nil, rerr
+		}
+	}()
+
+	c := m.nconst()
+	err = m.moveSubsetToFront(all)
+	if err != nil {
+		return nil, err
+	}
+	res = &AnovaTable{
+		Type:    typ,
+		Wycol:   wycol,
+		Rss:     m.rssFirst(wycol, c+len(all)),
+		DfResid: m.ResidualDf(c + len(all)),
+	}
+	res.MeanSqResid = res.Rss / res.DfResid
+
+	// sumSq(): the reduction in the residual sum of squares from adding
+	// term to the model of the variables in base.
+	sumSq := func(base []int, term Term) (float64, error) {
+		err := m.moveSubsetToFront(base)
+		if err != nil {
+			return 0, err
+		}
+		err = m.Reorder(term.Vars, c+len(base))
+		if err != nil {
+			return 0, err
+		}
+		return m.rssFirst(wycol, c+len(base)) - m.rssFirst(wycol, c+len(base)+len(term.Vars)), nil
+	}
+
+	bases := anovaBases(terms, all, typ)
+	for i, t := range terms {
+		ss, err := sumSq(bases[i], t)
+		if err != nil {
+			return nil, err
+		}
+		row := &AnovaRow{
+			Term:  t,
+			Df:    float64(len(t.Vars)),
+			SumSq: ss,
+		}
+		row.MeanSq = row.SumSq / row.Df
+		row.F, row.Pvalue = fStat(row.SumSq, row.Df, res.Rss, res.DfResid)
+		res.Rows = append(res.Rows, row)
+	}
+	return res, nil
+}
+
+// anovaBases(): for each of terms, the variables of the model it is
+// added to in an analysis of type typ; all is every variable of terms.
+func anovaBases(terms []Term, all []int, typ AnovaType) [][]int {
+	bases := make([][]int, len(terms))
+	before := []int{}
+	for i, t := range terms {
+		switch typ {
+		case ANOVA_TYPE_I:
+			bases[i] = DeepCopyInts(before)
+			before = append(before, t.Vars...)
+		case ANOVA_TYPE_II:
+			for j, u := range terms {
+				if j != i && !u.contains(t) {
+					bases[i] = append(bases[i], u.Vars...)
+				}
+			}
+		case ANOVA_TYPE_III:
+			bases[i] = without(all, t.Vars)
+		}
+	}
+	return bases
+}
+
+// Anova(): as MillerLSQ.Anova(), with each variable named from
+// mod.Xnames when terms is nil.
+func (mod *Model) Anova(wycol int, terms []Term, typ AnovaType) (*AnovaTable, error) {
+	if terms == nil {
+		terms = mod.SingleTerms(Seq(mod.Nxvar))
+	}
+	return mod.MillerLSQ.Anova(wycol, terms, typ)
+}
+
+// factors(): the factors making up the term; just its name if
+// Factors is empty.
+func (t *Term) factors() []string {
+	if len(t.Factors) == 0 {
+		return []string{t.Name}
+	}
+	return t.Factors
+}
+
+// contains(): true if t is an interaction containing u, that is, if the
+// factors of u are a proper subset of the factors of t.
+func (t *Term) contains(u Term) bool {
+	tf := t.factors()
+	uf := u.factors()
+	if len(uf) >= len(tf) {
+		return false
+	}
+	in := make(map[string]bool, len(tf))
+	for _, f := range tf {
+		in[f] = true
+	}
+	for _, f := range uf {
+		if !in[f] {
+			return false
+		}
+	}
+	return true
+}
+
+func (a AnovaType) String() string {
+	switch a {
+	case ANOVA_TYPE_I:
+		return "Type I (sequential)"
+	case ANOVA_TYPE_II:
+		return "Type II"
+	case ANOVA_TYPE_III:
+		return "Type III"
+	}
+	return fmt.Sprintf("AnovaType(%d)", int(a))
+}
+
+func (r *AnovaTable) String() string {
+	names := []string{""}
+	for _, row := range r.Rows {
+		names = append(names, row.Term.Name)
+	}
+	names = append(names, "Residuals")
+	names = NormalizeNameLengths(names)
+
+	s := fmt.Sprintf("Analysis of Variance Table, %s, for y-target %d\n", r.Type, r.Wycol)
+	s += fmt.Sprintf("%s  %6s  %14s  %14s  %10s  %10s\n", names[0], "Df", "Sum Sq", "Mean Sq", "F value", "Pr(>F)")
+	for i, row := range r.Rows {
+		s += fmt.Sprintf("%s  %6.0f  %14.6g  %14.6g  %10.4g  %10.4g\n", names[i+1], row.Df, row.SumSq, row.MeanSq, row.F, row.Pvalue)
+	}
+	s += fmt.Sprintf("%s  %6.0f  %14.6g  %14.6g\n", names[len(names)-1], r.DfResid, r.Rss, r.MeanSqResid)
+	return s
+}
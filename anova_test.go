@@ -0,0 +1,136 @@
+package lsq
+
+import (
+	"fmt"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestAnova(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+
+	cv.Convey("Given the fuelcons.dat data with every x-variable", t, func() {
+		xnames := df.Colnames[1:last]
+		mod := NewModel(xnames, df.Colnames[last:])
+		for i := range df.Rows {
+			mod.Includ(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		m := mod.MillerLSQ
+		origVorder := DeepCopyInts(m.Vorder)
+
+		cv.Convey("the Type I sums of squares should add up, with the residual, to the total sum of squares, in any order", func() {
+			terms := mod.SingleTerms([]int{7, 4, 2, 1, 3, 5, 6})
+			tab, err := m.Anova(0, terms, ANOVA_TYPE_I)
+			cv.So(err, cv.ShouldBeNil)
+			fmt.Printf("\n%s\n", tab)
+
+			total := tab.Rss
+			prev := m.NullRss(0)
+			var vars []int
+			for i, row := range tab.Rows {
+				cv.So(row.Term.Name, cv.ShouldEqual, terms[i].Name)
+				total += row.SumSq
+
+				vars = append(vars, row.Term.Vars...)
+				fit, err := m.FitSubset(vars, 0)
+				cv.So(err, cv.ShouldBeNil)
+				cv.So(row.SumSq, cv.ShouldAlmostEqual, prev-fit.Rss, 1e-6)
+				cv.So(row.F, cv.ShouldAlmostEqual, row.MeanSq/tab.MeanSqResid, 1e-8)
+				prev = fit.Rss
+			}
+			cv.So(total, cv.ShouldAlmostEqual, m.NullRss(0), 1e-6)
+			cv.So(IntSliceEqual(m.Vorder, origVorder), cv.ShouldBeTrue)
+		})
+
+		cv.Convey("the Type III table should agree with Drop1(), and, with no interactions, with Type II", func() {
+			t3, err := mod.Anova(0, nil, ANOVA_TYPE_III)
+			cv.So(err, cv.ShouldBeNil)
+			t2, err := mod.Anova(0, nil, ANOVA_TYPE_II)
+			cv.So(err, cv.ShouldBeNil)
+			d1, err := mod.Drop1(0, nil, nil)
+			cv.So(err, cv.ShouldBeNil)
+			for i := range t3.Rows {
+				cv.So(t3.Rows[i].Term.Name, cv.ShouldEqual, xnames[i])
+				cv.So(t3.Rows[i].SumSq, cv.ShouldAlmostEqual, d1.Rows[i].SumSq, 1e-6)
+				cv.So(t3.Rows[i].Pvalue, cv.ShouldAlmostEqual, d1.Rows[i].Pvalue, 1e-10)
+				cv.So(t2.Rows[i].SumSq, cv.ShouldAlmostEqual, t3.Rows[i].SumSq, 1e-6)
+			}
+			cv.So(IntSliceEqual(m.Vorder, origVorder), cv.ShouldBeTrue)
+		})
+
+		cv.Convey("terms sharing a variable should be an error", func() {
+			_, err := m.Anova(0, []Term{{Name: "a", Vars: []int{1, 2}}, {Name: "b", Vars: []int{2}}}, ANOVA_TYPE_I)
+			cv.So(err, cv.ShouldNotBeNil)
+		})
+	})
+
+	cv.Convey("Given Fuel_Pop ~ Income * TaxBand, with TaxBand a factor of three levels coded by two dummies", t, func() {
+		// x1 Income, x2-x3 the TaxBand dummies, x4-x5 the interaction
+		tax, income := 2, 4
+		m := NewMillerLSQ(5, 1)
+		for i := range df.Rows {
+			inc := df.Rows[i][income]
+			d1, d2 := 0.0, 0.0
+			switch {
+			case df.Rows[i][tax] < 7.5:
+				d1 = 1
+			case df.Rows[i][tax] >= 8.5:
+				d2 = 1
+			}
+			m.Includ(1.0, []float64{inc, d1, d2, inc * d1, inc * d2}, df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		terms := []Term{
+			{Name: "Income", Vars: []int{1}},
+			{Name: "TaxBand", Vars: []int{2, 3}},
+			{Name: "Income:TaxBand", Vars: []int{4, 5}, Factors: []string{"Income", "TaxBand"}},
+		}
+		rssOf := func(vars ...int) float64 {
+			fit, err := m.FitSubset(vars, 0)
+			if err != nil {
+				panic(err)
+			}
+			return fit.Rss
+		}
+
+		cv.Convey("each type should test the grouped terms together, adjusting for the right terms", func() {
+			t1, err := m.Anova(0, terms, ANOVA_TYPE_I)
+			cv.So(err, cv.ShouldBeNil)
+			t2, err := m.Anova(0, terms, ANOVA_TYPE_II)
+			cv.So(err, cv.ShouldBeNil)
+			t3, err := m.Anova(0, terms, ANOVA_TYPE_III)
+			cv.So(err, cv.ShouldBeNil)
+			fmt.Printf("\n%s\n%s\n%s\n", t1, t2, t3)
+
+			for _, tab := range []*AnovaTable{t1, t2, t3} {
+				cv.So(tab.Rows[1].Df, cv.ShouldEqual, 2)
+				cv.So(tab.Rows[2].Df, cv.ShouldEqual, 2)
+				cv.So(tab.DfResid, cv.ShouldEqual, float64(len(df.Rows)-6))
+				cv.So(tab.Rss, cv.ShouldAlmostEqual, rssOf(1, 2, 3, 4, 5), 1e-6)
+			}
+
+			cv.So(t1.Rows[0].SumSq, cv.ShouldAlmostEqual, m.NullRss(0)-rssOf(1), 1e-6)
+			cv.So(t1.Rows[1].SumSq, cv.ShouldAlmostEqual, rssOf(1)-rssOf(1, 2, 3), 1e-6)
+
+			// Income and TaxBand are each adjusted for the other, but not the interaction
+			cv.So(t2.Rows[0].SumSq, cv.ShouldAlmostEqual, rssOf(2, 3)-rssOf(1, 2, 3), 1e-6)
+			cv.So(t2.Rows[1].SumSq, cv.ShouldAlmostEqual, rssOf(1)-rssOf(1, 2, 3), 1e-6)
+			cv.So(t2.Rows[2].SumSq, cv.ShouldAlmostEqual, rssOf(1, 2, 3)-rssOf(1, 2, 3, 4, 5), 1e-6)
+
+			cv.So(t3.Rows[0].SumSq, cv.ShouldAlmostEqual, rssOf(2, 3, 4, 5)-t3.Rss, 1e-6)
+			cv.So(t3.Rows[1].SumSq, cv.ShouldAlmostEqual, rssOf(1, 4, 5)-t3.Rss, 1e-6)
+			ft, err := m.NestedFTest([]int{1, 4, 5}, []int{1, 2, 3, 4, 5}, 0)
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(t3.Rows[1].F, cv.ShouldAlmostEqual, ft.F, 1e-8)
+			cv.So(t3.Rows[1].Pvalue, cv.ShouldAlmostEqual, ft.Pvalue, 1e-10)
+
+			// the last term is tested the same way by every type
+			cv.So(t1.Rows[2].SumSq, cv.ShouldAlmostEqual, t3.Rows[2].SumSq, 1e-6)
+		})
+	})
+}
@@ -0,0 +1,297 @@
+package lsq
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+)
+
+// Cluster-robust covariance matrices.
+//
+// When the rows come in groups whose errors are correlated, such as many
+// rows per user, the sandwich of RobustFit() needs its meat summed over
+// the groups, or clusters, rather than over the rows:
+//
+//	M = sum over clusters g of  s_g s_g',   s_g = sum over rows of g of  w e x
+//
+// and the covariance is c B M B, with the small-sample correction
+// c = G/(G-1) * (N-1)/(N-K) for G clusters, N observations and K
+// coefficients, as Stata and R's sandwich::vcovCL() use by default.
+//
+// Two-way clustering, say by user and by day, follows Cameron, Gelbach
+// and Miller (2011): V = V1 + V2 - V12, where V12 clusters on the pairs
+// (user, day), each with its own correction. V need not be positive
+// definite then; when it isn't, the F test of the result is NaN.
+//
+// Only the score sums s_g are kept, one vector of K per cluster, over a
+// second pass through the rows. If there are too many clusters for that,
+// set ClusterOptions.MaxClusters, and the sums are spilled to files,
+// partitioned by cluster, and finished one partition at a time.
+
+// ClusterOptions controls ClusterFit().
+type ClusterOptions struct {
+	By int // the column of the rows holding the cluster id
+
+	// TwoWay clusters on column By2 as well. The zero value clusters
+	// one way, on By alone, and By2 is then ignored.
+	TwoWay bool
+	By2    int
+
+	// MaxClusters, if > 0, is the number of clusters whose score sums
+	// are held in memory; beyond that they are spilled to files in
+	// SpillDir (by default, the system's temporary directory), in
+	// SpillBuckets partitions (default 64). Each partition must then fit
+	// in memory on its own.
+	MaxClusters  int
+	SpillDir     string
+	SpillBuckets int
+}
+
+// ClusterFit(): Fit() for the wycol-th y-target, but with the
+// cluster-robust covariance matrix, clustered on the ids in column
+// opt.By of the rows of src (and column opt.By2, with opt.TwoWay),
+// and the standard errors, t-values, p-values and Wald F
+// test that follow from it. src and cols are as for RobustFit().
+// The p-values use the residual degrees of freedom, as Fit() does.
+func (m *MillerLSQ) ClusterFit(wycol int, src RowSource, cols Columns, opt ClusterOptions) (res *FitResult, err error) {
+	twoway := opt.TwoWay
+	if opt.By < 0 || (twoway && (opt.By2 < 0 || opt.By2 == opt.By)) {
+		return nil, errors.New(fmt.Sprintf("ClusterFit() error: bad cluster columns %d and %d", opt.By, opt.By2))
+	}
+	if opt.SpillBuckets <= 0 {
+		opt.SpillBuckets = 64
+	}
+
+	fit, bread, err := m.robustStart(wycol)
+	if err != nil {
+		return nil, err
+	}
+	p := len(fit.Coef)
+
+	sums := []*scoreSums{newScoreSums(p, opt)}
+	if twoway {
+		sums = append(sums, newScoreSums(p, opt), newScoreSums(p, opt))
+	}
+	defer func() {
+		for _, s := range sums {
+			s.cleanup()
+		}
+	}()
+
+	xfull := make([]float64, p)
+	ynorm := make([]float64, m.Nyvar)
+	err = m.eachRow(src, cols, func(i int64, weight float64, x []float64, y []float64, row []float64) error {
+		if weight < 0 {
+			return errors.New("ClusterFit() error: rows with negative weights, downdating others, cannot be used")
+		}
+		if opt.By >= len(row) || (twoway && opt.By2 >= len(row)) {
+			return errors.New(fmt.Sprintf("ClusterFit() error: cluster columns %d and %d out of range for a row of %d columns", opt.By, opt.By2, len(row)))
+		}
+		k1 := row[opt.By]
+		k2 := 0.0
+		if twoway {
+			k2 = row[opt.By2]
+		}
+		if math.IsNaN(k1) || math.IsNaN(k2) {
+			return errors.New("ClusterFit() error: a cluster id was NaN")
+		}
+
+		m.normalize(x, y, xfull, ynorm)
+		e := ynorm[wycol]
+		for i, b := range fit.Coef {
+			e -= b * xfull[i]
+		}
+		we := weight * e
+		err := sums[0].add(clusterKey{k1, 0}, we, xfull)
+		if err != nil || !twoway {
+			return err
+		}
+		err = sums[1].add(clusterKey{k2, 0}, we, xfull)
+		if err != nil {
+			return err
+		}
+		return sums[2].add(clusterKey{k1, k2}, we, xfull)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	n := m.NobsEffective()
+	v := NewSquareMatrix(p)
+	var g []int
+	for i, s := range sums {
+		meat, nclus, err := s.meat()
+		if err != nil {
+			return nil, err
+		}
+		g = append(g, nclus)
+		if nclus < 2 {
+			return nil, errors.New(fmt.Sprintf("ClusterFit() error: only %d cluster(s)", nclus))
+		}
+		vi := sandwich(bread, meat)
+		c := float64(nclus) / float64(nclus-1) * (n - 1) / (n - float64(p))
+		if i == 2 {
+			// the intersection is subtracted
+			c = -c
+		}
+		for j := range v.A {
+			v.A[j] += c * vi.A[j]
+		}
+	}
+
+	covType := fmt.Sprintf("cluster-robust, %d clusters", g[0])
+	if twoway {
+		covType = fmt.Sprintf("two-way cluster-robust, %d and %d clusters", g[0], g[1])
+	}
+	return fit.withCov(v, covType)
+}
+
+// ClusterFit(): as MillerLSQ.ClusterFit(), with the names of the Model.
+func (mod *Model) ClusterFit(wycol int, src RowSource, cols Columns, opt ClusterOptions) (*FitResult, error) {
+	r, err := mod.MillerLSQ.ClusterFit(wycol, src, cols, opt)
+	if err != nil {
+		return nil, err
+	}
+	mod.nameResult(r, wycol)
+	return r, nil
+}
+
+type clusterKey struct {
+	a, b float64
+}
+
+// scoreSums accumulates the score sum of each cluster, spilling them to
+// partition files when there are too many to hold.
+type scoreSums struct {
+	p     int
+	opt   ClusterOptions
+	sums  map[clusterKey][]float64
+	files []*os.File
+	bufs  []*bufio.Writer
+}
+
+func newScoreSums(p int, opt ClusterOptions) *scoreSums {
+	return &scoreSums{p: p, opt: opt, sums: make(map[clusterKey][]float64)}
+}
+
+// add(): s_k += we * x
+func (s *scoreSums) add(k clusterKey, we float64, x []float64) error {
+	sum, ok := s.sums[k]
+	if !ok {
+		if s.opt.MaxClusters > 0 && len(s.sums) >= s.opt.MaxClusters {
+			err := s.spill()
+			if err != nil {
+				return err
+			}
+		}
+		sum = make([]float64, s.p)
+		s.sums[k] = sum
+	}
+	for i := range sum {
+		sum[i] += we * x[i]
+	}
+	return nil
+}
+
+// spill(): write every sum in memory to its partition file, and forget it.
+// A cluster may be spilled more than once; its pieces are added back
+// together by meat().
+func (s *scoreSums) spill() error {
+	if s.files == nil {
+		for i := 0; i < s.opt.SpillBuckets; i++ {
+			f, err := ioutil.TempFile(s.opt.SpillDir, "lsq-cluster-")
+			if err != nil {
+				return err
+			}
+			s.files = append(s.files, f)
+			s.bufs = append(s.bufs, bufio.NewWriter(f))
+		}
+	}
+	rec := make([]float64, 2+s.p)
+	for k, sum := range s.sums {
+		rec[0], rec[1] = k.a, k.b
+		copy(rec[2:], sum)
+		err := binary.Write(s.bufs[s.bucket(k)], binary.LittleEndian, rec)
+		if err != nil {
+			return err
+		}
+	}
+	s.sums = make(map[clusterKey][]float64)
+	return nil
+}
+
+// bucket(): the partition of k. Adding 0 makes -0, which is == 0 as a
+// map key, hash as 0 too.
+func (s *scoreSums) bucket(k clusterKey) int {
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, [2]float64{k.a + 0, k.b + 0})
+	return int(h.Sum64() % uint64(len(s.files)))
+}
+
+// meat(): the sum of s_g s_g' over the clusters, and their number.
+func (s *scoreSums) meat() (meat *SquareMatrix, nclus int, err error) {
+	meat = NewSquareMatrix(s.p)
+	if s.files == nil {
+		for _, sum := range s.sums {
+			addOuter(meat, sum, 1)
+		}
+		return meat, len(s.sums), nil
+	}
+
+	err = s.spill()
+	if err != nil {
+		return nil, 0, err
+	}
+	rec := make([]float64, 2+s.p)
+	for i, f := range s.files {
+		err = s.bufs[i].Flush()
+		if err != nil {
+			return nil, 0, err
+		}
+		_, err = f.Seek(0, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		r := bufio.NewReader(f)
+		part := make(map[clusterKey][]float64)
+		for {
+			err = binary.Read(r, binary.LittleEndian, rec)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, 0, err
+			}
+			k := clusterKey{rec[0], rec[1]}
+			sum, ok := part[k]
+			if !ok {
+				sum = make([]float64, s.p)
+				part[k] = sum
+			}
+			for j := range sum {
+				sum[j] += rec[2+j]
+			}
+		}
+		for _, sum := range part {
+			addOuter(meat, sum, 1)
+		}
+		nclus += len(part)
+	}
+	return meat, nclus, nil
+}
+
+// cleanup(): remove the spill files, if any.
+func (s *scoreSums) cleanup() {
+	for _, f := range s.files {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	s.files = nil
+	s.bufs = nil
+}
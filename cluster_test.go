@@ -0,0 +1,167 @@
+package lsq
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestClusterFit(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+
+	// Fuel_Pop ~ Income + RoadMls + DLic, clustered by Tax, and by a made up
+	// region, appended to each row
+	xcols := []int{4, 5, 7}
+	p := len(xcols) + 1
+	n := len(df.Rows)
+	rows := make([][]float64, n)
+	for i := range df.Rows {
+		rows[i] = append(append([]float64{}, df.Rows[i]...), float64(i%5))
+	}
+	byTax, byRegion := 2, df.Ncol
+
+	// the one-way cluster-robust covariance, computed directly from the data,
+	// on the cluster ids given by key
+	direct := func(key func(row []float64) string) [][]float64 {
+		x := make([][]float64, n)
+		xtx := make([][]float64, p)
+		xty := make([]float64, p)
+		for a := range xtx {
+			xtx[a] = make([]float64, p)
+		}
+		for i, row := range rows {
+			x[i] = []float64{1}
+			for _, j := range xcols {
+				x[i] = append(x[i], row[j])
+			}
+			for a := 0; a < p; a++ {
+				xty[a] += x[i][a] * row[last]
+				for b := 0; b < p; b++ {
+					xtx[a][b] += x[i][a] * x[i][b]
+				}
+			}
+		}
+		bread := invert(xtx)
+		beta := make([]float64, p)
+		for a := 0; a < p; a++ {
+			for b := 0; b < p; b++ {
+				beta[a] += bread[a][b] * xty[b]
+			}
+		}
+		scores := make(map[string][]float64)
+		for i, row := range rows {
+			e := row[last]
+			for a := 0; a < p; a++ {
+				e -= beta[a] * x[i][a]
+			}
+			k := key(row)
+			if scores[k] == nil {
+				scores[k] = make([]float64, p)
+			}
+			for a := 0; a < p; a++ {
+				scores[k][a] += e * x[i][a]
+			}
+		}
+		g := float64(len(scores))
+		c := g / (g - 1) * float64(n-1) / float64(n-p)
+		v := make([][]float64, p)
+		for a := range v {
+			v[a] = make([]float64, p)
+			for b := 0; b < p; b++ {
+				for _, s := range scores {
+					for k := 0; k < p; k++ {
+						for l := 0; l < p; l++ {
+							v[a][b] += c * bread[a][k] * s[k] * s[l] * bread[l][b]
+						}
+					}
+				}
+			}
+		}
+		return v
+	}
+	tax := func(row []float64) string { return fmt.Sprint(row[byTax]) }
+	region := func(row []float64) string { return fmt.Sprint(row[byRegion]) }
+	both := func(row []float64) string { return tax(row) + "/" + region(row) }
+
+	mod := NewModel([]string{"Income", "RoadMls", "DLic"}, []string{"Fuel_Pop"})
+	xrow := make([]float64, len(xcols))
+	for _, row := range rows {
+		for k, j := range xcols {
+			xrow[k] = row[j]
+		}
+		mod.Includ(1, xrow, row[last:last+1], NAN_OMIT_ROW)
+	}
+	src := NewSliceSource(rows)
+	cols := Columns{X: xcols, Y: []int{last}, Weight: NoWeight}
+
+	cv.Convey("Given the fuelcons.dat data, ClusterFit() should give the one-way cluster-robust covariance", t, func() {
+		r, err := mod.ClusterFit(0, src, cols, ClusterOptions{By: byTax})
+		cv.So(err, cv.ShouldBeNil)
+		want := direct(tax)
+		for a := 0; a < p; a++ {
+			for b := 0; b < p; b++ {
+				cv.So(r.Vcov.At(a, b), cv.ShouldAlmostEqual, want[a][b], 1e-8*math.Sqrt(want[a][a]*want[b][b]))
+			}
+		}
+		cv.So(r.CovType, cv.ShouldEqual, "cluster-robust, 9 clusters")
+		cv.So(r.Names[3], cv.ShouldEqual, "DLic")
+		fmt.Printf("\n%s\n", r)
+
+		// without TwoWay, By2 is ignored
+		r2, err := mod.ClusterFit(0, src, cols, ClusterOptions{By: byTax, By2: byRegion})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(EpsSliceEqual(r2.Vcov.A, r.Vcov.A, 1e-8*math.Abs(r.Vcov.A[0])), cv.ShouldBeTrue)
+	})
+
+	cv.Convey("Two-way clustering should be V1 + V2 - V12", t, func() {
+		r, err := mod.ClusterFit(0, src, cols, ClusterOptions{By: byTax, TwoWay: true, By2: byRegion})
+		cv.So(err, cv.ShouldBeNil)
+		v1, v2, v12 := direct(tax), direct(region), direct(both)
+		for a := 0; a < p; a++ {
+			want := v1[a][a] + v2[a][a] - v12[a][a]
+			cv.So(r.Vcov.At(a, a), cv.ShouldAlmostEqual, want, 1e-8*math.Abs(v1[a][a]))
+		}
+		cv.So(r.CovType, cv.ShouldEqual, "two-way cluster-robust, 9 and 5 clusters")
+	})
+
+	cv.Convey("Spilling the score sums to disk should not change the answer", t, func() {
+		opt := ClusterOptions{By: byTax, TwoWay: true, By2: byRegion}
+		inMemory, err := mod.ClusterFit(0, src, cols, opt)
+		cv.So(err, cv.ShouldBeNil)
+		opt.MaxClusters = 2
+		opt.SpillBuckets = 3
+		opt.SpillDir = t.TempDir()
+		spilled, err := mod.ClusterFit(0, src, cols, opt)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(EpsSliceEqual(spilled.Vcov.A, inMemory.Vcov.A, 1e-8*math.Abs(inMemory.Vcov.A[0])), cv.ShouldBeTrue)
+		cv.So(spilled.CovType, cv.ShouldEqual, inMemory.CovType)
+	})
+
+	cv.Convey("Bad cluster columns, a single cluster, or a NaN cluster id should be errors", t, func() {
+		_, err := mod.ClusterFit(0, src, cols, ClusterOptions{By: -2})
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = mod.ClusterFit(0, src, cols, ClusterOptions{By: 99})
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = mod.ClusterFit(0, src, cols, ClusterOptions{By: byTax, TwoWay: true, By2: byTax})
+		cv.So(err, cv.ShouldNotBeNil)
+
+		one := make([][]float64, n)
+		for i := range rows {
+			one[i] = append(append([]float64{}, rows[i]...), 1)
+		}
+		_, err = mod.ClusterFit(0, NewSliceSource(one), cols, ClusterOptions{By: byRegion + 1})
+		cv.So(err, cv.ShouldNotBeNil)
+
+		one[3][byRegion+1] = math.NaN()
+		one[4][byRegion+1] = 2
+		_, err = mod.ClusterFit(0, NewSliceSource(one), cols, ClusterOptions{By: byRegion + 1})
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
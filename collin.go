@@ -0,0 +1,401 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Collinearity diagnostics, from the factorization alone.
+//
+// With X = Q sqrt(D) R, X'X = R'DR is at hand without the data, and so
+// are:
+//
+// The variance inflation factors: VIF_j = 1/(1 - R_j^2), with R_j^2 from
+// regressing x_j on the other x-variables, which is the factor by which
+// collinearity inflates the variance of b_j. With an intercept this is
+// (X'X)^-1_jj times the sum of squares of x_j about its mean, as in
+// car::vif(); without one, the uncentered (X'X)^-1_jj (X'X)_jj.
+//
+// Belsley, Kuh and Welsch's condition indices and variance-decomposition
+// proportions: scale each column of X, the constant included, to unit
+// length, and take the eigenvalues mu_k and eigenvectors v_k of the
+// scaled X'X. The condition indices are sqrt(mu_max/mu_k), the largest
+// being the condition number, and the proportion of the variance of b_j
+// due to dimension k is (v_jk^2/mu_k) / (sum over k of v_jk^2/mu_k). A
+// condition index over about 30, with two or more proportions over 0.5
+// in its row, marks the variables in a near dependency.
+//
+// Columns that are exactly (to within Tolset()'s tolerance) linear
+// combinations of earlier ones, which SingularCheck() flags in lindep,
+// are reported as Aliases, with the combination, as R's alias() does,
+// and left out of the other diagnostics.
+
+// Alias says that one x-variable is a linear combination of others.
+type Alias struct {
+	Var   int    // the aliased variable
+	Name  string // and its name
+	Vars  []int  // the variables it is a combination of; 0 is the intercept
+	Names []string
+	Coef  []float64 // Var = sum of Coef[i] * Vars[i]
+}
+
+// CollinearityReport is returned by Collinearity().
+type CollinearityReport struct {
+	// the variables not aliased, in original order; 0 is the intercept
+	Vars  []int
+	Names []string
+
+	VIF []float64 // one per variable; NaN for the intercept
+
+	// one per dimension, in decreasing order of eigenvalue, and so of
+	// increasing condition index
+	Eigenvalues []float64
+	CondIndex   []float64
+	CondNumber  float64
+
+	// Proportions[k][j]: the proportion of the variance of the
+	// coefficient of Vars[j] that goes with dimension k.
+	Proportions [][]float64
+
+	Aliases []*Alias
+}
+
+// Collinearity(): the collinearity diagnostics of the x-variables, with
+// the constant, if any. A variable is aliased when it is a combination
+// of the variables before it in m.Vorder, after the constant. If
+// SetMeanSd() is in use, the aliases are between the normalized
+// variables. m is not changed.
+func (m *MillerLSQ) Collinearity() (*CollinearityReport, error) {
+	if m.Nobs <= 0 {
+		return nil, errors.New("Collinearity() error: no observations")
+	}
+	c := m.Clone()
+	err := c.constantToFront()
+	if err != nil {
+		return nil, err
+	}
+	if !c.Tol_set {
+		c.Tolset(1e-12)
+	}
+	lindep := make([]bool, c.Ncol)
+	c.SingularCheck(&lindep, 0)
+
+	rep := &CollinearityReport{}
+	// the positions kept, in order
+	var kept []int
+	for pos := 0; pos < c.Ncol; pos++ {
+		if !lindep[pos] {
+			kept = append(kept, pos)
+			continue
+		}
+		rep.Aliases = append(rep.Aliases, c.alias(pos, kept))
+	}
+	if len(kept) == 0 {
+		return nil, errors.New("Collinearity() error: every column is aliased")
+	}
+
+	// present the variables in original order
+	sort.Slice(kept, func(a, b int) bool { return c.Vorder[kept[a]] < c.Vorder[kept[b]] })
+	n := len(kept)
+	for _, pos := range kept {
+		v := c.Vorder[pos]
+		rep.Vars = append(rep.Vars, v)
+		rep.Names = append(rep.Names, varName(v))
+	}
+
+	xtx := NewSquareMatrix(n)
+	for a, pa := range kept {
+		for b, pb := range kept {
+			xtx.Set(a, b, c.xtxAt(pa, pb))
+		}
+	}
+	inv, err := c.xtxInverse(kept)
+	if err != nil {
+		return nil, err
+	}
+
+	rep.VIF = make([]float64, n)
+	for j, v := range rep.Vars {
+		if v == 0 {
+			rep.VIF[j] = math.NaN()
+			continue
+		}
+		ss := xtx.At(j, j)
+		if !c.NoIntercept {
+			// about the mean: subtract (sum of x)^2 / (sum of weights)
+			ss -= xtx.At(0, j) * xtx.At(0, j) / xtx.At(0, 0)
+		}
+		rep.VIF[j] = inv.At(j, j) * ss
+	}
+
+	// scale to unit length, and decompose
+	scaled := NewSquareMatrix(n)
+	for a := 0; a < n; a++ {
+		for b := 0; b < n; b++ {
+			scaled.Set(a, b, xtx.At(a, b)/math.Sqrt(xtx.At(a, a)*xtx.At(b, b)))
+		}
+	}
+	mu, vecs := symmetricEigen(scaled)
+	rep.Eigenvalues = mu
+	rep.CondIndex = make([]float64, n)
+	for k := range mu {
+		rep.CondIndex[k] = math.Sqrt(mu[0] / mu[k])
+	}
+	rep.CondNumber = rep.CondIndex[n-1]
+
+	rep.Proportions = make([][]float64, n)
+	for k := range rep.Proportions {
+		rep.Proportions[k] = make([]float64, n)
+	}
+	for j := 0; j < n; j++ {
+		total := 0.0
+		for k := 0; k < n; k++ {
+			phi := vecs.At(j, k) * vecs.At(j, k) / mu[k]
+			rep.Proportions[k][j] = phi
+			total += phi
+		}
+		for k := 0; k < n; k++ {
+			rep.Proportions[k][j] /= total
+		}
+	}
+	return rep, nil
+}
+
+// Collinearity(): as MillerLSQ.Collinearity(), with the names of the Model.
+func (mod *Model) Collinearity() (*CollinearityReport, error) {
+	rep, err := mod.MillerLSQ.Collinearity()
+	if err != nil {
+		return nil, err
+	}
+	name := func(v int) string {
+		if v > 0 && v-1 < len(mod.Xnames) {
+			return mod.Xnames[v-1]
+		}
+		return varName(v)
+	}
+	for i, v := range rep.Vars {
+		rep.Names[i] = name(v)
+	}
+	for _, a := range rep.Aliases {
+		a.Name = name(a.Var)
+		for i, v := range a.Vars {
+			a.Names[i] = name(v)
+		}
+	}
+	return rep, nil
+}
+
+func varName(v int) string {
+	if v == 0 {
+		return "(Intercept)"
+	}
+	return fmt.Sprintf("x%d", v)
+}
+
+// rAt(): R[i][j], for positions i <= j, with the unit diagonal.
+func (m *MillerLSQ) rAt(i int, j int) float64 {
+	if i == j {
+		return 1
+	}
+	return m.R[m.Row_ptr[i]+j-i-1]
+}
+
+// xtxAt(): (X'X)[i][j] = sum over positions l of D[l] R[l][i] R[l][j],
+// for positions i and j.
+func (m *MillerLSQ) xtxAt(i int, j int) float64 {
+	s := 0.0
+	for l := 0; l <= i && l <= j; l++ {
+		s += m.D[l] * m.rAt(l, i) * m.rAt(l, j)
+	}
+	return s
+}
+
+// xtxInverse(): the inverse of X'X restricted to the positions kept,
+// which must have non-zero D, as R^-1 D^-1 R^-T, in the order of kept.
+func (m *MillerLSQ) xtxInverse(kept []int) (*SquareMatrix, error) {
+	// the positions in increasing order, where R is upper triangular
+	byPos := DeepCopyInts(kept)
+	sort.Ints(byPos)
+	n := len(byPos)
+	for _, p := range byPos {
+		if m.D[p] <= 0 {
+			return nil, errors.New(fmt.Sprintf("collinearity error: D[%d] = %v is not positive", p, m.D[p]))
+		}
+	}
+
+	// rinv = R^-1 for the rows and columns of byPos, by back-substitution
+	rinv := make([]float64, n*n)
+	for col := 0; col < n; col++ {
+		rinv[col*n+col] = 1
+		for row := col - 1; row >= 0; row-- {
+			s := 0.0
+			for k := row + 1; k <= col; k++ {
+				s -= m.rAt(byPos[row], byPos[k]) * rinv[k*n+col]
+			}
+			rinv[row*n+col] = s
+		}
+	}
+	at := make(map[int]int, n)
+	for i, p := range byPos {
+		at[p] = i
+	}
+	inv := NewSquareMatrix(n)
+	for a, pa := range kept {
+		for b, pb := range kept {
+			i, j := at[pa], at[pb]
+			s := 0.0
+			for k := 0; k < n; k++ {
+				s += rinv[i*n+k] * rinv[j*n+k] / m.D[byPos[k]]
+			}
+			inv.Set(a, b, s)
+		}
+	}
+	return inv, nil
+}
+
+// alias(): the aliased column at position k as a combination of the
+// columns at the earlier positions in kept, after SingularCheck() has
+// cleared row k of R and D: c solves R_BB c = R_Bk, with B those
+// positions, since X_k = Q_B sqrt(D_B) R_Bk and X_B = Q_B sqrt(D_B) R_BB.
+// Terms too small to matter next to the length of X_k are rounding
+// error, and left out.
+func (m *MillerLSQ) alias(k int, kept []int) *Alias {
+	n := len(kept)
+	coef := make([]float64, n)
+	for a := n - 1; a >= 0; a-- {
+		s := m.rAt(kept[a], k)
+		for b := a + 1; b < n; b++ {
+			s -= m.rAt(kept[a], kept[b]) * coef[b]
+		}
+		coef[a] = s
+	}
+	al := &Alias{Var: m.Vorder[k], Name: varName(m.Vorder[k])}
+	normk := math.Sqrt(m.xtxAt(k, k))
+	for a, p := range kept {
+		if math.Abs(coef[a])*math.Sqrt(m.xtxAt(p, p)) <= 1e-10*normk {
+			continue
+		}
+		al.Vars = append(al.Vars, m.Vorder[p])
+		al.Names = append(al.Names, varName(m.Vorder[p]))
+		al.Coef = append(al.Coef, coef[a])
+	}
+	return al
+}
+
+// symmetricEigen(): the eigenvalues of the symmetric matrix a, in
+// decreasing order, and the eigenvectors, as the columns of vecs in the
+// same order, by cyclic Jacobi rotations. a is not changed.
+func symmetricEigen(a *SquareMatrix) (vals []float64, vecs *SquareMatrix) {
+	n := a.Ncol
+	w := NewSquareMatrix(n)
+	copy(w.A, a.A)
+	v := NewSquareMatrix(n)
+	for i := 0; i < n; i++ {
+		v.Set(i, i, 1)
+	}
+
+	for sweep := 0; sweep < 100; sweep++ {
+		off := 0.0
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				off += w.At(i, j) * w.At(i, j)
+			}
+		}
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				apq := w.At(p, q)
+				if apq == 0 {
+					continue
+				}
+				theta := (w.At(q, q) - w.At(p, p)) / (2 * apq)
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < n; k++ {
+					wkp, wkq := w.At(k, p), w.At(k, q)
+					w.Set(k, p, c*wkp-s*wkq)
+					w.Set(k, q, s*wkp+c*wkq)
+				}
+				for k := 0; k < n; k++ {
+					wpk, wqk := w.At(p, k), w.At(q, k)
+					w.Set(p, k, c*wpk-s*wqk)
+					w.Set(q, k, s*wpk+c*wqk)
+				}
+				for k := 0; k < n; k++ {
+					vkp, vkq := v.At(k, p), v.At(k, q)
+					v.Set(k, p, c*vkp-s*vkq)
+					v.Set(k, q, s*vkp+c*vkq)
+				}
+			}
+		}
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return w.At(order[a], order[a]) > w.At(order[b], order[b]) })
+	vals = make([]float64, n)
+	vecs = NewSquareMatrix(n)
+	for k, o := range order {
+		vals[k] = w.At(o, o)
+		for i := 0; i < n; i++ {
+			vecs.Set(i, k, v.At(i, o))
+		}
+	}
+	return vals, vecs
+}
+
+func (a *Alias) String() string {
+	s := a.Name + " ="
+	for i := range a.Vars {
+		if i > 0 {
+			s += " +"
+		}
+		s += fmt.Sprintf(" %.6g * %s", a.Coef[i], a.Names[i])
+	}
+	if len(a.Vars) == 0 {
+		s += " 0"
+	}
+	return s
+}
+
+func (r *CollinearityReport) String() string {
+	names := NormalizeNameLengths(append([]string{""}, r.Names...))
+	s := "Collinearity diagnostics\n"
+	s += fmt.Sprintf("%s  %10s\n", names[0], "VIF")
+	for j := range r.Vars {
+		if math.IsNaN(r.VIF[j]) {
+			continue
+		}
+		s += fmt.Sprintf("%s  %10.4g\n", names[j+1], r.VIF[j])
+	}
+	s += fmt.Sprintf("\nCondition number: %.4g\n", r.CondNumber)
+	s += fmt.Sprintf("%4s  %12s  %10s", "dim", "eigenvalue", "cond.index")
+	for _, name := range r.Names {
+		s += fmt.Sprintf("  %12.12s", name)
+	}
+	s += "\n"
+	for k := range r.Eigenvalues {
+		s += fmt.Sprintf("%4d  %12.6g  %10.4g", k+1, r.Eigenvalues[k], r.CondIndex[k])
+		for _, p := range r.Proportions[k] {
+			s += fmt.Sprintf("  %12.4f", p)
+		}
+		s += "\n"
+	}
+	if len(r.Aliases) > 0 {
+		s += "\nAliased variables:\n"
+		for _, a := range r.Aliases {
+			s += "  " + a.String() + "\n"
+		}
+	}
+	return s
+}
@@ -0,0 +1,169 @@
+package lsq
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestCollinearity(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+
+	// Tax, NLic, Income, RoadMls, DLic
+	xcols := []int{2, 3, 4, 5, 7}
+	names := []string{"Tax", "NLic", "Income", "RoadMls", "DLic"}
+	nx := len(xcols)
+
+	mod := NewModel(names, []string{"Fuel_Pop"})
+	xrow := make([]float64, nx)
+	for i := range df.Rows {
+		for k, j := range xcols {
+			xrow[k] = df.Rows[i][j]
+		}
+		mod.Includ(1, xrow, df.Rows[i][last:], NAN_OMIT_ROW)
+	}
+
+	cv.Convey("Given the fuelcons.dat data, the VIFs should be 1/(1-R^2) of each x-variable on the others", t, func() {
+		before := DeepCopyInts(mod.Vorder)
+		rep, err := mod.Collinearity()
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(mod.Vorder, cv.ShouldResemble, before)
+		cv.So(len(rep.Aliases), cv.ShouldEqual, 0)
+		cv.So(rep.Names, cv.ShouldResemble, append([]string{"(Intercept)"}, names...))
+		cv.So(math.IsNaN(rep.VIF[0]), cv.ShouldBeTrue)
+		fmt.Printf("\n%s\n", rep)
+
+		for j := 0; j < nx; j++ {
+			// regress x_j on the others
+			aux := NewMillerLSQ(nx-1, 1)
+			others := make([]float64, 0, nx-1)
+			for i := range df.Rows {
+				others = others[:0]
+				for k, c := range xcols {
+					if k != j {
+						others = append(others, df.Rows[i][c])
+					}
+				}
+				aux.Includ(1, others, []float64{df.Rows[i][xcols[j]]}, NAN_OMIT_ROW)
+			}
+			fit, err := aux.Fit(0)
+			cv.So(err, cv.ShouldBeNil)
+			r2 := 1 - fit.Rss/fit.Tss
+			cv.So(rep.VIF[j+1], cv.ShouldAlmostEqual, 1/(1-r2), 1e-8/(1-r2))
+		}
+	})
+
+	cv.Convey("The condition indices and variance-decomposition proportions should come from the eigen-decomposition of the unit-scaled X'X", t, func() {
+		rep, err := mod.Collinearity()
+		cv.So(err, cv.ShouldBeNil)
+		p := nx + 1
+
+		// the scaled X'X, straight from the data
+		xtx := make([][]float64, p)
+		for a := range xtx {
+			xtx[a] = make([]float64, p)
+		}
+		for i := range df.Rows {
+			x := []float64{1}
+			for _, j := range xcols {
+				x = append(x, df.Rows[i][j])
+			}
+			for a := 0; a < p; a++ {
+				for b := 0; b < p; b++ {
+					xtx[a][b] += x[a] * x[b]
+				}
+			}
+		}
+		// the scaled X'X has a unit diagonal, so its trace is p
+		sum := 0.0
+		for _, mu := range rep.Eigenvalues {
+			sum += mu
+		}
+		cv.So(sum, cv.ShouldAlmostEqual, float64(p), 1e-10)
+		cv.So(rep.Eigenvalues[p-1] > 0, cv.ShouldBeTrue)
+
+		// mu_k = v_k' A v_k, and the proportions add up to 1 for each coefficient
+		_, vecs := symmetricEigen(func() *SquareMatrix {
+			a := NewSquareMatrix(p)
+			for i := 0; i < p; i++ {
+				for j := 0; j < p; j++ {
+					a.Set(i, j, xtx[i][j]/math.Sqrt(xtx[i][i]*xtx[j][j]))
+				}
+			}
+			return a
+		}())
+		for k := 0; k < p; k++ {
+			q := 0.0
+			for i := 0; i < p; i++ {
+				for j := 0; j < p; j++ {
+					q += vecs.At(i, k) * xtx[i][j] / math.Sqrt(xtx[i][i]*xtx[j][j]) * vecs.At(j, k)
+				}
+			}
+			cv.So(q, cv.ShouldAlmostEqual, rep.Eigenvalues[k], 1e-10)
+			cv.So(rep.CondIndex[k], cv.ShouldAlmostEqual, math.Sqrt(rep.Eigenvalues[0]/rep.Eigenvalues[k]), 1e-10)
+		}
+		for j := 0; j < p; j++ {
+			total := 0.0
+			for k := 0; k < p; k++ {
+				total += rep.Proportions[k][j]
+			}
+			cv.So(total, cv.ShouldAlmostEqual, 1, 1e-12)
+		}
+		cv.So(rep.CondNumber, cv.ShouldEqual, rep.CondIndex[p-1])
+		cv.So(rep.CondNumber > 1, cv.ShouldBeTrue)
+	})
+
+	cv.Convey("Exactly dependent columns should be reported as aliases of the earlier ones, and left out of the rest", t, func() {
+		// x6 = 3 + 2*Tax - 0.5*Income, x7 = RoadMls
+		al := NewModel(append(append([]string{}, names...), "Combo", "RoadMls2"), []string{"Fuel_Pop"})
+		row := make([]float64, nx+2)
+		for i := range df.Rows {
+			for k, j := range xcols {
+				row[k] = df.Rows[i][j]
+			}
+			row[nx] = 3 + 2*df.Rows[i][2] - 0.5*df.Rows[i][4]
+			row[nx+1] = df.Rows[i][5]
+			al.Includ(1, row, df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		_, err := al.Fit(0)
+		cv.So(err, cv.ShouldNotBeNil)
+
+		rep, err := al.Collinearity()
+		cv.So(err, cv.ShouldBeNil)
+		fmt.Printf("\n%s\n", rep)
+		cv.So(len(rep.Aliases), cv.ShouldEqual, 2)
+
+		a := rep.Aliases[0]
+		cv.So(a.Name, cv.ShouldEqual, "Combo")
+		want := map[string]float64{"(Intercept)": 3, "Tax": 2, "Income": -0.5}
+		for i, name := range a.Names {
+			w := want[name]
+			cv.So(a.Coef[i], cv.ShouldAlmostEqual, w, 1e-6)
+			delete(want, name)
+		}
+		cv.So(len(want), cv.ShouldEqual, 0)
+
+		b := rep.Aliases[1]
+		cv.So(b.Name, cv.ShouldEqual, "RoadMls2")
+		for i, name := range b.Names {
+			w := 0.0
+			if name == "RoadMls" {
+				w = 1
+			}
+			cv.So(b.Coef[i], cv.ShouldAlmostEqual, w, 1e-6)
+		}
+
+		// the rest is as without the aliased columns
+		plain, err := mod.Collinearity()
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(rep.Names, cv.ShouldResemble, plain.Names)
+		cv.So(EpsSliceEqual(rep.VIF[1:], plain.VIF[1:], 1e-6), cv.ShouldBeTrue)
+	})
+}
@@ -0,0 +1,370 @@
+package lsq
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Delimited text, comma- or tab-separated, by way of encoding/csv, so
+// quoted fields, embedded separators and quotes are all read correctly.
+// Fields are parsed with strconv.ParseFloat(), and missing values, such
+// as NA or an empty field, become NaN, to be handled by Includ() as any
+// other NaN. Anything else that is not a number is an error, a
+// *CSVError, which says where it is.
+
+// DefaultNA are the fields read as missing when CSVOptions.NA is nil.
+var DefaultNA = []string{"", "NA", "N/A", "NULL", "null", "."}
+
+// CSVOptions says how to read delimited text. The zero value reads
+// comma-separated text with a header line, keeping every column.
+type CSVOptions struct {
+	Comma   rune // the separator; 0 means ','. Use '\t' for TSV.
+	Comment rune // if not 0, lines starting with it are skipped
+
+	// NoHeader says the first line is data; the columns are then named
+	// V1, V2, ... as in R.
+	NoHeader bool
+
+	// RowNames says the first column holds row names rather than data.
+	RowNames bool
+
+	// NA lists the fields, after trimming spaces, that are missing
+	// values; nil means DefaultNA.
+	NA []string
+
+	// Select names the columns to keep, in the order wanted; nil keeps
+	// them all.
+	Select []string
+}
+
+// Errors of a *CSVError.
+var (
+	ErrNotNumber  = errors.New("not a number")
+	ErrNoColumn   = errors.New("no such column")
+	ErrEmptyInput = errors.New("no header line")
+)
+
+// CSVError is an error reading delimited text, with where it happened.
+type CSVError struct {
+	Line   int    // from 1, counting the header
+	Column int    // of the field, from 1 as in a spreadsheet; 0 if not about one field
+	Name   string // of the column, if known
+	Field  string // the text that could not be read, if any
+	Err    error  // such as ErrNotNumber, or one from encoding/csv
+}
+
+func (e *CSVError) Error() string {
+	s := fmt.Sprintf("line %d", e.Line)
+	if e.Column > 0 {
+		s += fmt.Sprintf(", column %d", e.Column)
+	}
+	if e.Name != "" {
+		s += fmt.Sprintf(" (%s)", e.Name)
+	}
+	if e.Field != "" {
+		s += fmt.Sprintf(", field %q", e.Field)
+	}
+	return s + ": " + e.Err.Error()
+}
+
+func (e *CSVError) Unwrap() error {
+	return e.Err
+}
+
+// CSVSource is a RowSource over delimited text, parsing one record at a
+// time. Its rows hold the selected columns, in the order of Colnames.
+type CSVSource struct {
+	Colnames []string // of the columns in each row
+
+	opt     CSVOptions
+	na      map[string]bool
+	r       io.Reader
+	f       *os.File // when opened from a path
+	cr      *csv.Reader
+	header  []string // every column of the input
+	pending []string // the first record, with NoHeader
+	started bool     // a row has been read since start()
+	keep    []int    // the input field of each column of a row
+	row     []float64
+	name    string
+	err     error
+}
+
+// NewCSVSource(): a CSVSource over r. The header, if any, is read now,
+// so that a missing column in opt.Select is an error at once. Reset()
+// needs r to be an io.Seeker, as files and strings.Readers are, once
+// any row has been read.
+func NewCSVSource(r io.Reader, opt CSVOptions) (*CSVSource, error) {
+	s := &CSVSource{opt: opt, r: r}
+	na := opt.NA
+	if na == nil {
+		na = DefaultNA
+	}
+	s.na = make(map[string]bool, len(na))
+	for _, v := range na {
+		s.na[strings.TrimSpace(v)] = true
+	}
+	err := s.start()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenCSV(): a CSVSource over the file at path. Close() it when done.
+func OpenCSV(path string, opt CSVOptions) (*CSVSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewCSVSource(f, opt)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.f = f
+	return s, nil
+}
+
+// start(): a fresh csv.Reader from where s.r is, with the header read
+// and the columns worked out.
+func (s *CSVSource) start() error {
+	s.cr = csv.NewReader(s.r)
+	if s.opt.Comma != 0 {
+		s.cr.Comma = s.opt.Comma
+	}
+	s.cr.Comment = s.opt.Comment
+	s.cr.ReuseRecord = true
+	s.err = nil
+	s.pending = nil
+	s.started = false
+
+	rec, err := s.cr.Read()
+	if err == io.EOF {
+		if !s.opt.NoHeader {
+			return &CSVError{Line: 1, Err: ErrEmptyInput}
+		}
+	} else if err != nil {
+		return s.wrap(err)
+	}
+	s.header = make([]string, len(rec))
+	if s.opt.NoHeader {
+		// the first record is a row, given by the next Next()
+		for j := range rec {
+			s.header[j] = fmt.Sprintf("V%d", j+1)
+		}
+		if rec != nil {
+			s.pending = append([]string{}, rec...)
+		}
+	} else {
+		for j, h := range rec {
+			s.header[j] = strings.TrimSpace(h)
+		}
+	}
+
+	first := 0
+	if s.opt.RowNames {
+		first = 1
+	}
+	s.keep = s.keep[:0]
+	s.Colnames = s.Colnames[:0]
+	if s.opt.Select == nil {
+		for j := first; j < len(s.header); j++ {
+			s.keep = append(s.keep, j)
+			s.Colnames = append(s.Colnames, s.header[j])
+		}
+	} else {
+		for _, name := range s.opt.Select {
+			j := -1
+			for k := first; k < len(s.header); k++ {
+				if s.header[k] == name {
+					j = k
+					break
+				}
+			}
+			if j < 0 {
+				return &CSVError{Line: 1, Name: name, Err: ErrNoColumn}
+			}
+			s.keep = append(s.keep, j)
+			s.Colnames = append(s.Colnames, name)
+		}
+	}
+	s.row = make([]float64, len(s.keep))
+	return nil
+}
+
+// rewind(): seek s.r back to its start.
+func (s *CSVSource) rewind() error {
+	seeker, ok := s.r.(io.Seeker)
+	if !ok {
+		return errors.New("CSVSource error: cannot go back to the start, as the reader is not an io.Seeker")
+	}
+	_, err := seeker.Seek(0, io.SeekStart)
+	return err
+}
+
+// wrap(): err from encoding/csv as a *CSVError.
+func (s *CSVSource) wrap(err error) error {
+	var pe *csv.ParseError
+	if errors.As(err, &pe) {
+		return &CSVError{Line: pe.Line, Column: pe.Column, Err: pe.Err}
+	}
+	return err
+}
+
+// Next(): the next row, or nil at the end or on an error. The slice is
+// reused by the following call.
+func (s *CSVSource) Next() []float64 {
+	if s.err != nil || s.cr == nil {
+		return nil
+	}
+	s.started = true
+	rec := s.pending
+	if rec != nil {
+		s.pending = nil
+	} else {
+		var err error
+		rec, err = s.cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			s.err = s.wrap(err)
+			return nil
+		}
+	}
+	s.name = ""
+	if s.opt.RowNames && len(rec) > 0 {
+		s.name = rec[0]
+	}
+	for k, j := range s.keep {
+		z, err := s.parse(rec[j])
+		if err != nil {
+			line, col := s.cr.FieldPos(j)
+			s.err = &CSVError{Line: line, Column: col, Name: s.header[j], Field: rec[j], Err: err}
+			return nil
+		}
+		s.row[k] = z
+	}
+	return s.row
+}
+
+// parse(): one field as a number, NaN if it is missing.
+func (s *CSVSource) parse(field string) (float64, error) {
+	z, err := strconv.ParseFloat(field, 64)
+	if err == nil || isRangeError(err) {
+		return z, nil
+	}
+	t := strings.TrimSpace(field)
+	if s.na[t] {
+		return math.NaN(), nil
+	}
+	z, err = strconv.ParseFloat(t, 64)
+	if err == nil || isRangeError(err) {
+		return z, nil
+	}
+	return 0, ErrNotNumber
+}
+
+// RowName(): the row name of the row last returned by Next(), with
+// CSVOptions.RowNames.
+func (s *CSVSource) RowName() string {
+	return s.name
+}
+
+// Err(): the error that ended the rows, or nil.
+func (s *CSVSource) Err() error {
+	return s.err
+}
+
+// Reset(): back to the first row. Until a row has been read there is
+// nothing to do, so a reader that cannot seek can still be read once.
+func (s *CSVSource) Reset() error {
+	if !s.started && s.err == nil {
+		return nil
+	}
+	err := s.rewind()
+	if err != nil {
+		return err
+	}
+	return s.start()
+}
+
+// Close(): close the file, if the source opened one.
+func (s *CSVSource) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	s.cr = nil
+	return err
+}
+
+// Index(): the column of a row holding the column named name.
+func (s *CSVSource) Index(name string) (int, error) {
+	for j, c := range s.Colnames {
+		if c == name {
+			return j, nil
+		}
+	}
+	return -1, &CSVError{Line: 1, Name: name, Err: ErrNoColumn}
+}
+
+// Columns(): the Columns for the x-variables and y-targets named, and
+// the weight, or no weight if wname is "".
+func (s *CSVSource) Columns(xnames []string, ynames []string, wname string) (Columns, error) {
+	cols := Columns{Weight: NoWeight}
+	for _, name := range xnames {
+		j, err := s.Index(name)
+		if err != nil {
+			return cols, err
+		}
+		cols.X = append(cols.X, j)
+	}
+	for _, name := range ynames {
+		j, err := s.Index(name)
+		if err != nil {
+			return cols, err
+		}
+		cols.Y = append(cols.Y, j)
+	}
+	if wname != "" {
+		j, err := s.Index(wname)
+		if err != nil {
+			return cols, err
+		}
+		cols.Weight = j
+	}
+	return cols, nil
+}
+
+// ReadCSV(): all of r into a DataFrame, for data small enough to hold;
+// for anything bigger, use a CSVSource as a RowSource directly.
+func ReadCSV(r io.Reader, opt CSVOptions) (*DataFrame, error) {
+	s, err := NewCSVSource(r, opt)
+	if err != nil {
+		return nil, err
+	}
+	df := &DataFrame{
+		Colnames: append([]string{}, s.Colnames...),
+		Ncol:     len(s.Colnames),
+	}
+	for row := s.Next(); row != nil; row = s.Next() {
+		df.Rows = append(df.Rows, DeepCopy(row))
+		if opt.RowNames {
+			df.Rownames = append(df.Rownames, s.RowName())
+		}
+		df.Nrow++
+	}
+	if s.Err() != nil {
+		return nil, s.Err()
+	}
+	return df, nil
+}
@@ -0,0 +1,211 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestCSV(t *testing.T) {
+
+	cv.Convey("Quoted fields, with separators, quotes and newlines inside, should be read as one field each", t, func() {
+		text := "name,\"x, in cm\",y\n\"Smith, J.\",1.5,2\n\"say \"\"hi\"\"\",\"3\",4e1\n\"two\nlines\",5,6\n"
+		df, err := ReadCSV(strings.NewReader(text), CSVOptions{RowNames: true})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(df.Colnames, cv.ShouldResemble, []string{"x, in cm", "y"})
+		cv.So(df.Rownames, cv.ShouldResemble, []string{"Smith, J.", "say \"hi\"", "two\nlines"})
+		cv.So(df.Nrow, cv.ShouldEqual, 3)
+		cv.So(df.Ncol, cv.ShouldEqual, 2)
+		cv.So(df.Rows, cv.ShouldResemble, [][]float64{{1.5, 2}, {3, 40}, {5, 6}})
+	})
+
+	cv.Convey("TSV, NA tokens and columns picked by name should all work", t, func() {
+		text := "a\tb\tc\n1\tNA\t3\n\t5\t 6 \n7\t-\t9\n"
+		df, err := ReadCSV(strings.NewReader(text), CSVOptions{Comma: '\t', Select: []string{"c", "a"}})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(df.Colnames, cv.ShouldResemble, []string{"c", "a"})
+		cv.So(df.Rows[0], cv.ShouldResemble, []float64{3, 1})
+		cv.So(df.Rows[1][0], cv.ShouldEqual, 6)
+		cv.So(math.IsNaN(df.Rows[1][1]), cv.ShouldBeTrue)
+
+		// b holds a "-", which is not a number unless it is said to be NA
+		_, err = ReadCSV(strings.NewReader(text), CSVOptions{Comma: '\t'})
+		cv.So(errors.Is(err, ErrNotNumber), cv.ShouldBeTrue)
+		df, err = ReadCSV(strings.NewReader(text), CSVOptions{Comma: '\t', NA: []string{"NA", "", "-"}})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(math.IsNaN(df.Rows[0][1]), cv.ShouldBeTrue)
+		cv.So(math.IsNaN(df.Rows[2][1]), cv.ShouldBeTrue)
+
+		// without a header, the columns are V1, V2, ...
+		df, err = ReadCSV(strings.NewReader("1,2\n3,4\n"), CSVOptions{NoHeader: true})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(df.Colnames, cv.ShouldResemble, []string{"V1", "V2"})
+		cv.So(df.Rows, cv.ShouldResemble, [][]float64{{1, 2}, {3, 4}})
+	})
+
+	cv.Convey("Errors should be *CSVErrors, saying on which line and in which column", t, func() {
+		var ce *CSVError
+
+		_, err := ReadCSV(strings.NewReader("a,b\n1,2\n3,oops\n"), CSVOptions{})
+		cv.So(errors.As(err, &ce), cv.ShouldBeTrue)
+		cv.So(ce.Line, cv.ShouldEqual, 3)
+		cv.So(ce.Column, cv.ShouldEqual, 3)
+		cv.So(ce.Name, cv.ShouldEqual, "b")
+		cv.So(ce.Field, cv.ShouldEqual, "oops")
+		cv.So(ce.Err, cv.ShouldEqual, ErrNotNumber)
+		cv.So(strings.Contains(err.Error(), "line 3"), cv.ShouldBeTrue)
+
+		// a short row
+		_, err = ReadCSV(strings.NewReader("a,b\n1,2\n3\n"), CSVOptions{})
+		cv.So(errors.As(err, &ce), cv.ShouldBeTrue)
+		cv.So(ce.Line, cv.ShouldEqual, 3)
+
+		// a bare quote
+		_, err = ReadCSV(strings.NewReader("a,b\n1,2\"x\n"), CSVOptions{})
+		cv.So(errors.As(err, &ce), cv.ShouldBeTrue)
+		cv.So(ce.Line, cv.ShouldEqual, 2)
+
+		_, err = ReadCSV(strings.NewReader("a,b\n1,2\n"), CSVOptions{Select: []string{"a", "z"}})
+		cv.So(errors.Is(err, ErrNoColumn), cv.ShouldBeTrue)
+		cv.So(errors.As(err, &ce), cv.ShouldBeTrue)
+		cv.So(ce.Name, cv.ShouldEqual, "z")
+
+		_, err = ReadCSV(strings.NewReader(""), CSVOptions{})
+		cv.So(errors.Is(err, ErrEmptyInput), cv.ShouldBeTrue)
+
+		_, err = OpenCSV("no-such-file.csv", CSVOptions{})
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = readData("no-such-file.dat")
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+
+	cv.Convey("Given fuelcons.dat as CSV, a CSVSource should fit, by column name, what the DataFrame's rows do", t, func() {
+		df, err := readData("fuelcons.dat")
+		cv.So(err, cv.ShouldBeNil)
+		last := df.Ncol - 1
+		xcols := []int{2, 4, 5, 7}
+		want, err := FitFromSource(NewSliceSource(df.Rows), xcols, []int{last}, 1)
+		cv.So(err, cv.ShouldBeNil)
+		wfit, err := want.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+
+		// as R's write.csv() would: row names, and a header without one
+		names := make([]string, df.Ncol)
+		for j := range names {
+			names[j] = strings.TrimSpace(df.Colnames[j])
+		}
+		var b strings.Builder
+		b.WriteString("\"\"")
+		for _, name := range names[1:] {
+			fmt.Fprintf(&b, ",%q", name)
+		}
+		b.WriteString("\r\n")
+		for i, row := range df.Rows {
+			fmt.Fprintf(&b, "%q", df.Rownames[i])
+			for _, z := range row[1:] {
+				fmt.Fprintf(&b, ",%v", z)
+			}
+			b.WriteString("\r\n")
+		}
+
+		src, err := NewCSVSource(strings.NewReader(b.String()), CSVOptions{RowNames: true})
+		cv.So(err, cv.ShouldBeNil)
+		var xnames []string
+		for _, j := range xcols {
+			xnames = append(xnames, names[j])
+		}
+		cols, err := src.Columns(xnames, []string{names[last]}, names[1])
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(cols.X, cv.ShouldResemble, []int{1, 3, 4, 6})
+		cv.So(cols.Weight, cv.ShouldEqual, 0)
+
+		m := NewMillerLSQ(len(xcols), 1)
+		n, err := m.IncludSource(src, cols)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(n, cv.ShouldEqual, len(df.Rows))
+		cv.So(src.RowName(), cv.ShouldEqual, df.Rownames[len(df.Rownames)-1])
+		fit, err := m.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(EpsSliceEqual(fit.Coef, wfit.Coef, 1e-10), cv.ShouldBeTrue)
+
+		// a second pass reads it again
+		rob, err := m.RobustFit(0, src, cols, HC3)
+		cv.So(err, cv.ShouldBeNil)
+		wrob, err := want.RobustFit(0, NewSliceSource(df.Rows), Columns{X: xcols, Y: []int{last}, Weight: 1}, HC3)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(EpsSliceEqual(rob.StdErr, wrob.StdErr, 1e-10), cv.ShouldBeTrue)
+
+	})
+
+	cv.Convey("A reader that cannot seek can be read once, by FitFromSource(), but not Reset() after that", t, func() {
+		once, err := NewCSVSource(io.MultiReader(strings.NewReader("x,y\n1,2\n2,3\n4,5\n")), CSVOptions{})
+		cv.So(err, cv.ShouldBeNil)
+		m, err := FitFromSource(once, []int{0}, []int{1}, NoWeight)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(m.Nobs, cv.ShouldEqual, 3)
+		cv.So(once.Reset(), cv.ShouldNotBeNil)
+
+		// without a header, the first row is held, and not lost
+		plain, err := NewCSVSource(io.MultiReader(strings.NewReader("1,2\n3,4\n")), CSVOptions{NoHeader: true})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(plain.Reset(), cv.ShouldBeNil)
+		cv.So(plain.Next(), cv.ShouldResemble, []float64{1, 2})
+	})
+}
+
+// benchText: n rows of 10 numbers, spaced as readData() reads them.
+func benchText(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		w := make([]string, 10)
+		for j := range w {
+			w[j] = fmt.Sprintf("%.6g", float64(i*j)/7)
+		}
+		lines[i] = strings.Join(w, " ")
+	}
+	return lines
+}
+
+func BenchmarkLineToFloatSlice(b *testing.B) {
+	lines := benchText(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			LineToFloatSlice(line)
+		}
+	}
+}
+
+func BenchmarkSscanf(b *testing.B) {
+	lines := benchText(1000)
+	b.ResetTimer()
+	var z float64
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			for _, w := range LineToStringSlice(line) {
+				fmt.Sscanf(w, "%f", &z)
+			}
+		}
+	}
+}
+
+func BenchmarkCSVSource(b *testing.B) {
+	text := strings.Replace(strings.Join(benchText(1000), "\n"), " ", ",", -1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		src, err := NewCSVSource(strings.NewReader(text), CSVOptions{NoHeader: true})
+		if err != nil {
+			b.Fatal(err)
+		}
+		for r := src.Next(); r != nil; r = src.Next() {
+		}
+		if src.Err() != nil {
+			b.Fatal(src.Err())
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package lsq
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// CUSUM and CUSUM-of-squares tests of coefficient stability, after
+// Brown, Durbin and Evans (1975), "Techniques for Testing the Constancy
+// of Regression Relationships over Time", JRSS B 37, 149-192.
+//
+// Feed a CusumTracker the recursive residuals w_1..w_n of one y-target,
+// from IncludRecursive(), in the order the rows arrived. The CUSUM
+//
+//	W_r = (w_1 + ... + w_r) / s
+//
+// with s the standard deviation of all n of them, wanders off when the
+// coefficients drift in one direction; it is compared with the lines
+// +/- a (sqrt(n) + 2r/sqrt(n)). The CUSUM of squares
+//
+//	S_r = (w_1^2 + ... + w_r^2) / (w_1^2 + ... + w_n^2)
+//
+// should stay near r/n, and also picks up a change in the variance; it
+// is compared with r/n +/- c, where c = k sqrt(2/n) is the asymptotic
+// (Brownian bridge) approximation to Durbin's (1969) table, a little
+// wide for small n.
+//
+// A CusumTracker keeps only O(1) running state: the count, the
+// cumulative sum, and the mean and sum of squared deviations (Welford's
+// update, as SdTracker does) that give s. As each residual arrives, the
+// CUSUM test of all the rows so far, as if the data ended there, is
+// checked at its last point, where the boundary is 3a sqrt(r):
+//
+//	|w_1 + ... + w_r| / s_r > 3a sqrt(r)
+//
+// with s_r the standard deviation of the first r. The left side is
+// |t| for the mean of the w, with r-1 degrees of freedom, so for small
+// r it is compared with the t quantile of the same tail probability
+// that 3a has for the normal. OnlineCrossing() is the first r at which
+// the check failed. Made after every row of a long stream, it is a
+// monitoring rule rather than a test of exactly the stated size. The
+// full paths of both tests, from Cusum() and CusumSq(), need the
+// residuals themselves, which are kept only after KeepHistory().
+
+// CusumLevel is the size of the CUSUM tests, one of 0.10, 0.05 and 0.01.
+type CusumLevel float64
+
+const (
+	CUSUM_LEVEL_10 CusumLevel = 0.10
+	CUSUM_LEVEL_5  CusumLevel = 0.05
+	CUSUM_LEVEL_1  CusumLevel = 0.01
+)
+
+// cusumA and cusumSqK: the boundary constants a and k of each level.
+var cusumA = map[CusumLevel]float64{CUSUM_LEVEL_10: 0.850, CUSUM_LEVEL_5: 0.948, CUSUM_LEVEL_1: 1.143}
+var cusumSqK = map[CusumLevel]float64{CUSUM_LEVEL_10: 1.224, CUSUM_LEVEL_5: 1.358, CUSUM_LEVEL_1: 1.628}
+
+// CusumTracker follows the recursive residuals of one y-target.
+type CusumTracker struct {
+	Level CusumLevel
+
+	n        int
+	sum      float64
+	mean     float64
+	m2       float64 // sum of squared deviations from mean
+	crossing int
+
+	// the last maxHist residuals, after KeepHistory(); hist may hold up
+	// to twice that before it is shifted down
+	maxHist int
+	hist    []float64
+}
+
+func NewCusumTracker(level CusumLevel) *CusumTracker {
+	if _, ok := cusumA[level]; !ok {
+		panic(fmt.Sprintf("NewCusumTracker(): level %v is not one of 0.10, 0.05 or 0.01", float64(level)))
+	}
+	return &CusumTracker{Level: level}
+}
+
+// KeepHistory(): keep the last max residuals, so that Cusum() and
+// CusumSq() can give the full paths of the tests of them. Call it
+// before the first Add().
+func (c *CusumTracker) KeepHistory(max int) {
+	if max < 2 {
+		panic(fmt.Sprintf("KeepHistory() error: max(%d) must be at least 2", max))
+	}
+	if c.n > 0 {
+		panic("KeepHistory() error: residuals have already been added")
+	}
+	c.maxHist = max
+}
+
+// Add(): add the next recursive residual. NaNs, as IncludRecursive()
+// gives for rows without one, are ignored.
+func (c *CusumTracker) Add(w float64) {
+	if math.IsNaN(w) {
+		return
+	}
+	c.n++
+	c.sum += w
+	d := w - c.mean
+	c.mean += d / float64(c.n)
+	c.m2 += d * (w - c.mean)
+
+	if c.crossing == 0 && c.n >= 2 && c.m2 > 0 {
+		t := math.Abs(c.sum) / (c.Sd() * math.Sqrt(float64(c.n)))
+		b := 3 * cusumA[c.Level]
+		// the t quantile is beyond b, so only then is Pt() needed
+		if t > b && Pt(t, float64(c.n-1)) < 0.5*math.Erfc(b/math.Sqrt2) {
+			c.crossing = c.n
+		}
+	}
+
+	if c.maxHist > 0 {
+		if len(c.hist) == 2*c.maxHist {
+			copy(c.hist, c.hist[c.maxHist:])
+			c.hist = c.hist[:c.maxHist]
+		}
+		c.hist = append(c.hist, w)
+	}
+}
+
+// N(): the number of recursive residuals so far.
+func (c *CusumTracker) N() int {
+	return c.n
+}
+
+// OnlineCrossing(): the first r, from 1, at which the CUSUM of the
+// residuals so far left its boundary, or 0 if it never has.
+func (c *CusumTracker) OnlineCrossing() int {
+	return c.crossing
+}
+
+// Sd(): s, the standard deviation of the recursive residuals so far; an
+// estimate of sigma that, unlike the residual standard error, is not
+// pulled up by a break.
+func (c *CusumTracker) Sd() float64 {
+	if c.n < 2 {
+		return math.NaN()
+	}
+	return math.Sqrt(c.m2 / float64(c.n-1))
+}
+
+// history(): the residuals the tests are of, and the number, from 1,
+// of the first of them among all those added.
+func (c *CusumTracker) history() ([]float64, int) {
+	if c.maxHist == 0 {
+		panic("CusumTracker error: the full path of a test needs KeepHistory()")
+	}
+	w := c.hist
+	if len(w) > c.maxHist {
+		w = w[len(w)-c.maxHist:]
+	}
+	return w, c.n - len(w) + 1
+}
+
+// CusumTest is the path of a CUSUM statistic and its boundaries, one
+// entry for each recursive residual.
+type CusumTest struct {
+	Title string
+	Level CusumLevel
+	N     int
+	Start int // the number, from 1, of the first residual tested
+	Path  []float64
+	Lower []float64
+	Upper []float64
+
+	// Crossing is the first r, from 1 at Start, at which Path left
+	// the boundaries, or 0 if it never did; Reject is Crossing > 0.
+	Crossing int
+	Reject   bool
+}
+
+// Cusum(): the CUSUM test of the residuals kept by KeepHistory(): all
+// of them, until there are more than its max, and then the last max.
+// It needs at least 2.
+func (c *CusumTracker) Cusum() *CusumTest {
+	hist, start := c.history()
+	n := len(hist)
+	t := &CusumTest{Title: "CUSUM test", Level: c.Level, N: n, Start: start}
+	s := sdOf(hist)
+	if n < 2 || s == 0 {
+		return t
+	}
+	a := cusumA[c.Level]
+	rootn := math.Sqrt(float64(n))
+	sum := 0.0
+	for r, w := range hist {
+		sum += w
+		b := a * (rootn + 2*float64(r+1)/rootn)
+		t.add(sum/s, -b, b)
+	}
+	return t
+}
+
+// CusumSq(): the CUSUM-of-squares test of the residuals kept, as for
+// Cusum().
+func (c *CusumTracker) CusumSq() *CusumTest {
+	hist, start := c.history()
+	n := len(hist)
+	t := &CusumTest{Title: "CUSUM of squares test", Level: c.Level, N: n, Start: start}
+	total := 0.0
+	for _, w := range hist {
+		total += w * w
+	}
+	if n < 2 || total == 0 {
+		return t
+	}
+	c0 := cusumSqK[c.Level] * math.Sqrt(2/float64(n))
+	sumSq := 0.0
+	for r, w := range hist {
+		sumSq += w * w
+		mid := float64(r+1) / float64(n)
+		t.add(sumSq/total, mid-c0, mid+c0)
+	}
+	return t
+}
+
+// sdOf(): the standard deviation of w, by Welford's update.
+func sdOf(w []float64) float64 {
+	if len(w) < 2 {
+		return math.NaN()
+	}
+	mean, m2 := 0.0, 0.0
+	for i, v := range w {
+		d := v - mean
+		mean += d / float64(i+1)
+		m2 += d * (v - mean)
+	}
+	return math.Sqrt(m2 / float64(len(w)-1))
+}
+
+func (t *CusumTest) add(v float64, lower float64, upper float64) {
+	t.Path = append(t.Path, v)
+	t.Lower = append(t.Lower, lower)
+	t.Upper = append(t.Upper, upper)
+	if t.Crossing == 0 && (v < lower || v > upper) {
+		t.Crossing = len(t.Path)
+		t.Reject = true
+	}
+}
+
+func (t *CusumTest) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s of %d recursive residuals", t.Title, t.N)
+	if t.Start > 1 {
+		fmt.Fprintf(&b, " from residual %d", t.Start)
+	}
+	fmt.Fprintf(&b, ", at the %v level: ", float64(t.Level))
+	if t.Reject {
+		fmt.Fprintf(&b, "unstable; the boundary was first crossed at residual %d\n", t.Crossing)
+	} else {
+		fmt.Fprintf(&b, "no evidence of instability\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,225 @@
+package lsq
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestRecursiveResiduals(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+
+	// Fuel_Pop ~ Tax + Income + DLic, optionally weighted by Popn
+	xcols := []int{2, 4, 7}
+	p := len(xcols) + 1
+
+	// (y - x'b) / sqrt(1 + x'(X'X)^-1 x), from the rows before row t
+	direct := func(t int, weighted bool) float64 {
+		xtx := make([][]float64, p)
+		xty := make([]float64, p)
+		for a := range xtx {
+			xtx[a] = make([]float64, p)
+		}
+		xOf := func(i int) []float64 {
+			x := []float64{1}
+			for _, j := range xcols {
+				x = append(x, df.Rows[i][j])
+			}
+			return x
+		}
+		wOf := func(i int) float64 {
+			if weighted {
+				return df.Rows[i][1]
+			}
+			return 1
+		}
+		for i := 0; i < t; i++ {
+			x := xOf(i)
+			for a := 0; a < p; a++ {
+				xty[a] += wOf(i) * x[a] * df.Rows[i][last]
+				for b := 0; b < p; b++ {
+					xtx[a][b] += wOf(i) * x[a] * x[b]
+				}
+			}
+		}
+		inv := invert(xtx)
+		x := xOf(t)
+		e := df.Rows[t][last]
+		h := 0.0
+		for a := 0; a < p; a++ {
+			for b := 0; b < p; b++ {
+				e -= x[a] * inv[a][b] * xty[b]
+				h += x[a] * inv[a][b] * x[b]
+			}
+		}
+		return math.Sqrt(wOf(t)) * e / math.Sqrt(1+wOf(t)*h)
+	}
+
+	cv.Convey("Given the fuelcons.dat data, IncludRecursive() should give the recursive residual of each row after the first p", t, func() {
+		for _, weighted := range []bool{false, true} {
+			m := NewMillerLSQ(len(xcols), 1)
+			xrow := make([]float64, len(xcols))
+			resid := make([]float64, 1)
+			for i := range df.Rows {
+				for k, j := range xcols {
+					xrow[k] = df.Rows[i][j]
+				}
+				w := 1.0
+				if weighted {
+					w = df.Rows[i][1]
+				}
+				m.IncludRecursive(w, xrow, df.Rows[i][last:], NAN_OMIT_ROW, resid)
+				if i < p {
+					cv.So(math.IsNaN(resid[0]), cv.ShouldBeTrue)
+					continue
+				}
+				want := direct(i, weighted)
+				cv.So(resid[0], cv.ShouldAlmostEqual, want, 1e-8*math.Abs(want)+1e-10)
+			}
+		}
+	})
+
+	cv.Convey("After SetMeanSd(), the recursive residuals should still be in the units of y", t, func() {
+		plain := NewMillerLSQ(1, 1)
+		scaled := NewMillerLSQ(1, 1)
+		scaled.SetMeanSd([]float64{5}, []float64{2}, []float64{500}, []float64{100})
+		r1 := make([]float64, 1)
+		r2 := make([]float64, 1)
+		for i := range df.Rows {
+			x := []float64{df.Rows[i][2]}
+			plain.IncludRecursive(1, x, df.Rows[i][last:], NAN_OMIT_ROW, r1)
+			x = []float64{df.Rows[i][2]}
+			scaled.IncludRecursive(1, x, df.Rows[i][last:], NAN_OMIT_ROW, r2)
+			cv.So(math.IsNaN(r2[0]), cv.ShouldEqual, math.IsNaN(r1[0]))
+			if !math.IsNaN(r1[0]) {
+				cv.So(r2[0], cv.ShouldAlmostEqual, r1[0], 1e-8*math.Abs(r1[0]))
+			}
+		}
+	})
+
+	cv.Convey("Omitted, zero-weight and downdating rows should have no recursive residual", t, func() {
+		m := NewMillerLSQ(1, 1)
+		resid := make([]float64, 1)
+		for i := 0; i < 5; i++ {
+			m.IncludRecursive(1, []float64{float64(i)}, []float64{float64(i * i)}, NAN_OMIT_ROW, resid)
+		}
+		cv.So(math.IsNaN(resid[0]), cv.ShouldBeFalse)
+		m.IncludRecursive(1, []float64{math.NaN()}, []float64{1}, NAN_OMIT_ROW, resid)
+		cv.So(math.IsNaN(resid[0]), cv.ShouldBeTrue)
+		m.IncludRecursive(0, []float64{1}, []float64{1}, NAN_OMIT_ROW, resid)
+		cv.So(math.IsNaN(resid[0]), cv.ShouldBeTrue)
+		m.IncludRecursive(-1, []float64{4}, []float64{16}, NAN_OMIT_ROW, resid)
+		cv.So(math.IsNaN(resid[0]), cv.ShouldBeTrue)
+	})
+}
+
+func TestCusum(t *testing.T) {
+
+	cv.Convey("The CUSUM and CUSUM-of-squares paths and boundaries should follow Brown, Durbin and Evans", t, func() {
+		c := NewCusumTracker(CUSUM_LEVEL_5)
+		c.KeepHistory(10)
+		for _, w := range []float64{1, -1, math.NaN(), 2, 0} {
+			c.Add(w)
+		}
+		cv.So(c.N(), cv.ShouldEqual, 4)
+		// mean 1/2, sum of squared deviations 5
+		s := math.Sqrt(5.0 / 3)
+		cv.So(c.Sd(), cv.ShouldAlmostEqual, s, 1e-12)
+
+		cu := c.Cusum()
+		cv.So(EpsSliceEqual(cu.Path, []float64{1 / s, 0, 2 / s, 2 / s}, 1e-12), cv.ShouldBeTrue)
+		cv.So(cu.Upper[0], cv.ShouldAlmostEqual, 0.948*(2+2.0/2), 1e-12)
+		cv.So(cu.Lower[3], cv.ShouldAlmostEqual, -0.948*(2+8.0/2), 1e-12)
+		cv.So(cu.Reject, cv.ShouldBeFalse)
+
+		sq := c.CusumSq()
+		cv.So(EpsSliceEqual(sq.Path, []float64{1.0 / 6, 2.0 / 6, 1, 1}, 1e-12), cv.ShouldBeTrue)
+		cv.So(sq.Upper[1], cv.ShouldAlmostEqual, 0.5+1.358*math.Sqrt(0.5), 1e-12)
+	})
+
+	// y = 1 + 2x + e, with the slope or the error variance changing half
+	// way through, if asked
+	run := func(slope2 float64, sd2 float64) *CusumTracker {
+		rng := rand.New(rand.NewSource(1))
+		m := NewMillerLSQ(1, 1)
+		c := NewCusumTracker(CUSUM_LEVEL_5)
+		c.KeepHistory(200)
+		resid := make([]float64, 1)
+		for i := 0; i < 200; i++ {
+			x := rng.Float64() * 10
+			slope, sd := 2.0, 1.0
+			if i >= 100 {
+				slope, sd = slope2, sd2
+			}
+			y := 1 + slope*x + sd*rng.NormFloat64()
+			m.IncludRecursive(1, []float64{x}, []float64{y}, NAN_OMIT_ROW, resid)
+			c.Add(resid[0])
+		}
+		return c
+	}
+
+	cv.Convey("A stable regression should pass both tests", t, func() {
+		c := run(2, 1)
+		cv.So(c.N(), cv.ShouldEqual, 198)
+		cv.So(c.Cusum().Reject, cv.ShouldBeFalse)
+		cv.So(c.CusumSq().Reject, cv.ShouldBeFalse)
+		cv.So(c.OnlineCrossing(), cv.ShouldEqual, 0)
+	})
+
+	cv.Convey("A change of slope should be caught by the CUSUM test, after the change", t, func() {
+		c := run(2.5, 1)
+		cu := c.Cusum()
+		fmt.Printf("\n%s", cu)
+		cv.So(cu.Reject, cv.ShouldBeTrue)
+		cv.So(cu.Crossing > 98, cv.ShouldBeTrue)
+
+		// and, as the rows arrive, by the running check
+		cv.So(c.OnlineCrossing() > 98, cv.ShouldBeTrue)
+	})
+
+	cv.Convey("A change of variance should be caught by the CUSUM-of-squares test", t, func() {
+		c := run(2, 3)
+		sq := c.CusumSq()
+		fmt.Printf("\n%s", sq)
+		cv.So(sq.Reject, cv.ShouldBeTrue)
+	})
+
+	cv.Convey("Sd() should keep its precision far from zero, and only the last residuals should be kept", t, func() {
+		c := NewCusumTracker(CUSUM_LEVEL_5)
+		c.KeepHistory(3)
+		for i := 0; i < 10; i++ {
+			c.Add(1e9 + float64(i%2))
+		}
+		cv.So(c.Sd(), cv.ShouldAlmostEqual, math.Sqrt(25.0/90), 1e-9)
+
+		cu := c.Cusum()
+		cv.So(cu.N, cv.ShouldEqual, 3)
+		cv.So(cu.Start, cv.ShouldEqual, 8)
+		cv.So(len(cu.Path), cv.ShouldEqual, 3)
+
+		// without KeepHistory() there is no path to give
+		panicked := func() (p bool) {
+			defer func() { p = recover() != nil }()
+			NewCusumTracker(CUSUM_LEVEL_5).Cusum()
+			return
+		}()
+		cv.So(panicked, cv.ShouldBeTrue)
+	})
+
+	cv.Convey("Levels other than 0.10, 0.05 and 0.01 should panic", t, func() {
+		panicked := func() (p bool) {
+			defer func() { p = recover() != nil }()
+			NewCusumTracker(0.2)
+			return
+		}()
+		cv.So(panicked, cv.ShouldBeTrue)
+	})
+}
@@ -2,11 +2,14 @@ package lsq
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
 // read data file utility
@@ -59,26 +62,28 @@ func main() {
 }
 */
 
+// LineToFloatSlice(): the whitespace-separated fields of line as
+// numbers, with NaN for any field that is not one, such as a row name.
 func LineToFloatSlice(line string) []float64 {
 	slc := LineToStringSlice(line)
-	var z float64
-	var a []float64
+	a := make([]float64, len(slc))
 	for i := range slc {
-		n, err := fmt.Sscanf(slc[i], "%f", &z)
-		if n == 0 {
-			a = append(a, math.NaN())
-			continue
+		z, err := strconv.ParseFloat(slc[i], 64)
+		if err != nil && !isRangeError(err) {
+			z = math.NaN()
 		}
-		//fmt.Println("n", n)
-		if err != nil {
-			fmt.Printf("ERROR: %v\n", err)
-			continue
-		}
-		a = append(a, z)
+		a[i] = z
 	}
 	return a
 }
 
+// isRangeError(): true if ParseFloat() failed only because the number
+// is too big or too small, in which case it gives +/-Inf or 0.
+func isRangeError(err error) bool {
+	ne, ok := err.(*strconv.NumError)
+	return ok && ne.Err == strconv.ErrRange
+}
+
 var wordRegex = regexp.MustCompile(`(\S+)+`)
 
 var firstWordRegex = regexp.MustCompile(`(\S+)`)
@@ -124,15 +129,15 @@ func readData(fname string) (*DataFrame, error) {
 
 	f, err := os.Open(fname)
 	if err != nil {
-		panic(fmt.Sprintf("error opening file '%s': %s", fname, err))
+		return nil, errors.New(fmt.Sprintf("error opening file '%s': %s", fname, err))
 	}
 	defer f.Close()
 	df := &DataFrame{}
 	r := bufio.NewReader(f)
 
 	line, err := r.ReadString(10)
-	if err != nil {
-		panic(err)
+	if err != nil && (err != io.EOF || line == "") {
+		return nil, errors.New(fmt.Sprintf("error reading the header of '%s': %s", fname, err))
 	}
 	df.Colnames = LineToStringSlice(line)
 	df.Colnames = NormalizeNameLengths(df.Colnames)
@@ -143,9 +148,10 @@ func readData(fname string) (*DataFrame, error) {
 	for {
 		line, err := r.ReadString(10) // 0x0A separator = newline
 		if err == io.EOF {
-			// do something here
-			// fmt.Printf("line on eof: '%s'\n", line)
-			break
+			// a last line without a newline still counts
+			if strings.TrimSpace(line) == "" {
+				break
+			}
 		} else if err != nil {
 			return nil, err
 		}
@@ -162,6 +168,9 @@ func readData(fname string) (*DataFrame, error) {
 			rowname = ""
 		}
 		df.Rownames = append(df.Rownames, rowname)
+		if err == io.EOF {
+			break
+		}
 	}
 
 	return df, nil
@@ -0,0 +1,369 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+)
+
+// F tests between nested models, and R-style drop1() and add1() tables.
+//
+// Like Stepwise(), these never look at the data again: the variables of
+// a model are brought to the front of m.Vorder with Vmove(), and the
+// residual sums of squares of the models compared are read off m.Rss.
+// m.Vorder is restored before returning.
+
+// FTest compares a reduced model with a full model that contains it.
+type FTest struct {
+	RssReduced float64
+	RssFull    float64
+	DfReduced  float64 // residual degrees of freedom of the reduced model
+	DfFull     float64 // residual degrees of freedom of the full model
+
+	Df     float64 // DfReduced - DfFull, the number of parameters tested
+	SumSq  float64 // RssReduced - RssFull
+	F      float64 // (SumSq/Df) / (RssFull/DfFull)
+	Pvalue float64
+}
+
+// Term is a group of x-variables that enter or leave a model together,
+// such as the dummy columns coding one factor.
+type Term struct {
+	Name string
+	Vars []int // x-variable numbers, 1-based
+
+	// Factors, used only by Type II Anova(), names the factors that
+	// make up an interaction term, such as {"a", "b"} for a:b, so that
+	// it is known to contain the terms a and b. Empty means the term
+	// is just the factor Name.
+	Factors []string
+}
+
+// SingleTerms(): one Term for each of vars, named x1, x2, ...
+func SingleTerms(vars []int) []Term {
+	terms := make([]Term, len(vars))
+	for i, v := range vars {
+		terms[i] = Term{Name: fmt.Sprintf("x%d", v), Vars: []int{v}}
+	}
+	return terms
+}
+
+// TermTest is one row of a TermTable.
+type TermTest struct {
+	Term    Term
+	Df      float64 // parameters dropped or added
+	SumSq   float64 // the change in the residual sum of squares
+	Rss     float64 // residual sum of squares after dropping or adding the term
+	DfResid float64 // residual degrees of freedom after dropping or adding the term
+	F       float64
+	Pvalue  float64
+}
+
+// TermTable is returned by Drop1() and Add1().
+type TermTable struct {
+	Title   string
+	Wycol   int
+	Vars    []int   // the x-variables of the base model
+	Rss     float64 // residual sum of squares of the base model
+	DfResid float64 // residual degrees of freedom of the base model
+	Rows    []*TermTest
+}
+
+// NestedFTest(): the F test of the model with the x-variables in reduced
+// against the model with those in full, for the wycol-th y-target. Every
+// variable of reduced must also be in full. The intercept, if any, is in
+// both models.
+func (m *MillerLSQ) NestedFTest(reduced []int, full []int, wycol int) (res *FTest, err error) {
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("NestedFTest() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	err = m.checkSubset(full)
+	if err != nil {
+		return nil, err
+	}
+	err = m.checkSubset(reduced)
+	if err != nil {
+		return nil, err
+	}
+	if len(reduced) >= len(full) || !containsAll(full, reduced) {
+		return nil, errors.New(fmt.Sprintf("NestedFTest() error: %v is not a proper subset of %v", reduced, full))
+	}
+
+	saved := m.startShuffle()
+	defer func() {
+		rerr := m.restoreOrder(saved)
+		if err == nil && rerr != nil {
+			res, err = nil, rerr
+		}
+	}()
+
+	err = m.moveSubsetToFront(full)
+	if err != nil {
+		return nil, err
+	}
+	// reduced is within the front block, so this only shuffles that block
+	err = m.Reorder(reduced, m.nconst())
+	if err != nil {
+		return nil, err
+	}
+	c := m.nconst()
+	res = newFTest(
+		m.rssFirst(wycol, c+len(reduced)), m.ResidualDf(c+len(reduced)),
+		m.rssFirst(wycol, c+len(full)), m.ResidualDf(c+len(full)))
+	return res, nil
+}
+
+func newFTest(rssReduced float64, dfReduced float64, rssFull float64, dfFull float64) *FTest {
+	t := &FTest{
+		RssReduced: rssReduced,
+		RssFull:    rssFull,
+		DfReduced:  dfReduced,
+		DfFull:     dfFull,
+		Df:         dfReduced - dfFull,
+		SumSq:      rssReduced - rssFull,
+	}
+	t.F, t.Pvalue = fStat(t.SumSq, t.Df, rssFull, dfFull)
+	return t
+}
+
+// fStat(): the F statistic for a change in the residual sum of squares of
+// sumSq on df degrees of freedom, against the residual rss on dfResid, and
+// its p-value.
+func fStat(sumSq float64, df float64, rss float64, dfResid float64) (f float64, pvalue float64) {
+	f = (sumSq / df) / (rss / dfResid)
+	return f, Pf(f, df, dfResid)
+}
+
+// Drop1(): as R's drop1(fit, test="F"), for the model of the wycol-th
+// y-target on the x-variables in vars (nil for every x-variable). Each
+// term is moved to the end of the model, so that it is tested last, and
+// the table gives the change from dropping it. With terms nil, each
+// variable of vars is a term by itself.
+func (m *MillerLSQ) Drop1(wycol int, vars []int, terms []Term) (res *TermTable, err error) {
+	if vars == nil {
+		vars = Seq(m.Nxvar)
+	}
+	if terms == nil {
+		terms = SingleTerms(vars)
+	}
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("Drop1() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	err = m.checkSubset(vars)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range terms {
+		err = m.checkSubset(t.Vars)
+		if err != nil {
+			return nil, err
+		}
+		if len(t.Vars) == 0 || !containsAll(vars, t.Vars) {
+			return nil, errors.New(fmt.Sprintf("Drop1() error: term %s%v is not in the model %v", t.Name, t.Vars, vars))
+		}
+	}
+
+	saved := m.startShuffle()
+	defer func() {
+		rerr := m.restoreOrder(saved)
+		if err == nil && rerr != nil {
+			res, err = nil, rerr
+		}
+	}()
+
+	err = m.moveSubsetToFront(vars)
+	if err != nil {
+		return nil, err
+	}
+	c := m.nconst()
+	k := len(vars)
+	res = &TermTable{
+		Title:   "Single term deletions",
+		Wycol:   wycol,
+		Vars:    DeepCopyInts(vars),
+		Rss:     m.rssFirst(wycol, c+k),
+		DfResid: m.ResidualDf(c + k),
+	}
+	for _, t := range terms {
+		// the rest of the model to the front leaves the term last
+		err = m.Reorder(without(vars, t.Vars), c)
+		if err != nil {
+			return nil, err
+		}
+		size := len(t.Vars)
+		row := &TermTest{
+			Term:    t,
+			Df:      float64(size),
+			Rss:     m.rssFirst(wycol, c+k-size),
+			DfResid: m.ResidualDf(c + k - size),
+		}
+		row.SumSq = row.Rss - res.Rss
+		row.F, row.Pvalue = fStat(row.SumSq, row.Df, res.Rss, res.DfResid)
+		res.Rows = append(res.Rows, row)
+	}
+	return res, nil
+}
+
+// Add1(): as R's add1(fit, scope, test="F"), for the model of the wycol-th
+// y-target on the x-variables in vars. Each term is moved in right after
+// the model, and the table gives the change from adding it. With terms
+// nil, each x-variable not in vars is a candidate by itself.
+func (m *MillerLSQ) Add1(wycol int, vars []int, terms []Term) (res *TermTable, err error) {
+	if terms == nil {
+		terms = SingleTerms(without(Seq(m.Nxvar), vars))
+	}
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("Add1() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	err = m.checkSubset(vars)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range terms {
+		err = m.checkSubset(append(DeepCopyInts(vars), t.Vars...))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Add1() error: term %s%v cannot be added to the model %v: %s", t.Name, t.Vars, vars, err))
+		}
+		if len(t.Vars) == 0 {
+			return nil, errors.New(fmt.Sprintf("Add1() error: term %s has no variables", t.Name))
+		}
+	}
+
+	saved := m.startShuffle()
+	defer func() {
+		rerr := m.restoreOrder(saved)
+		if err == nil && rerr != nil {
+			res, err = nil, rerr
+		}
+	}()
+
+	err = m.moveSubsetToFront(vars)
+	if err != nil {
+		return nil, err
+	}
+	c := m.nconst()
+	k := len(vars)
+	res = &TermTable{
+		Title:   "Single term additions",
+		Wycol:   wycol,
+		Vars:    DeepCopyInts(vars),
+		Rss:     m.rssFirst(wycol, c+k),
+		DfResid: m.ResidualDf(c + k),
+	}
+	for _, t := range terms {
+		err = m.Reorder(t.Vars, c+k)
+		if err != nil {
+			return nil, err
+		}
+		size := len(t.Vars)
+		row := &TermTest{
+			Term:    t,
+			Df:      float64(size),
+			Rss:     m.rssFirst(wycol, c+k+size),
+			DfResid: m.ResidualDf(c + k + size),
+		}
+		row.SumSq = res.Rss - row.Rss
+		row.F, row.Pvalue = fStat(row.SumSq, row.Df, row.Rss, row.DfResid)
+		res.Rows = append(res.Rows, row)
+	}
+	return res, nil
+}
+
+// Drop1(): as MillerLSQ.Drop1(), with each variable named from
+// mod.Xnames when terms is nil.
+func (mod *Model) Drop1(wycol int, vars []int, terms []Term) (*TermTable, error) {
+	if vars == nil {
+		vars = Seq(mod.Nxvar)
+	}
+	if terms == nil {
+		terms = mod.SingleTerms(vars)
+	}
+	return mod.MillerLSQ.Drop1(wycol, vars, terms)
+}
+
+// Add1(): as MillerLSQ.Add1(), with each candidate named from
+// mod.Xnames when terms is nil.
+func (mod *Model) Add1(wycol int, vars []int, terms []Term) (*TermTable, error) {
+	if terms == nil {
+		terms = mod.SingleTerms(without(Seq(mod.Nxvar), vars))
+	}
+	return mod.MillerLSQ.Add1(wycol, vars, terms)
+}
+
+// SingleTerms(): as the package level SingleTerms(), but named
+// from mod.Xnames.
+func (mod *Model) SingleTerms(vars []int) []Term {
+	terms := SingleTerms(vars)
+	for i := range terms {
+		v := terms[i].Vars[0]
+		if v >= 1 && v-1 < len(mod.Xnames) {
+			terms[i].Name = mod.Xnames[v-1]
+		}
+	}
+	return terms
+}
+
+// startShuffle(): get ready to move variables around with Vmove(),
+// returning the current order for restoreOrder().
+func (m *MillerLSQ) startShuffle() []int {
+	if !m.Tol_set {
+		m.Tolset(1e-12)
+	}
+	for k := range m.Rhs {
+		if !m.Rss_set[k] {
+			m.SS(k)
+		}
+	}
+	return DeepCopyInts(m.Vorder)
+}
+
+// containsAll(): true if every element of sub is in set.
+func containsAll(set []int, sub []int) bool {
+	in := make(map[int]bool, len(set))
+	for _, v := range set {
+		in[v] = true
+	}
+	for _, v := range sub {
+		if !in[v] {
+			return false
+		}
+	}
+	return true
+}
+
+// without(): the elements of set that are not in drop, in order.
+func without(set []int, drop []int) []int {
+	out := make(map[int]bool, len(drop))
+	for _, v := range drop {
+		out[v] = true
+	}
+	res := make([]int, 0, len(set))
+	for _, v := range set {
+		if !out[v] {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func (t *FTest) String() string {
+	s := fmt.Sprintf("%10s  %14s  %6s  %14s  %10s  %10s\n", "Res.Df", "RSS", "Df", "Sum of Sq", "F", "Pr(>F)")
+	s += fmt.Sprintf("%10.0f  %14.6g\n", t.DfReduced, t.RssReduced)
+	s += fmt.Sprintf("%10.0f  %14.6g  %6.0f  %14.6g  %10.4g  %10.4g\n", t.DfFull, t.RssFull, t.Df, t.SumSq, t.F, t.Pvalue)
+	return s
+}
+
+func (r *TermTable) String() string {
+	names := []string{"", "<none>"}
+	for _, row := range r.Rows {
+		names = append(names, row.Term.Name)
+	}
+	names = NormalizeNameLengths(names)
+
+	s := fmt.Sprintf("%s for y-target %d\n", r.Title, r.Wycol)
+	s += fmt.Sprintf("%s  %6s  %14s  %14s  %10s  %10s\n", names[0], "Df", "Sum of Sq", "RSS", "F value", "Pr(>F)")
+	s += fmt.Sprintf("%s  %6s  %14s  %14.6g\n", names[1], "", "", r.Rss)
+	for i, row := range r.Rows {
+		s += fmt.Sprintf("%s  %6.0f  %14.6g  %14.6g  %10.4g  %10.4g\n", names[i+2], row.Df, row.SumSq, row.Rss, row.F, row.Pvalue)
+	}
+	return s
+}
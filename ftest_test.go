@@ -0,0 +1,112 @@
+package lsq
+
+import (
+	"fmt"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestNestedFTest(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+	xnames := df.Colnames[1:last]
+	mod := NewModel(xnames, df.Colnames[last:])
+	for i := range df.Rows {
+		mod.Includ(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+	}
+	m := mod.MillerLSQ
+	origVorder := DeepCopyInts(m.Vorder)
+
+	rssOf := func(vars []int) float64 {
+		fit, err := m.FitSubset(vars, 0)
+		if err != nil {
+			panic(err)
+		}
+		return fit.Rss
+	}
+
+	// Fuel_Pop ~ Tax + Income + RoadMls + DLic
+	model := []int{2, 4, 5, 7}
+	n := float64(len(df.Rows))
+
+	cv.Convey("Given the fuelcons.dat data, NestedFTest() should compare the residual sums of squares of the two models", t, func() {
+		ft, err := m.NestedFTest([]int{5, 7}, model, 0)
+		cv.So(err, cv.ShouldBeNil)
+		fmt.Printf("\n%s\n", ft)
+
+		rssR, rssF := rssOf([]int{5, 7}), rssOf(model)
+		cv.So(ft.RssReduced, cv.ShouldAlmostEqual, rssR, 1e-6)
+		cv.So(ft.RssFull, cv.ShouldAlmostEqual, rssF, 1e-6)
+		cv.So(ft.Df, cv.ShouldEqual, 2)
+		cv.So(ft.DfFull, cv.ShouldEqual, n-5)
+		cv.So(ft.F, cv.ShouldAlmostEqual, ((rssR-rssF)/2)/(rssF/(n-5)), 1e-8)
+		cv.So(ft.Pvalue, cv.ShouldAlmostEqual, Pf(ft.F, 2, n-5), 1e-12)
+		cv.So(IntSliceEqual(m.Vorder, origVorder), cv.ShouldBeTrue)
+
+		_, err = m.NestedFTest([]int{1, 7}, model, 0)
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = m.NestedFTest(model, model, 0)
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+
+	cv.Convey("Drop1() should give, for each variable, the F statistic that is the square of its t statistic", t, func() {
+		tab, err := mod.Drop1(0, model, nil)
+		cv.So(err, cv.ShouldBeNil)
+		fmt.Printf("\n%s\n", tab)
+
+		fit, err := m.FitSubset(model, 0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(tab.Rss, cv.ShouldAlmostEqual, fit.Rss, 1e-6)
+		cv.So(len(tab.Rows), cv.ShouldEqual, len(model))
+		for i, row := range tab.Rows {
+			v := model[i]
+			cv.So(row.Term.Name, cv.ShouldEqual, xnames[v-1])
+			cv.So(row.Rss, cv.ShouldAlmostEqual, rssOf(without(model, []int{v})), 1e-6)
+			cv.So(row.F, cv.ShouldAlmostEqual, fit.Tvalue[i+1]*fit.Tvalue[i+1], 1e-6)
+			cv.So(row.Pvalue, cv.ShouldAlmostEqual, fit.Pvalue[i+1], 1e-8)
+		}
+		cv.So(IntSliceEqual(m.Vorder, origVorder), cv.ShouldBeTrue)
+	})
+
+	cv.Convey("Drop1() of a term of several variables should agree with NestedFTest()", t, func() {
+		terms := []Term{{Name: "Tax+Income", Vars: []int{2, 4}}, {Name: "DLic", Vars: []int{7}}}
+		tab, err := m.Drop1(0, model, terms)
+		cv.So(err, cv.ShouldBeNil)
+		ft, err := m.NestedFTest([]int{5, 7}, model, 0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(tab.Rows[0].Df, cv.ShouldEqual, 2)
+		cv.So(tab.Rows[0].F, cv.ShouldAlmostEqual, ft.F, 1e-8)
+		cv.So(tab.Rows[0].Pvalue, cv.ShouldAlmostEqual, ft.Pvalue, 1e-10)
+
+		_, err = m.Drop1(0, model, []Term{{Name: "Popn", Vars: []int{1}}})
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+
+	cv.Convey("Add1() should give the change from adding each candidate, tested against the larger model", t, func() {
+		base := []int{7}
+		tab, err := mod.Add1(0, base, nil)
+		cv.So(err, cv.ShouldBeNil)
+		fmt.Printf("\n%s\n", tab)
+
+		cv.So(len(tab.Rows), cv.ShouldEqual, 6)
+		cv.So(tab.Rss, cv.ShouldAlmostEqual, rssOf(base), 1e-6)
+		for _, row := range tab.Rows {
+			bigger := append(DeepCopyInts(base), row.Term.Vars...)
+			cv.So(row.Term.Name, cv.ShouldEqual, xnames[row.Term.Vars[0]-1])
+			cv.So(row.Rss, cv.ShouldAlmostEqual, rssOf(bigger), 1e-6)
+			ft, err := m.NestedFTest(base, bigger, 0)
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(row.F, cv.ShouldAlmostEqual, ft.F, 1e-8)
+			cv.So(row.DfResid, cv.ShouldEqual, n-3)
+		}
+		cv.So(IntSliceEqual(m.Vorder, origVorder), cv.ShouldBeTrue)
+
+		_, err = m.Add1(0, base, []Term{{Name: "DLic", Vars: []int{7}}})
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,261 @@
+package lsq
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Influence diagnostics, from a second pass over the rows.
+//
+// With e = y - x'b the residual of a row of weight w, h = w x'(X'WX)^-1 x
+// its leverage, from Hdiag(), s the residual standard error, and p the
+// number of coefficients:
+//
+//	standardized:   r = sqrt(w) e / (s sqrt(1-h))
+//	studentized:    t = r sqrt((n-p-1) / (n-p-r^2))      (s from the fit without the row)
+//	Cook's D:       r^2 h / (p (1-h))
+//	DFFITS:         t sqrt(h / (1-h))
+//	DFBETAS_j:      (b_j - b_j without the row) / (s without the row * sqrt((X'WX)^-1_jj))
+//
+// using b - b(without the row) = (X'WX)^-1 x w e / (1-h), so that
+// nothing needs refitting. These agree with R's rstandard(), rstudent(),
+// cooks.distance(), dffits() and dfbetas(). A row with h = 1 is fit
+// exactly whatever its y, and these are NaN for it.
+
+// Influence holds the diagnostics of one row.
+type Influence struct {
+	Row          int64   // the index of the row in the RowSource, from 0
+	Weight       float64 // as given in the row
+	Fitted       float64 // x'b
+	Resid        float64 // y - x'b
+	Leverage     float64 // h, the diagonal of the hat matrix
+	Standardized float64 // the internally studentized residual
+	Studentized  float64 // the externally studentized residual
+	CooksD       float64
+	Dffits       float64
+	Dfbetas      []float64 // one per coefficient, in the order of FitResult.Coef
+}
+
+// copy(): a copy of inf that shares nothing with it.
+func (inf *Influence) copy() *Influence {
+	c := *inf
+	c.Dfbetas = DeepCopy(inf.Dfbetas)
+	return &c
+}
+
+// Influence(): Fit() for the wycol-th y-target, and then a pass over
+// src, with cols saying where x, y and the weight are, as for
+// RobustFit(), that calls f with the Influence of each row that was
+// included. The Influence given to f is reused for the next row, so f
+// must copy anything it wants to keep. An error from f stops the pass.
+// The fit is returned, for its names and coefficients.
+func (m *MillerLSQ) Influence(wycol int, src RowSource, cols Columns, f func(inf *Influence) error) (*FitResult, error) {
+	fit, bread, err := m.robustStart(wycol)
+	if err != nil {
+		return nil, err
+	}
+	p := len(fit.Coef)
+	dfResid := fit.DfResid
+	s := fit.Sigma
+	// fit, and so s, are on the normalized scale if UseMeanSd; the
+	// residuals are given back in the units of y
+	yscale := 1.0
+	if m.UseMeanSd && m.Ysd[wycol] != 0.0 {
+		yscale = m.Ysd[wycol]
+	}
+
+	xfull := make([]float64, p)
+	xpos := make([]float64, p)
+	z := make([]float64, p)
+	ynorm := make([]float64, m.Nyvar)
+	inf := &Influence{Dfbetas: make([]float64, p)}
+	err = m.eachRow(src, cols, func(i int64, weight float64, x []float64, y []float64, row []float64) error {
+		if weight < 0 {
+			return errors.New("Influence() error: rows with negative weights, downdating others, cannot be used")
+		}
+		m.normalize(x, y, xfull, ynorm)
+		fitted := 0.0
+		for j, b := range fit.Coef {
+			fitted += b * xfull[j]
+		}
+		e := ynorm[wycol] - fitted
+
+		m.toPositions(xfull, xpos)
+		h, err := m.Hdiag(xpos, p)
+		if err != nil {
+			return err
+		}
+		h *= weight
+
+		inf.Row = i
+		inf.Weight = weight
+		inf.Fitted = fitted * yscale
+		if m.UseMeanSd {
+			inf.Fitted += m.Ymean[wycol]
+		}
+		inf.Resid = e * yscale
+		inf.Leverage = h
+
+		if h >= 1-1e-10 {
+			inf.Standardized = math.NaN()
+			inf.Studentized = math.NaN()
+			inf.CooksD = math.NaN()
+			inf.Dffits = math.NaN()
+			for j := range inf.Dfbetas {
+				inf.Dfbetas[j] = math.NaN()
+			}
+			return f(inf)
+		}
+
+		r := math.Sqrt(weight) * e / (s * math.Sqrt(1-h))
+		// s without the row, and so the externally studentized residual
+		sOut := s * math.Sqrt((dfResid-r*r)/(dfResid-1))
+		t := r * s / sOut
+		inf.Standardized = r
+		inf.Studentized = t
+		inf.CooksD = r * r * h / (float64(p) * (1 - h))
+		inf.Dffits = t * math.Sqrt(h/(1-h))
+
+		// z = (X'WX)^-1 x, and b - b(without the row) = z w e / (1-h)
+		for j := 0; j < p; j++ {
+			z[j] = 0
+			for k := 0; k < p; k++ {
+				z[j] += bread.At(j, k) * xfull[k]
+			}
+			inf.Dfbetas[j] = z[j] * weight * e / (1 - h) / (sOut * math.Sqrt(bread.At(j, j)))
+		}
+		return f(inf)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fit, nil
+}
+
+// Influence(): as MillerLSQ.Influence(), with the names of the Model.
+func (mod *Model) Influence(wycol int, src RowSource, cols Columns, f func(inf *Influence) error) (*FitResult, error) {
+	r, err := mod.MillerLSQ.Influence(wycol, src, cols, f)
+	if err != nil {
+		return nil, err
+	}
+	mod.nameResult(r, wycol)
+	return r, nil
+}
+
+// InfluenceCSV writes Influences as rows of CSV, after a header line.
+// Its Write() method can be given to Influence() as f; call Flush() at
+// the end.
+type InfluenceCSV struct {
+	w      *csv.Writer
+	names  []string
+	header bool
+	rec    []string
+}
+
+// NewInfluenceCSV(): names label the DFBETAS columns, one per
+// coefficient, as in FitResult.Names; if nil they are numbered.
+func NewInfluenceCSV(w io.Writer, names []string) *InfluenceCSV {
+	return &InfluenceCSV{w: csv.NewWriter(w), names: names}
+}
+
+func (c *InfluenceCSV) Write(inf *Influence) error {
+	if !c.header {
+		c.header = true
+		hdr := []string{"row", "weight", "fitted", "resid", "leverage", "standardized", "studentized", "cooks_d", "dffits"}
+		for j := range inf.Dfbetas {
+			if c.names != nil {
+				hdr = append(hdr, "dfbetas_"+c.names[j])
+			} else {
+				hdr = append(hdr, fmt.Sprintf("dfbetas_%d", j))
+			}
+		}
+		err := c.w.Write(hdr)
+		if err != nil {
+			return err
+		}
+	}
+	g := func(v float64) string {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	c.rec = append(c.rec[:0], strconv.FormatInt(inf.Row, 10), g(inf.Weight), g(inf.Fitted), g(inf.Resid), g(inf.Leverage),
+		g(inf.Standardized), g(inf.Studentized), g(inf.CooksD), g(inf.Dffits))
+	for _, d := range inf.Dfbetas {
+		c.rec = append(c.rec, g(d))
+	}
+	return c.w.Write(c.rec)
+}
+
+// Flush(): write out anything buffered, and report any error so far.
+func (c *InfluenceCSV) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// TopInfluence keeps the K most influential rows seen, by the score By
+// gives, largest first; by default the score is Cook's distance. Its
+// Add() method can be given to Influence() as f. Rows whose score is
+// NaN are not kept.
+type TopInfluence struct {
+	K  int
+	By func(inf *Influence) float64
+
+	h influenceHeap
+}
+
+func NewTopInfluence(k int) *TopInfluence {
+	return &TopInfluence{K: k, By: func(inf *Influence) float64 { return inf.CooksD }}
+}
+
+func (t *TopInfluence) Add(inf *Influence) error {
+	score := t.By(inf)
+	if t.K <= 0 || math.IsNaN(score) {
+		return nil
+	}
+	if len(t.h) < t.K {
+		heap.Push(&t.h, scoredInfluence{score, inf.copy()})
+		return nil
+	}
+	if score > t.h[0].score {
+		t.h[0] = scoredInfluence{score, inf.copy()}
+		heap.Fix(&t.h, 0)
+	}
+	return nil
+}
+
+// Rows(): the rows kept, most influential first.
+func (t *TopInfluence) Rows() []*Influence {
+	s := make([]scoredInfluence, len(t.h))
+	copy(s, t.h)
+	sort.Slice(s, func(i, j int) bool { return s[i].score > s[j].score })
+	rows := make([]*Influence, len(s))
+	for i := range s {
+		rows[i] = s[i].inf
+	}
+	return rows
+}
+
+type scoredInfluence struct {
+	score float64
+	inf   *Influence
+}
+
+// influenceHeap is a min-heap, so the least influential of the rows
+// kept is the one to go.
+type influenceHeap []scoredInfluence
+
+func (h influenceHeap) Len() int            { return len(h) }
+func (h influenceHeap) Less(i, j int) bool  { return h[i].score < h[j].score }
+func (h influenceHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *influenceHeap) Push(x interface{}) { *h = append(*h, x.(scoredInfluence)) }
+func (h *influenceHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,188 @@
+package lsq
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestInfluence(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+
+	// Fuel_Pop ~ Tax + Income + RoadMls + DLic, optionally weighted by Popn
+	xcols := []int{2, 4, 5, 7}
+	names := []string{"Tax", "Income", "RoadMls", "DLic"}
+	p := len(xcols) + 1
+	n := len(df.Rows)
+
+	fill := func(weighted bool, skip int) *Model {
+		mod := NewModel(names, []string{"Fuel_Pop"})
+		xrow := make([]float64, len(xcols))
+		for i := range df.Rows {
+			if i == skip {
+				continue
+			}
+			for k, j := range xcols {
+				xrow[k] = df.Rows[i][j]
+			}
+			w := 1.0
+			if weighted {
+				w = df.Rows[i][1]
+			}
+			mod.Includ(w, xrow, df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		return mod
+	}
+	src := NewSliceSource(df.Rows)
+	cols := Columns{X: xcols, Y: []int{last}, Weight: NoWeight}
+
+	for _, weighted := range []bool{false, true} {
+		wcols := cols
+		if weighted {
+			wcols.Weight = 1
+		}
+
+		cv.Convey(fmt.Sprintf("Given the fuelcons.dat data (weighted: %v), Influence() should agree with refitting without each row", weighted), t, func() {
+			mod := fill(weighted, -1)
+			var got []*Influence
+			fit, err := mod.Influence(0, src, wcols, func(inf *Influence) error {
+				got = append(got, inf.copy())
+				return nil
+			})
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(len(got), cv.ShouldEqual, n)
+			cv.So(fit.Names[4], cv.ShouldEqual, "DLic")
+
+			sumH := 0.0
+			for i, inf := range got {
+				cv.So(inf.Row, cv.ShouldEqual, i)
+				sumH += inf.Leverage
+				w := inf.Weight
+
+				out, err := fill(weighted, i).Fit(0)
+				cv.So(err, cv.ShouldBeNil)
+
+				// the prediction without the row, and its variance
+				x := []float64{1}
+				for _, j := range xcols {
+					x = append(x, df.Rows[i][j])
+				}
+				predOut := 0.0
+				for a := range x {
+					predOut += out.Coef[a] * x[a]
+				}
+				cv.So(inf.Fitted+inf.Resid, cv.ShouldAlmostEqual, df.Rows[i][last], 1e-9)
+
+				// e(-i) = e / (1-h), and the studentized residual from it
+				cv.So(df.Rows[i][last]-predOut, cv.ShouldAlmostEqual, inf.Resid/(1-inf.Leverage), 1e-7*math.Abs(inf.Resid)+1e-9)
+				vOut := 0.0
+				for a := range x {
+					for b := range x {
+						vOut += x[a] * out.Vcov.At(a, b) * x[b]
+					}
+				}
+				tOut := (df.Rows[i][last] - predOut) / math.Sqrt(out.Sigma*out.Sigma/w+vOut)
+				cv.So(inf.Studentized, cv.ShouldAlmostEqual, tOut, 1e-7*math.Abs(tOut))
+
+				// DFBETAS and Cook's distance from the change in the coefficients
+				diff := make([]float64, p)
+				for a := range diff {
+					diff[a] = fit.Coef[a] - out.Coef[a]
+					bread := fit.Vcov.At(a, a) / (fit.Sigma * fit.Sigma)
+					want := diff[a] / (out.Sigma * math.Sqrt(bread))
+					cv.So(inf.Dfbetas[a], cv.ShouldAlmostEqual, want, 1e-7*math.Abs(want)+1e-12)
+				}
+				cook := 0.0
+				for a := range diff {
+					for b := range diff {
+						xtx := 0.0
+						for k := 0; k < n; k++ {
+							xa, xb := 1.0, 1.0
+							if a > 0 {
+								xa = df.Rows[k][xcols[a-1]]
+							}
+							if b > 0 {
+								xb = df.Rows[k][xcols[b-1]]
+							}
+							wk := 1.0
+							if weighted {
+								wk = df.Rows[k][1]
+							}
+							xtx += wk * xa * xb
+						}
+						cook += diff[a] * xtx * diff[b]
+					}
+				}
+				cook /= float64(p) * fit.Sigma * fit.Sigma
+				cv.So(inf.CooksD, cv.ShouldAlmostEqual, cook, 1e-7*cook)
+
+				cv.So(inf.Dffits, cv.ShouldAlmostEqual, inf.Studentized*math.Sqrt(inf.Leverage/(1-inf.Leverage)), 1e-12)
+				std := math.Sqrt(w) * inf.Resid / (fit.Sigma * math.Sqrt(1-inf.Leverage))
+				cv.So(inf.Standardized, cv.ShouldAlmostEqual, std, 1e-9*math.Abs(std))
+			}
+			// the leverages add up to p
+			cv.So(sumH, cv.ShouldAlmostEqual, float64(p), 1e-9)
+		})
+	}
+
+	cv.Convey("TopInfluence should keep the rows of largest Cook's distance, and InfluenceCSV should write a line per row", t, func() {
+		mod := fill(false, -1)
+		var all []*Influence
+		top := NewTopInfluence(3)
+		var buf bytes.Buffer
+		csv := NewInfluenceCSV(&buf, []string{"(Intercept)", "Tax", "Income", "RoadMls", "DLic"})
+		_, err := mod.Influence(0, src, cols, func(inf *Influence) error {
+			all = append(all, inf.copy())
+			err := top.Add(inf)
+			if err != nil {
+				return err
+			}
+			return csv.Write(inf)
+		})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(csv.Flush(), cv.ShouldBeNil)
+
+		rows := top.Rows()
+		cv.So(len(rows), cv.ShouldEqual, 3)
+		bigger := 0
+		for _, inf := range all {
+			if inf.CooksD > rows[2].CooksD {
+				bigger++
+			}
+		}
+		cv.So(bigger, cv.ShouldEqual, 2)
+		cv.So(rows[0].CooksD >= rows[1].CooksD && rows[1].CooksD >= rows[2].CooksD, cv.ShouldBeTrue)
+		for _, inf := range rows {
+			fmt.Printf("\n%s: Cook's D %.4f, leverage %.4f, studentized residual %.3f", df.Rownames[inf.Row], inf.CooksD, inf.Leverage, inf.Studentized)
+		}
+		fmt.Printf("\n")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		cv.So(len(lines), cv.ShouldEqual, n+1)
+		cv.So(lines[0], cv.ShouldEqual, "row,weight,fitted,resid,leverage,standardized,studentized,cooks_d,dffits,dfbetas_(Intercept),dfbetas_Tax,dfbetas_Income,dfbetas_RoadMls,dfbetas_DLic")
+		cv.So(strings.HasPrefix(lines[1], "0,1,"), cv.ShouldBeTrue)
+	})
+
+	cv.Convey("An error from the callback should stop the pass", t, func() {
+		mod := fill(false, -1)
+		calls := 0
+		_, err := mod.Influence(0, src, cols, func(inf *Influence) error {
+			calls++
+			if calls == 5 {
+				return fmt.Errorf("enough")
+			}
+			return nil
+		})
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(calls, cv.ShouldEqual, 5)
+	})
+}
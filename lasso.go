@@ -0,0 +1,344 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The lasso and the elastic net, fit by coordinate descent, following
+// Friedman, Hastie and Tibshirani, "Regularization Paths for Generalized
+// Linear Models via Coordinate Descent", J. Stat. Software (2010).
+//
+// Coordinate descent only ever needs the Gram matrix X'X and the vector
+// X'y, and both can be read off the factorization: with the y column
+// appended to R as SupplementR() does, X'X and X'y are the dot products
+// of its columns, just as in QR2Cov(). So, like everything else here,
+// the whole path comes from a single pass over the data.
+//
+// The objective, for each lambda, is that of glmnet:
+//
+//	1/(2n) * ||y - b0 - X b||^2 + lambda * ( alpha*|b|_1 + (1-alpha)/2 * ||b||^2 )
+//
+// The intercept b0 is never penalized. Without an intercept (see
+// NewMillerLSQIntercept()) there is no b0, and nothing is centered.
+
+// ElasticNetOptions controls ElasticNetPath().
+type ElasticNetOptions struct {
+	// Alpha mixes the penalties: 1 is the lasso, and values down
+	// towards 0 move towards ridge regression. Must be in [0, 1]; the
+	// zero value means 1, the lasso.
+	Alpha float64
+
+	// Ridge asks for pure ridge regression, alpha 0, which the zero
+	// value of Alpha cannot give. Alpha must then be left at 0.
+	Ridge bool
+
+	// Lambdas is the path of penalties, which should be decreasing for
+	// the warm starts to help. If empty, Nlambda values are spaced
+	// evenly on the log scale from the smallest lambda that sets every
+	// coefficient to zero, down to LambdaMinRatio times that.
+	Lambdas        []float64
+	Nlambda        int     // default 100
+	LambdaMinRatio float64 // default 1e-4 if n > p, else 1e-2
+
+	// Standardize applies the penalty to the coefficients of the
+	// x-variables scaled to unit variance (dividing by n). The
+	// coefficients returned are always on the original scale.
+	Standardize bool
+
+	// Tol is the convergence threshold on the largest change in the
+	// fitted values, relative to the variance of y. Default 1e-7.
+	Tol float64
+
+	// MaxIter bounds the passes over the coordinates for each lambda.
+	// Default 100000.
+	MaxIter int
+}
+
+// ElasticNetFit is the fit for one lambda.
+type ElasticNetFit struct {
+	Lambda float64
+
+	// Coef is in the original variable order: the intercept first,
+	// if there is one, then x-variables 1, 2, ..., whatever the
+	// current m.Vorder.
+	Coef []float64
+
+	Df       int     // the number of non-zero coefficients, not counting the intercept
+	Rss      float64 // residual sum of squares
+	DevRatio float64 // fraction of the sum of squares explained, 1 - Rss/Tss, as in Fit()
+	Iter     int     // passes over the coordinates
+}
+
+// ElasticNetPath is returned by ElasticNetPath().
+type ElasticNetPath struct {
+	Wycol int
+	Alpha float64
+	Fits  []*ElasticNetFit
+}
+
+// ElasticNetPath(): fit the lasso (or elastic net, with opt.Alpha < 1) of
+// the wycol-th y-target on every x-variable, for each lambda on the path.
+// Each fit is started from the solution for the previous lambda. m itself
+// is not modified.
+func (m *MillerLSQ) ElasticNetPath(wycol int, opt ElasticNetOptions) (*ElasticNetPath, error) {
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("ElasticNetPath() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	if opt.Alpha < 0 || opt.Alpha > 1 || math.IsNaN(opt.Alpha) {
+		return nil, errors.New(fmt.Sprintf("ElasticNetPath() error: Alpha(%v) must be in [0, 1]", opt.Alpha))
+	}
+	if opt.Ridge {
+		if opt.Alpha != 0 {
+			return nil, errors.New(fmt.Sprintf("ElasticNetPath() error: Alpha(%v) given with Ridge, which is alpha 0", opt.Alpha))
+		}
+	} else if opt.Alpha == 0 {
+		opt.Alpha = 1
+	}
+	for _, lambda := range opt.Lambdas {
+		if lambda < 0 || math.IsNaN(lambda) || math.IsInf(lambda, 0) {
+			return nil, errors.New(fmt.Sprintf("ElasticNetPath() error: lambda(%v) must be finite and >= 0", lambda))
+		}
+	}
+	if opt.Nlambda <= 0 {
+		opt.Nlambda = 100
+	}
+	if opt.Tol <= 0 {
+		opt.Tol = 1e-7
+	}
+	if opt.MaxIter <= 0 {
+		opt.MaxIter = 100000
+	}
+
+	g, err := m.newGram(wycol, opt.Standardize)
+	if err != nil {
+		return nil, err
+	}
+
+	lambdas := opt.Lambdas
+	if len(lambdas) == 0 {
+		ratio := opt.LambdaMinRatio
+		if ratio <= 0 {
+			ratio = 1e-4
+			if g.n <= float64(m.Ncol) {
+				ratio = 1e-2
+			}
+		}
+		lambdas = g.lambdaPath(opt.Alpha, opt.Nlambda, ratio)
+	}
+
+	res := &ElasticNetPath{Wycol: wycol, Alpha: opt.Alpha}
+	b := make([]float64, g.p)
+	for _, lambda := range lambdas {
+		iter, err := g.descend(b, lambda, opt.Alpha, opt.Tol, opt.MaxIter)
+		if err != nil {
+			return nil, err
+		}
+		fit := g.fit(b, lambda)
+		fit.Iter = iter
+		res.Fits = append(res.Fits, fit)
+	}
+	return res, nil
+}
+
+// gram holds the centered, and perhaps scaled, cross products that
+// coordinate descent works on, indexed by x-variable number - 1.
+type gram struct {
+	n     float64
+	p     int
+	c     int // 1 with an intercept, 0 without
+	xbar  []float64
+	scale []float64 // the sd of each x if standardizing, else 1; 0 for a constant column
+	ybar  float64
+	tss   float64
+
+	xx [][]float64 // (1/n) * centered and scaled X'X
+	xy []float64   // (1/n) * centered and scaled X'y
+
+	r []float64 // xy - xx * b, for the current b
+}
+
+// newGram(): recover X'X and X'y for the wycol-th y-target from the
+// factorization.
+func (m *MillerLSQ) newGram(wycol int, standardize bool) (*gram, error) {
+	sup := SupplementR(&m.UpperTriMatrix, m.Rhs[wycol], m.Sserr[wycol], NoRecycle)
+
+	// pos[v] is the position of variable v in sup, and y is in the last
+	// one, position m.Ncol. Without an intercept, pos[0] is unused.
+	p := m.Nxvar
+	pos := make([]int, p+1)
+	for i, v := range m.Vorder {
+		pos[v] = i
+	}
+	ypos := m.Ncol
+
+	g := &gram{
+		n:     m.AccumWeightSum,
+		p:     p,
+		c:     m.nconst(),
+		xbar:  make([]float64, p),
+		scale: make([]float64, p),
+		xx:    make([][]float64, p),
+		xy:    make([]float64, p),
+		r:     make([]float64, p),
+	}
+	if g.c == 1 {
+		g.n = sup.DotColumns(pos[0], pos[0])
+	}
+	n := g.n
+	if n <= 0 {
+		return nil, errors.New("ElasticNetPath() error: no observations have been included")
+	}
+
+	// the means stay zero without an intercept
+	if g.c == 1 {
+		g.ybar = sup.DotColumns(pos[0], ypos) / n
+		for j := 0; j < p; j++ {
+			g.xbar[j] = sup.DotColumns(pos[0], pos[j+1]) / n
+		}
+	}
+	g.tss = sup.DotColumns(ypos, ypos) - n*g.ybar*g.ybar
+
+	for j := 0; j < p; j++ {
+		g.xx[j] = make([]float64, p)
+		for k := 0; k <= j; k++ {
+			c := (sup.DotColumns(pos[j+1], pos[k+1]) - n*g.xbar[j]*g.xbar[k]) / n
+			g.xx[j][k] = c
+			g.xx[k][j] = c
+		}
+		g.xy[j] = (sup.DotColumns(pos[j+1], ypos) - n*g.xbar[j]*g.ybar) / n
+	}
+
+	for j := 0; j < p; j++ {
+		// a column with no variance about its mean cannot enter the model
+		if g.xx[j][j] <= 1e-12*(1+g.xbar[j]*g.xbar[j]) {
+			g.scale[j] = 0
+			continue
+		}
+		g.scale[j] = 1
+		if standardize {
+			g.scale[j] = math.Sqrt(g.xx[j][j])
+		}
+	}
+	for j := 0; j < p; j++ {
+		for k := 0; k < p; k++ {
+			if g.scale[j] == 0 || g.scale[k] == 0 {
+				g.xx[j][k] = 0
+			} else {
+				g.xx[j][k] /= g.scale[j] * g.scale[k]
+			}
+		}
+		if g.scale[j] != 0 {
+			g.xy[j] /= g.scale[j]
+		} else {
+			g.xy[j] = 0
+		}
+	}
+	copy(g.r, g.xy)
+	return g, nil
+}
+
+// lambdaPath(): nlambda values, log-spaced from the smallest lambda for
+// which every coefficient is zero.
+func (g *gram) lambdaPath(alpha float64, nlambda int, ratio float64) []float64 {
+	// as glmnet does, keep ridge away from an infinite lambda max
+	a := math.Max(alpha, 1e-3)
+	max := 0.0
+	for j := range g.xy {
+		max = math.Max(max, math.Abs(g.xy[j]))
+	}
+	max /= a
+
+	lambdas := make([]float64, nlambda)
+	if nlambda == 1 {
+		lambdas[0] = max
+		return lambdas
+	}
+	step := math.Log(ratio) / float64(nlambda-1)
+	for i := range lambdas {
+		lambdas[i] = max * math.Exp(step*float64(i))
+	}
+	return lambdas
+}
+
+// descend(): cyclic coordinate descent from the starting point b, which
+// is updated in place, using the covariance updates of Friedman et al.
+func (g *gram) descend(b []float64, lambda float64, alpha float64, tol float64, maxIter int) (iter int, err error) {
+	l1 := lambda * alpha
+	l2 := lambda * (1 - alpha)
+	thresh := tol * g.tss / g.n
+
+	for iter = 1; iter <= maxIter; iter++ {
+		maxChange := 0.0
+		for j := 0; j < g.p; j++ {
+			if g.scale[j] == 0 {
+				continue
+			}
+			xjj := g.xx[j][j]
+			old := b[j]
+			z := g.r[j] + xjj*old
+			b[j] = softThreshold(z, l1) / (xjj + l2)
+
+			delta := b[j] - old
+			if delta == 0 {
+				continue
+			}
+			for k := 0; k < g.p; k++ {
+				g.r[k] -= g.xx[k][j] * delta
+			}
+			maxChange = math.Max(maxChange, xjj*delta*delta)
+		}
+		if maxChange < thresh {
+			return iter, nil
+		}
+	}
+	return iter, errors.New(fmt.Sprintf("ElasticNetPath() error: no convergence after %d passes at lambda %v", maxIter, lambda))
+}
+
+func softThreshold(z float64, gamma float64) float64 {
+	switch {
+	case z > gamma:
+		return z - gamma
+	case z < -gamma:
+		return z + gamma
+	}
+	return 0
+}
+
+// fit(): the coefficients on the original scale, and the summaries.
+func (g *gram) fit(b []float64, lambda float64) *ElasticNetFit {
+	fit := &ElasticNetFit{
+		Lambda: lambda,
+		Coef:   make([]float64, g.p+g.c),
+	}
+
+	// with the gradient r = xy - xx*b, the centered residual sum of
+	// squares over n is yy - 2 b'xy + b'xx b = yy - b'(xy + r)
+	rss := g.tss / g.n
+	b0 := g.ybar
+	for j := 0; j < g.p; j++ {
+		if b[j] == 0 {
+			continue
+		}
+		fit.Df++
+		rss -= b[j] * (g.xy[j] + g.r[j])
+		fit.Coef[j+g.c] = b[j] / g.scale[j]
+		b0 -= fit.Coef[j+g.c] * g.xbar[j]
+	}
+	if g.c == 1 {
+		fit.Coef[0] = b0
+	}
+	fit.Rss = rss * g.n
+	fit.DevRatio = 1 - fit.Rss/g.tss
+	return fit
+}
+
+func (r *ElasticNetPath) String() string {
+	s := fmt.Sprintf("Elastic net path (alpha %v) for y-target %d\n", r.Alpha, r.Wycol)
+	s += fmt.Sprintf("%12s  %4s  %8s  %14s  %s\n", "lambda", "df", "%dev", "RSS", "coefficients")
+	for _, f := range r.Fits {
+		s += fmt.Sprintf("%12.6g  %4d  %8.4f  %14.6g  %v\n", f.Lambda, f.Df, f.DevRatio, f.Rss, f.Coef)
+	}
+	return s
+}
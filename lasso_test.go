@@ -0,0 +1,141 @@
+package lsq
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestElasticNetPath(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+
+	// Fuel_Pop ~ Tax + Income + RoadMls + DLic
+	xcols := []int{2, 4, 5, 7}
+	last := df.Ncol - 1
+	p := len(xcols)
+
+	m := NewMillerLSQ(p, 1)
+	xrow := make([]float64, p)
+	for i := range df.Rows {
+		for k, j := range xcols {
+			xrow[k] = df.Rows[i][j]
+		}
+		m.Includ(1.0, xrow, df.Rows[i][last:], NAN_OMIT_ROW)
+	}
+
+	// means and sds (dividing by n) straight from the data
+	n := float64(len(df.Rows))
+	xbar := make([]float64, p)
+	sd := make([]float64, p)
+	for k, j := range xcols {
+		for i := range df.Rows {
+			xbar[k] += df.Rows[i][j] / n
+		}
+		for i := range df.Rows {
+			d := df.Rows[i][j] - xbar[k]
+			sd[k] += d * d / n
+		}
+		sd[k] = math.Sqrt(sd[k])
+	}
+
+	ones := []float64{1, 1, 1, 1}
+
+	// the Karush-Kuhn-Tucker conditions, checked against the raw data, with
+	// the x-variables divided by scale: the gradient (1/n) x_j'(y - yhat)
+	// must equal lambda*(alpha*sign(b_j) + (1-alpha)*b_j) where b_j != 0,
+	// and be no larger than lambda*alpha in magnitude where b_j == 0.
+	kkt := func(f *ElasticNetFit, alpha float64, scale []float64) bool {
+		for k, j := range xcols {
+			grad := 0.0
+			for i := range df.Rows {
+				yhat := f.Coef[0]
+				for kk, jj := range xcols {
+					yhat += f.Coef[kk+1] * df.Rows[i][jj]
+				}
+				grad += (df.Rows[i][j] - xbar[k]) / scale[k] * (df.Rows[i][last] - yhat) / n
+			}
+			b := f.Coef[k+1] * scale[k]
+			if b == 0 {
+				if math.Abs(grad) > f.Lambda*alpha+1e-6 {
+					fmt.Printf("kkt failed at lambda %v, var %d: |grad| %v > %v\n", f.Lambda, k+1, math.Abs(grad), f.Lambda*alpha)
+					return false
+				}
+				continue
+			}
+			want := f.Lambda * (alpha*math.Copysign(1, b) + (1-alpha)*b)
+			if math.Abs(grad-want) > 1e-6*(1+math.Abs(want)) {
+				fmt.Printf("kkt failed at lambda %v, var %d: grad %v != %v\n", f.Lambda, k+1, grad, want)
+				return false
+			}
+		}
+		return true
+	}
+
+	cv.Convey("Given the fuelcons.dat data in a MillerLSQ, the default lasso path should start empty and satisfy the KKT conditions throughout", t, func() {
+		path, err := m.ElasticNetPath(0, ElasticNetOptions{Alpha: 1, Standardize: true, Nlambda: 20, Tol: 1e-20})
+		cv.So(err, cv.ShouldBeNil)
+		fmt.Printf("\n%s\n", path)
+
+		cv.So(len(path.Fits), cv.ShouldEqual, 20)
+		cv.So(path.Fits[0].Df, cv.ShouldEqual, 0)
+		cv.So(path.Fits[1].Df, cv.ShouldBeGreaterThan, 0)
+		cv.So(path.Fits[19].Df, cv.ShouldEqual, p)
+		for i, f := range path.Fits {
+			cv.So(kkt(f, 1, sd), cv.ShouldBeTrue)
+			if i > 0 {
+				cv.So(f.Lambda, cv.ShouldBeLessThan, path.Fits[i-1].Lambda)
+				cv.So(f.Rss <= path.Fits[i-1].Rss*(1+1e-12), cv.ShouldBeTrue)
+			}
+		}
+	})
+
+	cv.Convey("An elastic net on the raw scale should also satisfy its KKT conditions", t, func() {
+		path, err := m.ElasticNetPath(0, ElasticNetOptions{Alpha: 0.5, Lambdas: []float64{100, 10, 1}, Tol: 1e-20})
+		cv.So(err, cv.ShouldBeNil)
+		for _, f := range path.Fits {
+			cv.So(kkt(f, 0.5, ones), cv.ShouldBeTrue)
+		}
+	})
+
+	cv.Convey("With lambda of 0, the lasso should reach the ordinary least squares fit", t, func() {
+		path, err := m.ElasticNetPath(0, ElasticNetOptions{Alpha: 1, Lambdas: []float64{0}, Standardize: true, Tol: 1e-20})
+		cv.So(err, cv.ShouldBeNil)
+		ols, err := m.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		for j := range ols.Coef {
+			cv.So(path.Fits[0].Coef[j], cv.ShouldAlmostEqual, ols.Coef[j], 1e-6*(1+math.Abs(ols.Coef[j])))
+		}
+		cv.So(path.Fits[0].Rss, cv.ShouldAlmostEqual, ols.Rss, 1e-6*ols.Rss)
+	})
+
+	cv.Convey("Alpha outside [0, 1] should be an error", t, func() {
+		_, err := m.ElasticNetPath(0, ElasticNetOptions{Alpha: 2})
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = m.ElasticNetPath(0, ElasticNetOptions{Alpha: 0.5, Ridge: true})
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+
+	cv.Convey("The zero value of the options should give the lasso, and Ridge ridge regression", t, func() {
+		lambdas := []float64{100, 10, 1}
+		path, err := m.ElasticNetPath(0, ElasticNetOptions{Lambdas: lambdas, Standardize: true, Tol: 1e-20})
+		cv.So(err, cv.ShouldBeNil)
+		lasso, err := m.ElasticNetPath(0, ElasticNetOptions{Alpha: 1, Lambdas: lambdas, Standardize: true, Tol: 1e-20})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(path.Alpha, cv.ShouldEqual, 1)
+		for i := range lambdas {
+			cv.So(path.Fits[i].Coef, cv.ShouldResemble, lasso.Fits[i].Coef)
+		}
+		cv.So(path.Fits[0].Df < m.Nxvar, cv.ShouldBeTrue)
+
+		ridge, err := m.ElasticNetPath(0, ElasticNetOptions{Ridge: true, Lambdas: lambdas, Standardize: true, Tol: 1e-20})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(ridge.Alpha, cv.ShouldEqual, 0)
+		cv.So(ridge.Fits[0].Df, cv.ShouldEqual, m.Nxvar)
+	})
+}
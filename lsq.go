@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -82,12 +83,17 @@ import (
 //
 //  Nobs    = the number of observations processed to date.
 //  Ncol    = the total number of dependent (x) variables,
-//            including one for the constant. In this version of the code,
-//            a constant or intercept term is always fitted.
+//            including one for the constant. A constant or intercept
+//            term is fitted unless the MillerLSQ was made by
+//            NewMillerLSQIntercept(nxvar, nyvar, !AddIntercept), in
+//            which case the fit is forced through the origin and
+//            Ncol == Nxvar.
 //
 //  R_dim   = the dimension of the upper triangular matrix R = Ncol*(Ncol-1)/2
 //  Vorder  = an integer vector storing the current order of the variables
 //            in the QR-factorization.   The initial order is 0, 1, 2, ...
+//            where 0 is the constant and j is the j-th x-variable. Without
+//            an intercept, there is no variable 0 and the order starts at 1.
 //
 //  Initialized = a logical variable which indicates whether space has
 //                been allocated for various arrays.
@@ -126,11 +132,14 @@ import (
 //            The residual sum of squares with NO variables in the model,
 //            that is the total sum of squares of the first column of y-values, can be
 //            calculated as Rss[0][0] + D[0]*Rhs[0][0]^2.
-//            Since we always fit a constant, by itself Rss[0][0] is the sum of squares of
-//            (y - ybar) where ybar is the average value of y.
+//            When the constant is in the first position, by itself Rss[0][0] is the
+//            sum of squares of (y - ybar) where ybar is the average value of y.
+//            NullRss() returns whichever of these the null model calls for.
 //
 //  Sserr   = residual sum of squares with all of the variables included, one
 //             for each y-variable.
+//  Sscp    = residual sums of squares and cross-products with all of the
+//             variables included, Nyvar by Nyvar, row-major; its diagonal is Sserr.
 //  Row_ptr = array of indices of first elements in each row of R.
 //
 //--------------------------------------------------------------------------
@@ -140,6 +149,10 @@ type NanHandling uint32
 const (
 	NAN_TO_ZERO  NanHandling = 0
 	NAN_OMIT_ROW             = 1
+
+	// NAN_OMIT_TARGET omits a missing y-target from the row, but keeps the
+	// row for the other targets; see Target(). A missing x omits the row.
+	NAN_OMIT_TARGET = 2
 )
 
 // change assignments from 1 (Fortan) based to 0 (Go) based by doing -Adj
@@ -155,6 +168,22 @@ type MillerLSQ struct {
 	Nxvar          int     // not counting Constant (intercept) coefficient. Nxvar = Ncol -1.
 	Nyvar          int     // number of y-target variables
 
+	// NoIntercept is set for regression through the origin, when there
+	// is no constant column and Nxvar == Ncol. See NewMillerLSQIntercept().
+	NoIntercept bool
+
+	// ForgettingFactor, if strictly between 0 and 1, is applied with
+	// Decay() before each row is added by Includ(), so that a row seen
+	// k rows ago carries weight ForgettingFactor^k, as in recursive least
+	// squares. The zero value, like 1, means no forgetting.
+	ForgettingFactor float64
+
+	// Decayed is set by the first Decay(); from then on DecayedNobs is
+	// the number of observations, discounted just as their weights are,
+	// and it replaces Nobs in the degrees of freedom. See NobsEffective().
+	Decayed     bool
+	DecayedNobs float64
+
 	// the main Upper-triangular matrix
 	UpperTriMatrix
 
@@ -167,8 +196,19 @@ type MillerLSQ struct {
 
 	// NaN handling
 	NanApproach         NanHandling // NAN_TO_ZERO or NAN_OMIT_ROW implemented so far.
-	CountNaNRowsSkipped int64
-	RowsSeen            int64 // includes both included and omitted due to nan
+	CountNaNRowsSkipped int64       // rows omitted for missing or infinite values, or a weight that is neither
+	RowsSeen            int64       // includes both included and omitted due to nan
+
+	// per-column missing-value policies, and what came of them; see
+	// SetMissingPolicy().
+	XPolicy  []ColumnPolicy
+	YPolicy  []ColumnPolicy
+	XMissing []MissingCounts
+	YMissing []MissingCounts
+
+	// Partial[j] holds the rows that had the j-th y-target but lacked
+	// another; nil until there are any. See Target().
+	Partial []*MillerLSQ
 
 	UseMeanSd bool // Apply x' = (x - mean)/sd normalization
 	Xmean     []float64
@@ -182,6 +222,7 @@ type MillerLSQ struct {
 
 	Vsmall float64
 	Sserr  []float64
+	Sscp   []float64
 	Toly   float64
 
 	Vorder []int
@@ -225,6 +266,72 @@ func DeepCopy(a []float64) []float64 {
 	return res
 }
 
+func DeepCopyInts(a []int) []int {
+	res := make([]int, len(a))
+	copy(res, a)
+	return res
+}
+
+// Clone(): return a deep copy of m, sharing no memory with it.
+func (m *MillerLSQ) Clone() *MillerLSQ {
+	c := *m
+
+	c.XStats = cloneTracker(&m.XStats)
+	c.YStats = cloneTracker(&m.YStats)
+
+	c.R = DeepCopy(m.R)
+	c.D = DeepCopy(m.D)
+	c.Row_ptr = DeepCopyInts(m.Row_ptr)
+
+	c.Xmean = DeepCopy(m.Xmean)
+	c.Xsd = DeepCopy(m.Xsd)
+	c.Ymean = DeepCopy(m.Ymean)
+	c.Ysd = DeepCopy(m.Ysd)
+
+	c.Rss_set = make([]bool, len(m.Rss_set))
+	copy(c.Rss_set, m.Rss_set)
+	c.Sserr = DeepCopy(m.Sserr)
+	c.Sscp = DeepCopy(m.Sscp)
+	c.XPolicy = append([]ColumnPolicy(nil), m.XPolicy...)
+	c.YPolicy = append([]ColumnPolicy(nil), m.YPolicy...)
+	c.XMissing = append([]MissingCounts(nil), m.XMissing...)
+	c.YMissing = append([]MissingCounts(nil), m.YMissing...)
+	if m.Partial != nil {
+		c.Partial = make([]*MillerLSQ, len(m.Partial))
+		for j, p := range m.Partial {
+			if p != nil {
+				c.Partial[j] = p.Clone()
+			}
+		}
+	}
+	c.Vorder = DeepCopyInts(m.Vorder)
+	c.Tol = DeepCopy(m.Tol)
+
+	c.Rhs = make([][]float64, len(m.Rhs))
+	for i := range m.Rhs {
+		c.Rhs[i] = DeepCopy(m.Rhs[i])
+	}
+	c.Rss = make([][]float64, len(m.Rss))
+	for i := range m.Rss {
+		c.Rss[i] = DeepCopy(m.Rss[i])
+	}
+	c.Rinv = DeepCopy(m.Rinv)
+
+	c.Curxrow = DeepCopy(m.Curxrow)
+	c.Curyrow = DeepCopy(m.Curyrow)
+	return &c
+}
+
+func cloneTracker(s *SdTracker) SdTracker {
+	return SdTracker{
+		Nc:   s.Nc,
+		W:    DeepCopy(s.W),
+		A:    DeepCopy(s.A),
+		Q:    DeepCopy(s.Q),
+		Nobs: s.Nobs,
+	}
+}
+
 // NewMillerLSQ(): the constructor
 //
 // nxvar should include the count of all x, but not the Constant 1 column.
@@ -236,20 +343,38 @@ func DeepCopy(a []float64) []float64 {
 // nxvar = number of x columns (indep variables)
 // nyvar = number of y columns (dependent variable(s))
 //
+// For regression through the origin, use NewMillerLSQIntercept().
 //
 func NewMillerLSQ(nxvar int, nyvar int) *MillerLSQ {
+	return NewMillerLSQIntercept(nxvar, nyvar, AddIntercept)
+}
+
+// NewMillerLSQIntercept(): as NewMillerLSQ(), but with addIntercept set to
+// false (!AddIntercept), no constant column is added and the model is fit
+// through the origin. Then Ncol == nxvar, variable j is still the j-th
+// x-variable, and R-squared is computed about zero rather than about the
+// mean of y, as R's summary.lm() does for a model without an intercept.
+//
+func NewMillerLSQIntercept(nxvar int, nyvar int, addIntercept bool) *MillerLSQ {
 	//fmt.Printf("NewMillerLSQ called with nxvar=%v   and nyvar=%v\n", nxvar, nyvar)
 	m := &MillerLSQ{}
 
+	m.NoIntercept = !addIntercept
+	if m.NoIntercept && nxvar < 1 {
+		panic("a model without an intercept needs at least one x-variable")
+	}
+
 	m.Nobs = 0
 	m.AccumWeightSum = 0
-	m.Ncol = nxvar + 1 // 1 for the constant term (intercept)
+	m.Ncol = nxvar + m.nconst() // 1 for the constant term (intercept), if any
 	m.Nxvar = nxvar
 	m.Nyvar = nyvar
 
 	m.NanApproach = NAN_OMIT_ROW
 	m.CountNaNRowsSkipped = 0
 	m.RowsSeen = 0 // includes both included and omitted due to nan
+	m.XMissing = make([]MissingCounts, m.Nxvar)
+	m.YMissing = make([]MissingCounts, m.Nyvar)
 
 	m.XStats.ZeroTracker(m.Nxvar)
 	m.YStats.ZeroTracker(m.Nyvar)
@@ -268,6 +393,7 @@ func NewMillerLSQ(nxvar int, nyvar int) *MillerLSQ {
 
 	m.Vsmall = 1e-12
 	m.Sserr = make([]float64, m.Nyvar)
+	m.Sscp = make([]float64, m.Nyvar*m.Nyvar)
 	m.Toly = 0.0
 
 	m.Vorder = make([]int, m.Ncol)
@@ -288,7 +414,7 @@ func NewMillerLSQ(nxvar int, nyvar int) *MillerLSQ {
 	}
 
 	for i := 1; i <= m.Ncol; i++ {
-		m.Vorder[i-Adj] = i - 1
+		m.Vorder[i-Adj] = i - m.nconst()
 	}
 
 	// m.Row_ptr[i] is the position of element R(i,i+1) in array m.R.
@@ -312,6 +438,19 @@ func NewMillerLSQ(nxvar int, nyvar int) *MillerLSQ {
 	return m
 } // end ctor
 
+// nconst(): the number of constant columns, 1 normally, 0 without an intercept.
+func (m *MillerLSQ) nconst() int {
+	if m.NoIntercept {
+		return 0
+	}
+	return 1
+}
+
+// HasIntercept(): false for regression through the origin.
+func (m *MillerLSQ) HasIntercept() bool {
+	return !m.NoIntercept
+}
+
 func clearSlice(slice []float64) {
 	for i := range slice {
 		slice[i] = 0
@@ -329,10 +468,15 @@ func clearBoolSlice(slice []bool) {
 func (m *MillerLSQ) Reset() {
 
 	m.Nobs = 0
+	m.Decayed = false
+	m.DecayedNobs = 0
 	m.AccumWeightSum = 0
 
 	m.CountNaNRowsSkipped = 0
 	m.RowsSeen = 0
+	m.XMissing = make([]MissingCounts, m.Nxvar)
+	m.YMissing = make([]MissingCounts, m.Nyvar)
+	m.Partial = nil
 
 	m.XStats.ZeroTracker(m.Nxvar)
 	m.YStats.ZeroTracker(m.Nyvar)
@@ -348,10 +492,11 @@ func (m *MillerLSQ) Reset() {
 
 	m.Vsmall = 1e-12
 	clearSlice(m.Sserr)
+	clearSlice(m.Sscp)
 	m.Toly = 0.0
 
 	for i := 1; i <= m.Ncol; i++ {
-		m.Vorder[i-Adj] = i - 1
+		m.Vorder[i-Adj] = i - m.nconst()
 	}
 
 	clearSlice(m.R)
@@ -527,22 +672,61 @@ func StringSliceEqual(a, b []string) bool {
 //     inclusion of xrow, yelem = each of yrow member in turn, with the specified weight.
 //
 //   iff row was ommitted due to nanapproach == NAN_OMIT_ROW, we return false
+//
+//     Missing values are handled column by column as SetMissingPolicy()
+//     says, nanapproach being the policy of any column without one of
+//     its own. Whatever is filled in is written back into xrow and yrow.
+//     Infinities, unless a policy treats them as missing, and a weight
+//     that is NaN or infinite, always omit the row. A row missing only
+//     some of its y-targets, under NAN_OMIT_TARGET, goes to m.Partial,
+//     and resid stays NaN for it.
 func (m *MillerLSQ) Includ(weight float64, xrow []float64, yrow []float64, nanapproach NanHandling) (rowIncluded bool) {
+	return m.IncludRecursive(weight, xrow, yrow, nanapproach, nil)
+}
+
+// IncludRecursive(): Includ(), which also fills resid, if not nil, with
+// the standardized recursive residual of the row for each y-target:
+//
+//	sqrt(w) (y - x'b) / sqrt(1 + w x'(X'WX)^-1 x)
+//
+// with b and X'WX from the rows before this one (Brown, Durbin and
+// Evans, 1975). Under the model these are independent, with mean zero
+// and variance sigma^2, which is what CusumTracker builds on. They come
+// free with the Givens sweep. resid is in the units of y, even after
+// SetMeanSd(). It is NaN where there is no recursive residual: for an
+// omitted or zero-weight row, a downdate (weight < 0), or a row that
+// brings in a direction not seen before, as each of the first Ncol
+// rows does.
+func (m *MillerLSQ) IncludRecursive(weight float64, xrow []float64, yrow []float64, nanapproach NanHandling, resid []float64) (rowIncluded bool) {
+	if resid != nil {
+		if len(resid) != m.Nyvar {
+			panic(fmt.Sprintf("len(resid) == %v did not match m.Nyvar == %v", len(resid), m.Nyvar))
+		}
+		for j := range resid {
+			resid[j] = math.NaN()
+		}
+	}
 	m.RowsSeen++
 
 	rowIncluded = true //default
 
-	// xi having NaN is messing us up. Zero them out. This takes care of
-	//  nanapproach == NAN_TO_ZERO
-	xHasNaN := NanToZero(xrow)
-	yHasNaN := NanToZero(yrow)
-
-	// but in order to match with R regression (default) linear model,
-	// we also need to be able to omit rows with NaN in them.
-	// Not yet implemented: replace missing value with xmean?
-	if nanapproach == NAN_OMIT_ROW {
-		if xHasNaN || yHasNaN {
-			// returning early omits the row
+	// NaN or Inf would poison the whole factorization, so fill them in,
+	// or, as in R's default lm(), omit the row.
+	xvars := xrow
+	if !m.NoIntercept && len(xrow) == m.Nxvar+1 {
+		xvars = xrow[1:]
+	}
+	if math.IsNaN(weight) || math.IsInf(weight, 0) || !m.fillMissing(xvars, yrow, nanapproach, true) {
+		// returning early omits the row
+		m.CountNaNRowsSkipped++
+		return false
+	}
+	if len(yrow) > 0 && missingTargets(yrow) {
+		present := false
+		for _, y := range yrow {
+			present = present || !math.IsNaN(y)
+		}
+		if !present {
 			m.CountNaNRowsSkipped++
 			return false
 		}
@@ -556,10 +740,16 @@ func (m *MillerLSQ) Includ(weight float64, xrow []float64, yrow []float64, nanap
 
 	// assume that input one longer than Nxvar means we are getting the intercept too.
 	// Merge() uses this.
-	if len(xrow) == m.Nxvar+1 {
+	switch {
+	case m.NoIntercept:
+		if len(xrow) != m.Nxvar {
+			panic(fmt.Sprintf("len(xrow) == %v did not match m.Nxvar == %v", len(xrow), m.Nxvar))
+		}
+		copy(m.Curxrow, xrow) // no constant
+	case len(xrow) == m.Nxvar+1:
 		copy(m.Curxrow, xrow)
 		xrow = xrow[1:]
-	} else {
+	default:
 		if len(xrow) != m.Nxvar {
 			panic(fmt.Sprintf("len(xrow) == %v did not match m.Nxvar == %v", len(xrow), m.Nxvar))
 		}
@@ -570,17 +760,18 @@ func (m *MillerLSQ) Includ(weight float64, xrow []float64, yrow []float64, nanap
 
 	//fmt.Printf("        in Include, Curxrow = %v\n", m.Curxrow)
 
-	var i, k, nextr int
-	var w, y, xi, di, wxi, dpi, cbar, sbar, xk float64
-
-	w = weight
-
 	// don't bother adding a zero-weighted x vector
-	if w == 0 || math.Abs(w) < m.Vsmall {
+	if weight == 0 || math.Abs(weight) < m.Vsmall {
 		return
-	} else {
-		m.AccumWeightSum += weight
 	}
+	if m.ForgettingFactor > 0 && m.ForgettingFactor < 1 {
+		m.Decay(m.ForgettingFactor)
+	}
+	if missingTargets(yrow) {
+		m.includPartial(weight, xrow, yrow)
+		return
+	}
+	m.AccumWeightSum += weight
 
 	// track our mean and sd
 	m.XStats.AddObs(xrow, weight)
@@ -594,7 +785,7 @@ func (m *MillerLSQ) Includ(weight float64, xrow []float64, yrow []float64, nanap
 		}
 
 		// use bump to skip past the 1.0 constant in xrow
-		bump := 1
+		bump := m.nconst()
 		for j := range xrow {
 			if m.Xsd[j] != 0.0 {
 				m.Curxrow[j+bump] = (m.Curxrow[j+bump] - m.Xmean[j]) / m.Xsd[j]
@@ -604,7 +795,45 @@ func (m *MillerLSQ) Includ(weight float64, xrow []float64, yrow []float64, nanap
 
 	//y = m.Curyrow[0]
 
-	m.Nobs++
+	// a negative weight removes a row that was included before
+	if weight > 0 {
+		m.Nobs++
+		if m.Decayed {
+			m.DecayedNobs++
+		}
+	} else {
+		m.Nobs--
+		if m.Decayed {
+			m.DecayedNobs--
+		}
+	}
+	w := m.givens(weight)
+
+	//     Y * math.Sqrt(W) is now the recursive residual, unless the
+	//     row was absorbed by a new position, which leaves W == 0.
+	if resid != nil && weight > 0 && w > 0 {
+		for j, y := range m.Curyrow {
+			resid[j] = y * math.Sqrt(w)
+			if m.UseMeanSd && m.Ysd[j] != 0.0 {
+				resid[j] *= m.Ysd[j]
+			}
+		}
+	}
+	return
+} // end IncludRecursive()
+
+// givens(): the heart of Includ(). Rotates the row in m.Curxrow (which
+// must already hold the constant, if any) and m.Curyrow into the
+// factorization with weight w, updating D, R, Rhs and Sserr. Nothing
+// else, not even m.Nobs, is touched, so this is also how pseudo-rows
+// get added to a factorization.
+//
+// On return m.Curyrow holds the final y residuals, and the returned
+// weight is what remains of w after the sweep.
+func (m *MillerLSQ) givens(w float64) float64 {
+	var i, k, nextr int
+	var y, xi, di, wxi, dpi, cbar, sbar, xk float64
+
 	for k := range m.Rss_set {
 		m.Rss_set[k] = false
 	}
@@ -681,16 +910,102 @@ func (m *MillerLSQ) Includ(weight float64, xrow []float64, yrow []float64, nanap
 		y = m.Curyrow[yi]
 		m.Sserr[yi] = m.Sserr[yi] + w*y*y
 	}
+	if len(m.Sscp) == m.Nyvar*m.Nyvar {
+		for yi, y := range m.Curyrow {
+			if y == 0 {
+				continue
+			}
+			row := m.Sscp[yi*m.Nyvar : (yi+1)*m.Nyvar]
+			for yj, yy := range m.Curyrow {
+				row[yj] += w * y * yy
+			}
+		}
+	}
 
-	return
-} // end Includ()
+	return w
+} // end givens()
+
+// Decay(): discount everything seen so far by factor, which must be in
+// (0, 1]. Afterwards the factorization is exactly that of the same rows
+// included with their weights multiplied by factor, so old rows fade out
+// without ever having to be kept around for downdating with weight -1.
+//
+// Since X = Q * sqrt(D) * R, this only needs to scale D, Sserr, and the
+// running sums that go with them; R and Rhs are unchanged. Call it
+// directly on a schedule (say once an hour), or set m.ForgettingFactor
+// to have Includ() call it before every row.
+func (m *MillerLSQ) Decay(factor float64) {
+	if !(factor > 0 && factor <= 1) {
+		panic(fmt.Sprintf("Decay() factor(%v) must be in (0, 1]", factor))
+	}
+	if !m.Decayed {
+		m.Decayed = true
+		m.DecayedNobs = float64(m.Nobs)
+	}
+	if factor == 1 {
+		return
+	}
+
+	for i := range m.D {
+		m.D[i] *= factor
+	}
+	for k := range m.Sserr {
+		m.Sserr[k] *= factor
+	}
+	for k := range m.Sscp {
+		m.Sscp[k] *= factor
+	}
+	for _, p := range m.Partial {
+		if p != nil {
+			p.Decay(factor)
+		}
+	}
+	if m.Tol_set {
+		// the tolerances are proportional to the column norms
+		sf := math.Sqrt(factor)
+		for i := range m.Tol {
+			m.Tol[i] *= sf
+		}
+	}
+	for k := range m.Rss_set {
+		m.Rss_set[k] = false
+	}
+
+	m.AccumWeightSum *= factor
+	m.DecayedNobs *= factor
+	m.XStats.Decay(factor)
+	m.YStats.Decay(factor)
+}
+
+// NobsEffective(): the number of observations, as it counts towards the
+// degrees of freedom. This is Nobs, unless Decay() has discounted it.
+func (m *MillerLSQ) NobsEffective() float64 {
+	if m.Decayed {
+		return m.DecayedNobs
+	}
+	return float64(m.Nobs)
+}
+
+// ResidualDf(): the residual degrees of freedom of a model with nreq
+// coefficients, counting the constant if there is one.
+func (m *MillerLSQ) ResidualDf(nreq int) float64 {
+	return m.NobsEffective() - float64(nreq)
+}
 
 //    Regcf(): This returns the least-squares regression coefficients in array beta.
 //
-//     wxcol = which of the x-variables you want in your model. The constant/intercept
-//      term is always included and is the 0-th term. So the contents of wxcol should
-//      not include zero but should start at 1, 2, 3, ... for instance, if a model
-//      using the first three x-variables is desired.
+//     wxcol = which positions of the current m.Vorder you want in your model. Only
+//      len(wxcol) is used: the model fitted is always the one formed by the first
+//      len(wxcol)+1 variables in m.Vorder, the constant/intercept term being in the
+//      0-th position. So the contents of wxcol should not include zero but should
+//      be 1, 2, 3, ... for instance, if a model using the first three positions is
+//      desired. beta[i] is the coefficient of variable m.Vorder[i].
+//
+//      Without an intercept (see NewMillerLSQIntercept()) there is no constant
+//      to count, and the model is formed by the first len(wxcol) variables.
+//
+//      To fit a model using a particular set of x-variables, regardless of
+//      their position in m.Vorder, use RegcfSubset() instead.
 //
 //     wycol = which single y column you want to regress on. 0-based.
 //
@@ -704,28 +1019,16 @@ func (m *MillerLSQ) Includ(weight float64, xrow []float64, yrow []float64, nanap
 
 func (m *MillerLSQ) Regcf(wxcol []int, wycol int) (err error, beta []float64) {
 
-	var nreq int = len(wxcol) + 1
+	var nreq int = len(wxcol) + m.nconst()
 	beta = make([]float64, nreq)
 
 	if wycol < 0 {
 		panic("wcol cannot be negative")
 	}
 	if wycol >= len(m.Rhs) {
-		panic(fmt.Sprintf("out of bounds: wycol==%d was >= len(m.Rhs)==%d", wycol, m.Rhs))
+		panic(fmt.Sprintf("out of bounds: wycol==%d was >= len(m.Rhs)==%d", wycol, len(m.Rhs)))
 	}
 
-	// put the [xcol] right after the Constant
-	/* // problem: this is changing the beta output, when it shouldn't, should it?!?.
-	// to be fixed later
-		var err error
-		if len(wxcol) > 0 {
-			err = m.Reorder(wxcol, 1)
-			if err != nil {
-				panic(err)
-			}
-		}
-	*/
-
 	var i, j, nextr int
 
 	//     Some checks.
@@ -752,7 +1055,7 @@ func (m *MillerLSQ) Regcf(wxcol []int, wycol int) (err error, beta []float64) {
 			m.D[i-Adj] = 0.0
 
 			badList = append(badList, i-Adj)
-			res = errors.New(fmt.Sprintf("Regcf() error, singular variable(s) at locations: %v.", badList))
+			res = errors.New(fmt.Sprintf("Regcf() error, singular variable(s) at locations: %v; Collinearity() reports what they are aliased with.", badList))
 		} else {
 			beta[i-Adj] = m.Rhs[wycol][i-Adj]
 			nextr = m.Row_ptr[i-Adj]
@@ -766,6 +1069,119 @@ func (m *MillerLSQ) Regcf(wxcol []int, wycol int) (err error, beta []float64) {
 	return res, beta
 }
 
+// RegcfSubset(): fit the model using the constant plus exactly the
+//  x-variables listed in wxcol, wherever they currently sit in m.Vorder.
+//
+//  The variables in wxcol are moved with Reorder() to the positions right
+//  after the intercept, Regcf() is called, and then the factorization is
+//  put back into its original order, so the caller's m.Vorder is unchanged.
+//  If you intend to fit the same subset repeatedly, it is cheaper to call
+//  Reorder() once yourself and then use Regcf() directly.
+//
+//  beta is keyed by original variable number, not by Vorder position:
+//  beta[0] is the intercept, if there is one, and the coefficients of
+//  the variables in wxcol follow in increasing variable number, whatever
+//  the order of wxcol, just as FitSubset() gives them.
+//
+func (m *MillerLSQ) RegcfSubset(wxcol []int, wycol int) (err error, beta []float64) {
+	err = m.checkSubset(wxcol)
+	if err != nil {
+		return err, nil
+	}
+
+	saved := make([]int, m.Ncol)
+	copy(saved, m.Vorder)
+	defer func() {
+		rerr := m.restoreOrder(saved)
+		if err == nil {
+			err = rerr
+		}
+	}()
+
+	err = m.moveSubsetToFront(wxcol)
+	if err != nil {
+		return err, nil
+	}
+
+	err, posBeta := m.Regcf(Seq(len(wxcol)), wycol)
+
+	// translate from Vorder positions to variable numbers
+	where := make(map[int]int, len(posBeta))
+	for p := range posBeta {
+		where[m.Vorder[p]] = p
+	}
+	vars := DeepCopyInts(wxcol)
+	sort.Ints(vars)
+	c := m.nconst()
+	beta = make([]float64, len(vars)+c)
+	if c == 1 {
+		beta[0] = posBeta[where[0]]
+	}
+	for k, v := range vars {
+		beta[k+c] = posBeta[where[v]]
+	}
+	return err, beta
+}
+
+// checkSubset(): validate a list of x-variable numbers; each must be in
+// [1, m.Nxvar], and none may be repeated.
+func (m *MillerLSQ) checkSubset(wxcol []int) error {
+	seen := make(map[int]bool, len(wxcol))
+	for _, v := range wxcol {
+		if v < 1 || v > m.Nxvar {
+			return errors.New(fmt.Sprintf("subset error: variable %d is out of the range [1, %d]", v, m.Nxvar))
+		}
+		if seen[v] {
+			return errors.New(fmt.Sprintf("subset error: variable %d is listed more than once in %v", v, wxcol))
+		}
+		seen[v] = true
+	}
+	return nil
+}
+
+// moveSubsetToFront(): put the constant in position 0, and the variables
+// of wxcol in positions 1..len(wxcol) of m.Vorder. Without an intercept,
+// the variables of wxcol go in positions 0..len(wxcol)-1.
+func (m *MillerLSQ) moveSubsetToFront(wxcol []int) error {
+	err := m.constantToFront()
+	if err != nil {
+		return err
+	}
+	return m.Reorder(wxcol, m.nconst())
+}
+
+// constantToFront(): move the constant, if there is one, to position 0.
+func (m *MillerLSQ) constantToFront() error {
+	if m.NoIntercept || m.Vorder[0] == 0 {
+		return nil
+	}
+	return m.Reorder([]int{0}, 0)
+}
+
+// NullRss(): the residual sum of squares of the null model for the
+// wycol-th y-target. With an intercept, that is the model with only the
+// constant, and the sum of squares of y about its mean; the constant must
+// be in the first position of m.Vorder. Without an intercept, the null
+// model is empty, and this is the sum of squares of y about zero.
+func (m *MillerLSQ) NullRss(wycol int) float64 {
+	if !m.Rss_set[wycol] {
+		m.SS(wycol)
+	}
+	if m.NoIntercept {
+		return m.rssFirst(wycol, 0)
+	}
+	return m.Rss[wycol][0]
+}
+
+// rssFirst(): the residual sum of squares with the variables in the first
+// k positions of m.Vorder in the model, for k >= 0.
+func (m *MillerLSQ) rssFirst(wycol int, k int) float64 {
+	if k == 0 {
+		return m.Rss[wycol][0] + m.D[0]*m.Rhs[wycol][0]*m.Rhs[wycol][0]
+	}
+	return m.Rss[wycol][k-1]
+}
+
 var EpsilonFloat64 float64 = math.Nextafter(1.0, 2.0) - 1.0 // 2.220446049250313e-16
 
 func (m *MillerLSQ) Tolset(eps float64) {
@@ -916,13 +1332,20 @@ func (m *MillerLSQ) SingularCheck(lindep *[]bool, wycol int) int {
 				m.Rhs[wycol][row-Adj] = 0.0
 
 				//fmt.Printf("weight=%v   x=%v   y=%v\n", weight, x, y)
-				m.Includ(weight, x[1:], []float64{y}, NAN_TO_ZERO)
-				// INCLUD automatically increases the number
-				// of cases each time it is called. compensate by decreasing m.Nobs
-				m.Nobs--
-				m.RowsSeen--
+				// Rotate the row straight into the rows below with givens(),
+				// rather than Includ(), which would count it as a new
+				// observation, and expects an x-row without the constant.
+				if weight != 0 {
+					copy(m.Curxrow, x)
+					zero_out(m.Curyrow)
+					m.Curyrow[wycol] = y
+					m.givens(weight)
+				}
 			} else {
-				m.Sserr[0] = m.Sserr[0] + m.D[row-Adj]*m.Rhs[wycol][row-Adj]*m.Rhs[wycol][row-Adj]
+				m.Sserr[wycol] = m.Sserr[wycol] + m.D[row-Adj]*m.Rhs[wycol][row-Adj]*m.Rhs[wycol][row-Adj]
+				if len(m.Sscp) == m.Nyvar*m.Nyvar {
+					m.Sscp[wycol*m.Nyvar+wycol] = m.Sserr[wycol]
+				}
 			}
 		}
 	}
@@ -965,7 +1388,7 @@ func (m *MillerLSQ) SS(wycol int) {
 //     Fills in covmat, sterr as the major intended effect.
 //
 //     nreq, as above, is the number of regression coefficients to be
-//     accounted for, include a count of one for the constant term.
+//     accounted for, include a count of one for the constant term, if any.
 //--------------------------------------------------------------------------
 
 func (m *MillerLSQ) Cov(nreq int, covmat []float64, sterr []float64, wycol int) (err error, Variance float64) {
@@ -992,13 +1415,13 @@ func (m *MillerLSQ) Cov(nreq int, covmat []float64, sterr []float64, wycol int)
 
 	//     Calculate estimate of the residual variance.
 
-	if m.Nobs > int64(nreq) {
+	if m.ResidualDf(nreq) > 0 {
 		if !m.Rss_set[wycol] {
 			m.SS(wycol)
 		}
-		Variance = m.Rss[wycol][nreq-Adj] / float64(m.Nobs-int64(nreq))
+		Variance = m.Rss[wycol][nreq-Adj] / m.ResidualDf(nreq)
 	} else {
-		return errors.New(fmt.Sprintf("Cov() error: m.Nobs(%v) <= nreq(%v)", m.Nobs, nreq)), 0
+		return errors.New(fmt.Sprintf("Cov() error: m.Nobs(%v) <= nreq(%v)", m.NobsEffective(), nreq)), 0
 	}
 
 	m.Dim_rinv = nreq * (nreq - 1) / 2
@@ -1418,6 +1841,35 @@ forty40:
 	return nil
 } // end reordr
 
+// restoreOrder(): use Vmove() to return the variables to exactly the
+// order given in saved, typically a copy of m.Vorder taken before a
+// call to Reorder(). Unlike Reorder(), the order within saved is honored.
+func (m *MillerLSQ) restoreOrder(saved []int) error {
+	if len(saved) != m.Ncol {
+		return errors.New(fmt.Sprintf("restoreOrder() error: len(saved)==%d but m.Ncol==%d", len(saved), m.Ncol))
+	}
+	for pos := 0; pos < m.Ncol; pos++ {
+		if m.Vorder[pos] == saved[pos] {
+			continue
+		}
+		from := -1
+		for i := pos + 1; i < m.Ncol; i++ {
+			if m.Vorder[i] == saved[pos] {
+				from = i
+				break
+			}
+		}
+		if from < 0 {
+			return errors.New(fmt.Sprintf("restoreOrder() error: could not find variable %d after position %d", saved[pos], pos))
+		}
+		err := m.Vmove(from+1, pos+1) // Vmove is 1-based
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *MillerLSQ) Hdiag(xrow []float64, nreq int) (hii float64, err error) {
 
 	//     ALGORITHM AS274  APPL. STATIST. (1992) VOL.41, NO. 2
@@ -1469,33 +1921,37 @@ func (m *MillerLSQ) Hdiag(xrow []float64, nreq int) (hii float64, err error) {
 // ---------------------------------------------------------------------
 //   Varprd()
 //     Calculate the variance of x'b where b consists of the first nreq
-//     least-squares regression coefficients.
+//     least-squares regression coefficients. x is in position order,
+//     as the variables currently stand in Vorder; see Predict() for
+//     the variance at a row of raw x-values. Bad input is an error.
 //
 // ---------------------------------------------------------------------
 
-func (m *MillerLSQ) Varprd(x []float64, nreq int, wycol int) float64 {
+func (m *MillerLSQ) Varprd(x []float64, nreq int, wycol int) (float64, error) {
 
-	var ifault, row int
+	var row int
 	wk := make([]float64, nreq)
 	var Variance float64
 
 	//     Check input parameter values
 
 	var fn_val float64
-	ifault = 0
 	if nreq < 1 || nreq > m.Ncol {
-		ifault = ifault + 4
+		return 0, errors.New(fmt.Sprintf("Varprd() error: nreq=%v must be in [1, m.Ncol=%v]", nreq, m.Ncol))
 	}
-	if m.Nobs <= int64(nreq) {
-		ifault = ifault + 8
+	if len(x) < nreq {
+		return 0, errors.New(fmt.Sprintf("Varprd() error: len(x)=%v was < nreq=%v", len(x), nreq))
 	}
-	if ifault != 0 {
-		panic(fmt.Sprintf("Error in function Varprd: ifault = %v\n", ifault))
+	if wycol < 0 || wycol >= m.Nyvar {
+		return 0, errors.New(fmt.Sprintf("Varprd() error: wycol=%v out of range [0, %v)", wycol, m.Nyvar))
+	}
+	if m.ResidualDf(nreq) <= 0 {
+		return 0, errors.New(fmt.Sprintf("Varprd() error: no residual degrees of freedom with nreq=%v and %v observations", nreq, m.NobsEffective()))
 	}
 
 	//     Calculate the residual variance estimate.
 
-	Variance = m.Sserr[wycol] / float64(m.Nobs-int64(nreq))
+	Variance = m.Sserr[wycol] / m.ResidualDf(nreq)
 
 	//     Variance of x'b = var.x'(inv R)(inv D)(inv R')x
 	//     First call BKSUB2 to calculate (inv R')x by back-substitution.
@@ -1509,7 +1965,7 @@ func (m *MillerLSQ) Varprd(x []float64, nreq int, wycol int) float64 {
 
 	fn_val = fn_val * Variance
 
-	return fn_val
+	return fn_val, nil
 }
 
 //--------------------------------------------------------------------------
@@ -1872,6 +2328,7 @@ func CompareLSQ(a, b *MillerLSQ) bool {
 	deepcheck(a.Ysd, b.Ysd, "Ysd")
 	deepcheck(a.Initialized, b.Initialized, "Initialized")
 	floatcheck(a.Sserr, b.Sserr, "Sserr")
+	floatcheck(a.Sscp, b.Sscp, "Sscp")
 	deepcheck(a.Toly, b.Toly, "Toly")
 	deepcheck(a.Vorder, b.Vorder, "Vorder")
 	for i := range a.Rhs {
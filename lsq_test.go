@@ -668,7 +668,11 @@ DLic             13.75        1.837     7.49          191302      2.239e-09
 		}
 		std_resid[i] = resid[i] / math.Sqrt(Variance*(1.0-hii))
 
-		std_err_pred = math.Sqrt(m.Varprd(xx, nreq, wycol))
+		vp, err := m.Varprd(xx, nreq, wycol)
+		if err != nil {
+			panic(err)
+		}
+		std_err_pred = math.Sqrt(vp)
 		vStdErrPred[i] = std_err_pred
 
 		fmt.Printf("%s  %10.1f%10.1f%10.1f  %9.2f       %10.0f\n", df.Rownames[i], y[i], fitted, resid[i], std_resid[i], std_err_pred)
@@ -701,3 +705,249 @@ DLic             13.75        1.837     7.49          191302      2.239e-09
 	})
 
 }
+
+func TestRegcfSubsetHonorsVariableNumbers(t *testing.T) {
+
+	nvar := 7
+	m := NewMillerLSQ(nvar, 1)
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+	for i := range df.Rows {
+		m.Includ(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+	}
+
+	origVorder := DeepCopyInts(m.Vorder)
+	origD := DeepCopy(m.D)
+
+	// Const, Tax, Income, RoadMls, DLic; the same model as in TestReorderBetasCovarReporting,
+	// but requested by variable number, and in a scrambled order.
+	err, beta := m.RegcfSubset([]int{7, 2, 5, 4}, 0)
+
+	knownGoodBeta := []float64{377.29114647367385, -34.790149164432734, -66.58875178694376, -2.4258888852597504, 13.364493572600963}
+
+	cv.Convey("RegcfSubset() should fit exactly the requested variables, keyed in increasing variable number, and leave Vorder as it found it", t, func() {
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(len(beta), cv.ShouldEqual, 5)
+		cv.So(EpsSliceEqual(beta, knownGoodBeta, 1e-8), cv.ShouldBeTrue)
+
+		cv.So(IntSliceEqual(m.Vorder, origVorder), cv.ShouldBeTrue)
+		cv.So(EpsSliceEqual(m.D, origD, 1e-6), cv.ShouldBeTrue)
+	})
+
+	cv.Convey("RegcfSubset() should key its betas as FitSubset() does", t, func() {
+		fit, err := m.FitSubset([]int{7, 2, 5, 4}, 0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(IntSliceEqual(fit.Vars, []int{0, 2, 4, 5, 7}), cv.ShouldBeTrue)
+		cv.So(EpsSliceEqual(fit.Coef, beta, 1e-8), cv.ShouldBeTrue)
+
+		cv.So(IntSliceEqual(m.Vorder, origVorder), cv.ShouldBeTrue)
+		cv.So(EpsSliceEqual(m.D, origD, 1e-6), cv.ShouldBeTrue)
+	})
+
+	cv.Convey("RegcfSubset() should give the same answer when the factorization starts out in a different order", t, func() {
+		err := m.Reorder([]int{3, 6, 1}, 1)
+		cv.So(err, cv.ShouldBeNil)
+		reordered := DeepCopyInts(m.Vorder)
+
+		err, beta2 := m.RegcfSubset([]int{7, 2, 5, 4}, 0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(EpsSliceEqual(beta, beta2, 1e-8), cv.ShouldBeTrue)
+		cv.So(IntSliceEqual(m.Vorder, reordered), cv.ShouldBeTrue)
+	})
+
+	cv.Convey("RegcfSubset() should reject variable numbers that are out of range or repeated", t, func() {
+		err, _ := m.RegcfSubset([]int{0, 2}, 0)
+		cv.So(err, cv.ShouldNotBeNil)
+		err, _ = m.RegcfSubset([]int{8}, 0)
+		cv.So(err, cv.ShouldNotBeNil)
+		err, _ = m.RegcfSubset([]int{2, 2}, 0)
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
+
+func TestNoIntercept(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+
+	// lm(Fuel_Pop ~ Tax + Income + DLic - 1)
+	xcols := []int{2, 4, 7}
+	last := df.Ncol - 1
+
+	m := NewMillerLSQIntercept(len(xcols), 1, !AddIntercept)
+	xrow := make([]float64, len(xcols))
+	for i := range df.Rows {
+		for k, j := range xcols {
+			xrow[k] = df.Rows[i][j]
+		}
+		m.Includ(1.0, xrow, df.Rows[i][last:], NAN_OMIT_ROW)
+	}
+
+	// from the exact solution of the normal equations
+	knownGoodBeta := []float64{-15.266913677762366, -57.480727143291155, 16.420826805641294}
+	knownGoodRss := 207998.35451188582
+	knownGoodTss := 16556267.0 // sum of y^2, since there is no intercept
+
+	cv.Convey("Given a MillerLSQ made without an intercept, there should be no constant column", t, func() {
+		cv.So(m.HasIntercept(), cv.ShouldBeFalse)
+		cv.So(m.Ncol, cv.ShouldEqual, 3)
+		cv.So(IntSliceEqual(m.Vorder, []int{1, 2, 3}), cv.ShouldBeTrue)
+
+		cv.Convey("and Regcf() and RegcfSubset() should fit through the origin", func() {
+			err, beta := m.Regcf(Seq(3), 0)
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(EpsSliceEqual(beta, knownGoodBeta, 1e-8), cv.ShouldBeTrue)
+
+			err, beta = m.RegcfSubset([]int{3, 1, 2}, 0)
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(len(beta), cv.ShouldEqual, 3)
+			cv.So(EpsSliceEqual(beta, knownGoodBeta, 1e-8), cv.ShouldBeTrue)
+			cv.So(IntSliceEqual(m.Vorder, []int{1, 2, 3}), cv.ShouldBeTrue)
+		})
+
+		cv.Convey("and Fit() should report the uncentered R-squared and F-test, as R does for y ~ x - 1", func() {
+			fit, err := m.Fit(0)
+			cv.So(err, cv.ShouldBeNil)
+			fmt.Printf("\n%s\n", fit)
+			cv.So(fit.Names[0], cv.ShouldEqual, "x1")
+			cv.So(IntSliceEqual(fit.Vars, []int{1, 2, 3}), cv.ShouldBeTrue)
+			cv.So(EpsSliceEqual(fit.Coef, knownGoodBeta, 1e-8), cv.ShouldBeTrue)
+			cv.So(fit.DfModel, cv.ShouldEqual, 3)
+			cv.So(fit.DfResid, cv.ShouldEqual, 45)
+			cv.So(fit.Rss, cv.ShouldAlmostEqual, knownGoodRss, 1e-6)
+			cv.So(fit.Tss, cv.ShouldAlmostEqual, knownGoodTss, 1e-4)
+			cv.So(fit.Rsquared, cv.ShouldAlmostEqual, 1-knownGoodRss/knownGoodTss, 1e-10)
+			cv.So(fit.AdjRsquared, cv.ShouldAlmostEqual, 1-(knownGoodRss/knownGoodTss)*48/45, 1e-10)
+			cv.So(fit.Sigma, cv.ShouldAlmostEqual, 67.98665792506412, 1e-8)
+			cv.So(fit.Fstat, cv.ShouldAlmostEqual, 1178.9710080052998, 1e-6)
+
+			sub, err := m.FitSubset([]int{2, 3}, 0)
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(sub.DfModel, cv.ShouldEqual, 2)
+			cv.So(sub.Tss, cv.ShouldAlmostEqual, knownGoodTss, 1e-4)
+		})
+
+		cv.Convey("and BestSubsets() should start from the empty model", func() {
+			res, err := m.BestSubsets(0, SubsetOptions{})
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(res.BySize[0][0].Rss, cv.ShouldAlmostEqual, knownGoodTss, 1e-4)
+			cv.So(res.BySize[3][0].Rss, cv.ShouldAlmostEqual, knownGoodRss, 1e-6)
+			for size := 1; size <= 2; size++ {
+				best := res.BySize[size][0]
+				fit, err := m.FitSubset(best.Vars, 0)
+				cv.So(err, cv.ShouldBeNil)
+				cv.So(best.Rss, cv.ShouldAlmostEqual, fit.Rss, 1e-6)
+			}
+		})
+
+		cv.Convey("and QR2Cov() should have no column of ones", func() {
+			cov, means, N := m.QR2Cov(0)
+			cv.So(cov.Ncol, cv.ShouldEqual, 4)
+			cv.So(len(means), cv.ShouldEqual, 4)
+			cv.So(N, cv.ShouldEqual, 48)
+			cv.So(means[0], cv.ShouldAlmostEqual, m.XStats.Mean()[0], 1e-10)
+		})
+	})
+}
+
+func TestForgettingFactor(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+	n := len(df.Rows)
+	lambda := 0.95
+
+	// forgetting, row by row
+	forget := NewMillerLSQ(7, 1)
+	forget.ForgettingFactor = lambda
+
+	// the same thing, by weighting each row by lambda^(rows that follow it)
+	weighted := NewMillerLSQ(7, 1)
+
+	for i := range df.Rows {
+		forget.Includ(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+		weighted.Includ(math.Pow(lambda, float64(n-1-i)), df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+	}
+
+	relEqual := func(a, b []float64) bool {
+		for i := range a {
+			if math.Abs(a[i]-b[i]) > 1e-9*(1+math.Abs(b[i])) {
+				fmt.Printf("at %d: %v != %v\n", i, a[i], b[i])
+				return false
+			}
+		}
+		return true
+	}
+
+	cv.Convey("Given a ForgettingFactor, Includ() should give the same factorization as exponentially decreasing weights", t, func() {
+		cv.So(relEqual(forget.D, weighted.D), cv.ShouldBeTrue)
+		cv.So(relEqual(forget.R, weighted.R), cv.ShouldBeTrue)
+		cv.So(relEqual(forget.Rhs[0], weighted.Rhs[0]), cv.ShouldBeTrue)
+		cv.So(relEqual(forget.Sserr, weighted.Sserr), cv.ShouldBeTrue)
+		cv.So(forget.AccumWeightSum, cv.ShouldAlmostEqual, weighted.AccumWeightSum, 1e-10)
+		cv.So(relEqual(forget.XStats.Mean(), weighted.XStats.Mean()), cv.ShouldBeTrue)
+		cv.So(relEqual(forget.XStats.W, weighted.XStats.W), cv.ShouldBeTrue)
+		cv.So(relEqual(forget.XStats.Q, weighted.XStats.Q), cv.ShouldBeTrue)
+
+		cv.Convey("and the same coefficients, but with the discounted count of observations in the degrees of freedom", func() {
+			f1, err := forget.Fit(0)
+			cv.So(err, cv.ShouldBeNil)
+			f2, err := weighted.Fit(0)
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(relEqual(f1.Coef, f2.Coef), cv.ShouldBeTrue)
+
+			neff := (1 - math.Pow(lambda, float64(n))) / (1 - lambda)
+			cv.So(forget.NobsEffective(), cv.ShouldAlmostEqual, neff, 1e-10)
+			cv.So(f1.DfResid, cv.ShouldAlmostEqual, neff-8, 1e-10)
+			cv.So(f2.DfResid, cv.ShouldEqual, float64(n-8))
+		})
+	})
+
+	cv.Convey("An explicit Decay() should be the same as having included the earlier rows at the discounted weight", t, func() {
+		decayed := NewMillerLSQ(7, 1)
+		halved := NewMillerLSQ(7, 1)
+		for i := range df.Rows {
+			w := 1.0
+			if i < 30 {
+				w = 0.5
+			}
+			halved.Includ(w, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+			decayed.Includ(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+			if i == 29 {
+				decayed.Decay(0.5)
+			}
+		}
+		cv.So(relEqual(decayed.D, halved.D), cv.ShouldBeTrue)
+		cv.So(relEqual(decayed.R, halved.R), cv.ShouldBeTrue)
+		cv.So(relEqual(decayed.Rhs[0], halved.Rhs[0]), cv.ShouldBeTrue)
+		cv.So(relEqual(decayed.Sserr, halved.Sserr), cv.ShouldBeTrue)
+		cv.So(decayed.NobsEffective(), cv.ShouldAlmostEqual, 15+18, 1e-12)
+
+		panicked := func() (p bool) {
+			defer func() { p = recover() != nil }()
+			decayed.Decay(1.5)
+			return
+		}()
+		cv.So(panicked, cv.ShouldBeTrue)
+
+		// Reset() should forget the discounting too
+		decayed.Reset()
+		for i := 0; i < 12; i++ {
+			decayed.Includ(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		cv.So(decayed.Decayed, cv.ShouldBeFalse)
+		cv.So(decayed.NobsEffective(), cv.ShouldEqual, float64(decayed.Nobs))
+		f, err := decayed.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(f.DfResid, cv.ShouldEqual, float64(12-8))
+	})
+}
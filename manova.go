@@ -0,0 +1,386 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Multivariate regression: the y-targets share the x-variables, and so
+// the factorization, and their residuals are correlated. m.Sscp holds
+// the residual sums of squares and cross-products of every pair of
+// y-targets, built up by Includ() from the residuals left in Curyrow,
+// just as Sserr is from their squares.
+//
+// With E the residual cross-products of the full model and H the
+// reduction in them from adding a term, the multivariate tests of the
+// term are functions of the eigenvalues of inv(E) H:
+//
+//	Wilks' lambda       prod 1/(1+l)
+//	Pillai's trace      sum l/(1+l)
+//	Hotelling-Lawley    sum l
+//	Roy's largest root  max l
+//
+// each with the F approximation of R's summary.manova(). With one
+// y-target all four are the F test of Anova().
+
+// MultiStat is one of the multivariate test statistics of a term, with
+// its F approximation.
+type MultiStat struct {
+	Name   string
+	Value  float64
+	F      float64
+	Df1    float64
+	Df2    float64
+	Pvalue float64
+}
+
+// ManovaRow is one term's line of a ManovaTable.
+type ManovaRow struct {
+	Term Term
+	Df   float64
+
+	// H is the hypothesis cross-products of the term: the reduction in
+	// the residual cross-products from adding it.
+	H *SquareMatrix
+
+	Eigenvalues []float64 // of inv(E) H, in decreasing order
+
+	Wilks           MultiStat
+	Pillai          MultiStat
+	HotellingLawley MultiStat
+	Roy             MultiStat
+}
+
+// ManovaTable is returned by Manova().
+type ManovaTable struct {
+	Type   AnovaType
+	Ycols  []int
+	Ynames []string // from a Model; otherwise y0, y1, ...
+	Rows   []*ManovaRow
+
+	// the residual cross-products, E, of the model of every term
+	DfResid float64
+	E       *SquareMatrix
+}
+
+// yCross(): the Nyvar by Nyvar residual cross-products, row-major, with
+// the variables in the first k positions in the model; with k = 0, the
+// raw cross-products of the y-targets.
+func (m *MillerLSQ) yCross(k int) []float64 {
+	ny := m.Nyvar
+	res := DeepCopy(m.Sscp)
+	for i := 0; i < ny; i++ {
+		for j := 0; j <= i; j++ {
+			sum := res[i*ny+j]
+			for pos := k; pos < m.Ncol; pos++ {
+				sum += m.D[pos] * m.Rhs[i][pos] * m.Rhs[j][pos]
+			}
+			res[i*ny+j] = sum
+			res[j*ny+i] = sum
+		}
+	}
+	return res
+}
+
+// ySub(): the rows and columns ycols of the Nyvar by Nyvar cross, back
+// in the units of y.
+func (m *MillerLSQ) ySub(cross []float64, ycols []int) *SquareMatrix {
+	s := NewSquareMatrix(len(ycols))
+	for a, i := range ycols {
+		for b, j := range ycols {
+			v := cross[i*m.Nyvar+j]
+			if m.UseMeanSd && m.Ysd[i] != 0.0 && m.Ysd[j] != 0.0 {
+				v *= m.Ysd[i] * m.Ysd[j]
+			}
+			s.Set(a, b, v)
+		}
+	}
+	return s
+}
+
+// checkYcols(): an error if ycols is empty, repeats a y-target or is
+// out of range, or if m has no residual cross-products to go with them.
+func (m *MillerLSQ) checkYcols(ycols []int, caller string) error {
+	if len(ycols) == 0 {
+		return errors.New(fmt.Sprintf("%s error: no y-targets given", caller))
+	}
+	seen := make(map[int]bool, len(ycols))
+	for _, j := range ycols {
+		if j < 0 || j >= m.Nyvar {
+			return errors.New(fmt.Sprintf("%s error: y-target %d out of range [0, %d)", caller, j, m.Nyvar))
+		}
+		if seen[j] {
+			return errors.New(fmt.Sprintf("%s error: y-target %d given twice", caller, j))
+		}
+		seen[j] = true
+	}
+	if len(m.Sscp) != m.Nyvar*m.Nyvar {
+		return errors.New(fmt.Sprintf("%s error: no residual cross-products; this model was saved before they were kept", caller))
+	}
+	return nil
+}
+
+// ResidualSSCP(): the residual sums of squares and cross-products of
+// the y-targets ycols, with every x-variable in the model; its
+// diagonal is their Sserr.
+func (m *MillerLSQ) ResidualSSCP(ycols []int) (*SquareMatrix, error) {
+	err := m.checkYcols(ycols, "ResidualSSCP()")
+	if err != nil {
+		return nil, err
+	}
+	return m.ySub(m.Sscp, ycols), nil
+}
+
+// ResidualCov(): the residual covariance matrix of the y-targets ycols,
+// ResidualSSCP() over the residual degrees of freedom, with every
+// x-variable in the model.
+func (m *MillerLSQ) ResidualCov(ycols []int) (*SquareMatrix, error) {
+	s, err := m.ResidualSSCP(ycols)
+	if err != nil {
+		return nil, err
+	}
+	df := m.ResidualDf(m.Ncol)
+	if df <= 0 {
+		return nil, errors.New(fmt.Sprintf("ResidualCov() error: %v residual degrees of freedom", df))
+	}
+	s.DivideBy(df)
+	return s, nil
+}
+
+// Manova(): the multivariate analysis of variance table of type typ,
+// as for Anova(), for the y-targets ycols together on the model made of
+// terms. With terms nil, each x-variable is a term by itself, in order.
+// m.Vorder is restored before returning.
+func (m *MillerLSQ) Manova(ycols []int, terms []Term, typ AnovaType) (res *ManovaTable, err error) {
+	if terms == nil {
+		terms = SingleTerms(Seq(m.Nxvar))
+	}
+	err = m.checkYcols(ycols, "Manova()")
+	if err != nil {
+		return nil, err
+	}
+	if typ < ANOVA_TYPE_I || typ > ANOVA_TYPE_III {
+		return nil, errors.New(fmt.Sprintf("Manova() error: unknown type %v", typ))
+	}
+	all := []int{}
+	for _, t := range terms {
+		if len(t.Vars) == 0 {
+			return nil, errors.New(fmt.Sprintf("Manova() error: term %s has no variables", t.Name))
+		}
+		all = append(all, t.Vars...)
+	}
+	err = m.checkSubset(all)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := m.startShuffle()
+	defer func() {
+		rerr := m.restoreOrder(saved)
+		if err == nil && rerr != nil {
+			res, err = nil, rerr
+		}
+	}()
+
+	c := m.nconst()
+	err = m.moveSubsetToFront(all)
+	if err != nil {
+		return nil, err
+	}
+	p := len(ycols)
+	res = &ManovaTable{
+		Type:    typ,
+		Ycols:   DeepCopyInts(ycols),
+		Ynames:  make([]string, p),
+		DfResid: m.ResidualDf(c + len(all)),
+		E:       m.ySub(m.yCross(c+len(all)), ycols),
+	}
+	for a, j := range ycols {
+		res.Ynames[a] = fmt.Sprintf("y%d", j)
+	}
+	if res.DfResid < float64(p) {
+		return nil, errors.New(fmt.Sprintf("Manova() error: %v residual degrees of freedom is too few for %d y-targets", res.DfResid, p))
+	}
+
+	// with E = L L', the eigenvalues of inv(E) H are those of
+	// inv(L) H inv(L)'
+	L, cerr, _ := res.E.FactorToCholeskyLower(CHOL_RETURN_FRESH_MATRIX)
+	if cerr != nil {
+		return nil, errors.New(fmt.Sprintf("Manova() error: the residual cross-products are not positive definite: %s", cerr))
+	}
+	for i := 0; i < p; i++ {
+		// relative to the residual sum of squares of y-target i, what
+		// is left of it after the y-targets before it
+		if L.At(i, i) <= 1e-7*math.Sqrt(res.E.At(i, i)) {
+			return nil, errors.New(fmt.Sprintf("Manova() error: the residuals of y-target %d are a linear combination of those before it", ycols[i]))
+		}
+	}
+
+	bases := anovaBases(terms, all, typ)
+	for i, t := range terms {
+		base := bases[i]
+		err := m.moveSubsetToFront(base)
+		if err != nil {
+			return nil, err
+		}
+		err = m.Reorder(t.Vars, c+len(base))
+		if err != nil {
+			return nil, err
+		}
+		before := m.yCross(c + len(base))
+		after := m.yCross(c + len(base) + len(t.Vars))
+		for k := range before {
+			before[k] -= after[k]
+		}
+		row := &ManovaRow{
+			Term: t,
+			Df:   float64(len(t.Vars)),
+			H:    m.ySub(before, ycols),
+		}
+		row.Eigenvalues, _ = symmetricEigen(lowerSandwich(L, row.H))
+		for k, l := range row.Eigenvalues {
+			// H is positive semi-definite; anything below zero is rounding
+			if l < 0 {
+				row.Eigenvalues[k] = 0
+			}
+		}
+		row.Wilks, row.Pillai, row.HotellingLawley, row.Roy = multiStats(row.Eigenvalues, float64(p), row.Df, res.DfResid)
+		res.Rows = append(res.Rows, row)
+	}
+	return res, nil
+}
+
+// Manova(): as MillerLSQ.Manova(), with each variable named from
+// mod.Xnames when terms is nil, and the y-targets from mod.Ynames.
+func (mod *Model) Manova(ycols []int, terms []Term, typ AnovaType) (*ManovaTable, error) {
+	if terms == nil {
+		terms = mod.SingleTerms(Seq(mod.Nxvar))
+	}
+	res, err := mod.MillerLSQ.Manova(ycols, terms, typ)
+	if err != nil {
+		return nil, err
+	}
+	for a, j := range res.Ycols {
+		if j < len(mod.Ynames) {
+			res.Ynames[a] = mod.Ynames[j]
+		}
+	}
+	return res, nil
+}
+
+// lowerSandwich(): inv(L) H inv(L)', for L lower triangular with a
+// positive diagonal and H symmetric.
+func lowerSandwich(L *SquareMatrix, h *SquareMatrix) *SquareMatrix {
+	n := L.Ncol
+	// solve L Z = B for each column of B
+	solve := func(b *SquareMatrix) *SquareMatrix {
+		z := NewSquareMatrix(n)
+		for col := 0; col < n; col++ {
+			for i := 0; i < n; i++ {
+				s := b.At(i, col)
+				for k := 0; k < i; k++ {
+					s -= L.At(i, k) * z.At(k, col)
+				}
+				z.Set(i, col, s/L.At(i, i))
+			}
+		}
+		return z
+	}
+	// inv(L) H, and then inv(L) (inv(L) H)', which is the same as
+	// inv(L) H inv(L)' since H is symmetric
+	z := solve(h)
+	z.Transpose()
+	a := solve(z)
+	// symmetric, but for rounding
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			v := (a.At(i, j) + a.At(j, i)) / 2
+			a.Set(i, j, v)
+			a.Set(j, i, v)
+		}
+	}
+	return a
+}
+
+// multiStats(): the four multivariate statistics from the eigenvalues
+// of inv(E) H, for p y-targets, a term of q degrees of freedom and v
+// residual degrees of freedom, with the F approximations of R's
+// summary.manova().
+func multiStats(eig []float64, p, q, v float64) (wilks, pillai, hl, roy MultiStat) {
+	wilks.Name = "Wilks"
+	pillai.Name = "Pillai"
+	hl.Name = "Hotelling-Lawley"
+	roy.Name = "Roy"
+
+	wilks.Value = 1
+	for _, l := range eig {
+		wilks.Value /= 1 + l
+		pillai.Value += l / (1 + l)
+		hl.Value += l
+		if l > roy.Value {
+			roy.Value = l
+		}
+	}
+
+	// Rao's F for Wilks' lambda
+	tmp1 := v - 0.5*(p-q+1)
+	tmp2 := (p*q - 2) / 4
+	tmp3 := 1.0
+	if p*p+q*q-5 > 0 {
+		tmp3 = math.Sqrt((p*p*q*q - 4) / (p*p + q*q - 5))
+	}
+	wilks.Df1 = p * q
+	wilks.Df2 = tmp1*tmp3 - 2*tmp2
+	wilks.F = (math.Pow(wilks.Value, -1/tmp3) - 1) * wilks.Df2 / wilks.Df1
+
+	s := math.Min(p, q)
+	n := 0.5 * (v - p - 1)
+	mm := 0.5 * (math.Abs(p-q) - 1)
+	tmp1 = 2*mm + s + 1
+	tmp2 = 2*n + s + 1
+	pillai.Df1 = s * tmp1
+	pillai.Df2 = s * tmp2
+	pillai.F = (tmp2 / tmp1) * pillai.Value / (s - pillai.Value)
+
+	tmp2 = 2 * (s*n + 1)
+	hl.Df1 = s * tmp1
+	hl.Df2 = tmp2
+	hl.F = tmp2 * hl.Value / (s * s * tmp1)
+
+	tmp1 = math.Max(p, q)
+	roy.Df1 = tmp1
+	roy.Df2 = v - tmp1 + q
+	roy.F = roy.Df2 * roy.Value / roy.Df1
+
+	for _, st := range []*MultiStat{&wilks, &pillai, &hl, &roy} {
+		st.Pvalue = math.NaN()
+		if st.Df1 > 0 && st.Df2 > 0 {
+			st.Pvalue = Pf(st.F, st.Df1, st.Df2)
+		}
+	}
+	return
+}
+
+func (r *ManovaTable) String() string {
+	names := []string{""}
+	for _, row := range r.Rows {
+		names = append(names, row.Term.Name)
+	}
+	names = append(names, "Residuals")
+	names = NormalizeNameLengths(names)
+
+	s := fmt.Sprintf("Multivariate Analysis of Variance Table, %s, for y-targets %v\n", r.Type, r.Ynames)
+	s += fmt.Sprintf("%s  %6s  %-16s  %10s  %10s  %8s  %8s  %10s\n", names[0], "Df", "Test", "Statistic", "approx F", "num Df", "den Df", "Pr(>F)")
+	for i, row := range r.Rows {
+		for k, st := range []MultiStat{row.Pillai, row.Wilks, row.HotellingLawley, row.Roy} {
+			name, df := names[i+1], fmt.Sprintf("%6.0f", row.Df)
+			if k > 0 {
+				name, df = fmt.Sprintf("%*s", len(names[i+1]), ""), fmt.Sprintf("%6s", "")
+			}
+			s += fmt.Sprintf("%s  %s  %-16s  %10.5g  %10.5g  %8.4g  %8.4g  %10.4g\n", name, df, st.Name, st.Value, st.F, st.Df1, st.Df2, st.Pvalue)
+		}
+	}
+	s += fmt.Sprintf("%s  %6.0f\n", names[len(names)-1], r.DfResid)
+	return s
+}
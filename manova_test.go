@@ -0,0 +1,209 @@
+package lsq
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestManova(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+
+	// Fuel_Pop and FuelCon on Tax, Income, RoadMls, DLic
+	xcols := []int{2, 4, 5, 7}
+	names := []string{"Tax", "Income", "RoadMls", "DLic"}
+	ycols := []int{8, 6}
+	ynames := []string{"Fuel_Pop", "FuelCon"}
+
+	fill := func(vars []int, rows []int, y []int) *MillerLSQ {
+		m := NewMillerLSQ(len(vars), len(y))
+		xrow := make([]float64, len(vars))
+		yrow := make([]float64, len(y))
+		for _, i := range rows {
+			for k, v := range vars {
+				xrow[k] = df.Rows[i][xcols[v-1]]
+			}
+			for k, j := range y {
+				yrow[k] = df.Rows[i][j]
+			}
+			m.Includ(1, xrow, yrow, NAN_OMIT_ROW)
+		}
+		return m
+	}
+	allRows := Seq(len(df.Rows))
+	for i := range allRows {
+		allRows[i]--
+	}
+
+	// the residual cross-products, straight from the residuals of
+	// separate fits of each y-target on vars
+	direct := func(vars []int) [2][2]float64 {
+		var resid [2][]float64
+		for k, j := range ycols {
+			fit, err := fill(vars, allRows, []int{j}).Fit(0)
+			if err != nil {
+				panic(err)
+			}
+			for _, i := range allRows {
+				e := df.Rows[i][j] - fit.Coef[0]
+				for a, v := range vars {
+					e -= fit.Coef[a+1] * df.Rows[i][xcols[v-1]]
+				}
+				resid[k] = append(resid[k], e)
+			}
+		}
+		var s [2][2]float64
+		for a := 0; a < 2; a++ {
+			for b := 0; b < 2; b++ {
+				for i := range resid[a] {
+					s[a][b] += resid[a][i] * resid[b][i]
+				}
+			}
+		}
+		return s
+	}
+
+	cv.Convey("Given the fuelcons.dat data with two y-targets, Sscp should be the residual cross-products of the two fits, with Sserr on its diagonal", t, func() {
+		m := fill(Seq(4), allRows, ycols)
+		want := direct(Seq(4))
+		e, err := m.ResidualSSCP([]int{0, 1})
+		cv.So(err, cv.ShouldBeNil)
+		for a := 0; a < 2; a++ {
+			cv.So(m.Sscp[a*2+a], cv.ShouldEqual, m.Sserr[a])
+			for b := 0; b < 2; b++ {
+				cv.So(e.At(a, b), cv.ShouldAlmostEqual, want[a][b], 1e-9*math.Abs(want[a][a]))
+			}
+		}
+		cov, err := m.ResidualCov([]int{1, 0})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(cov.At(0, 1), cv.ShouldAlmostEqual, want[0][1]/float64(len(allRows)-5), 1e-9*math.Abs(want[0][1]))
+		cv.So(cov.At(0, 0), cv.ShouldAlmostEqual, want[1][1]/float64(len(allRows)-5), 1e-9*want[1][1])
+
+		// and the same after SetMeanSd(), in the units of y
+		scaled := NewMillerLSQ(4, 2)
+		scaled.SetMeanSd([]float64{7, 4, 5, 57}, []float64{1, 0.5, 3, 5}, []float64{576, 500}, []float64{111, 300})
+		xrow := make([]float64, 4)
+		for i := range df.Rows {
+			for k, j := range xcols {
+				xrow[k] = df.Rows[i][j]
+			}
+			scaled.Includ(1, xrow, []float64{df.Rows[i][8], df.Rows[i][6]}, NAN_OMIT_ROW)
+		}
+		es, err := scaled.ResidualSSCP([]int{0, 1})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(es.At(0, 1), cv.ShouldAlmostEqual, want[0][1], 1e-8*math.Abs(want[0][1]))
+	})
+
+	cv.Convey("Sscp should be carried through Clone(), Decay() and merging", t, func() {
+		m := fill(Seq(4), allRows, ycols)
+		c := m.Clone()
+		cv.So(c.Sscp, cv.ShouldResemble, m.Sscp)
+		c.Decay(0.5)
+		for k := range m.Sscp {
+			cv.So(c.Sscp[k], cv.ShouldAlmostEqual, m.Sscp[k]/2, 1e-12*math.Abs(m.Sscp[k]))
+		}
+
+		m1 := fill(Seq(4), allRows[:25], ycols)
+		m2 := fill(Seq(4), allRows[25:], ycols)
+		merged := LsqCombineAllRhs(m1, m2)
+		for k := range m.Sscp {
+			cv.So(merged.Sscp[k], cv.ShouldAlmostEqual, m.Sscp[k], 1e-7*math.Abs(m.Sscp[0]))
+		}
+	})
+
+	cv.Convey("Manova() should test each term by the eigenvalues of inv(E) H, with E and H from separate fits", t, func() {
+		mod := &Model{MillerLSQ: fill(Seq(4), allRows, ycols), Xnames: names, Ynames: ynames}
+		mod.Reorder([]int{3, 1}, 1)
+		before := DeepCopyInts(mod.Vorder)
+
+		tab, err := mod.Manova([]int{0, 1}, nil, ANOVA_TYPE_III)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(mod.Vorder, cv.ShouldResemble, before)
+		cv.So(tab.Ynames, cv.ShouldResemble, ynames)
+		cv.So(tab.DfResid, cv.ShouldEqual, float64(len(allRows)-5))
+		fmt.Printf("\n%s\n", tab)
+
+		e := direct(Seq(4))
+		for k, row := range tab.Rows {
+			cv.So(row.Term.Name, cv.ShouldEqual, names[k])
+			reduced := direct(without(Seq(4), []int{k + 1}))
+			var h [2][2]float64
+			for a := 0; a < 2; a++ {
+				for b := 0; b < 2; b++ {
+					h[a][b] = reduced[a][b] - e[a][b]
+					cv.So(row.H.At(a, b), cv.ShouldAlmostEqual, h[a][b], 1e-8*math.Abs(reduced[a][a]))
+				}
+			}
+			// Wilks' lambda is det(E)/det(E+H), and Hotelling-Lawley is trace(inv(E) H)
+			detE := e[0][0]*e[1][1] - e[0][1]*e[1][0]
+			detR := reduced[0][0]*reduced[1][1] - reduced[0][1]*reduced[1][0]
+			cv.So(row.Wilks.Value, cv.ShouldAlmostEqual, detE/detR, 1e-8)
+			tr := (e[1][1]*h[0][0] - e[0][1]*h[1][0] - e[1][0]*h[0][1] + e[0][0]*h[1][1]) / detE
+			cv.So(row.HotellingLawley.Value, cv.ShouldAlmostEqual, tr, 1e-8*tr)
+			cv.So(row.Roy.Value, cv.ShouldEqual, row.Eigenvalues[0])
+			cv.So(row.Pillai.Value, cv.ShouldAlmostEqual, row.Eigenvalues[0]/(1+row.Eigenvalues[0])+row.Eigenvalues[1]/(1+row.Eigenvalues[1]), 1e-12)
+
+			// with a one-column term, all four F tests are exact and agree
+			for _, st := range []MultiStat{row.Pillai, row.HotellingLawley, row.Roy} {
+				cv.So(st.F, cv.ShouldAlmostEqual, row.Wilks.F, 1e-8*row.Wilks.F)
+				cv.So(st.Df1, cv.ShouldEqual, 2)
+				cv.So(st.Df2, cv.ShouldEqual, tab.DfResid-1)
+				cv.So(st.Pvalue, cv.ShouldAlmostEqual, row.Wilks.Pvalue, 1e-8)
+			}
+		}
+	})
+
+	cv.Convey("With one y-target, Manova() should give the F tests of Anova()", t, func() {
+		m := fill(Seq(4), allRows, ycols)
+		terms := []Term{{Name: "TaxIncome", Vars: []int{1, 2}}, {Name: "RoadMls", Vars: []int{3}}, {Name: "DLic", Vars: []int{4}}}
+		for _, typ := range []AnovaType{ANOVA_TYPE_I, ANOVA_TYPE_II, ANOVA_TYPE_III} {
+			av, err := m.Anova(1, terms, typ)
+			cv.So(err, cv.ShouldBeNil)
+			mv, err := m.Manova([]int{1}, terms, typ)
+			cv.So(err, cv.ShouldBeNil)
+			for k, row := range mv.Rows {
+				cv.So(row.H.At(0, 0), cv.ShouldAlmostEqual, av.Rows[k].SumSq, 1e-9*av.Rows[k].SumSq)
+				for _, st := range []MultiStat{row.Wilks, row.Pillai, row.HotellingLawley, row.Roy} {
+					cv.So(st.F, cv.ShouldAlmostEqual, av.Rows[k].F, 1e-8*av.Rows[k].F)
+					cv.So(st.Pvalue, cv.ShouldAlmostEqual, av.Rows[k].Pvalue, 1e-8)
+				}
+			}
+		}
+	})
+
+	cv.Convey("Bad y-targets, and y-targets with dependent residuals, should be errors", t, func() {
+		m := fill(Seq(4), allRows, ycols)
+		_, err := m.Manova(nil, nil, ANOVA_TYPE_I)
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = m.Manova([]int{0, 0}, nil, ANOVA_TYPE_I)
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = m.Manova([]int{2}, nil, ANOVA_TYPE_I)
+		cv.So(err, cv.ShouldNotBeNil)
+
+		// the third y-target is the sum of the first two
+		d := fill(Seq(4), allRows, []int{8, 6, 8})
+		d2 := NewMillerLSQ(4, 3)
+		xrow := make([]float64, 4)
+		for i := range df.Rows {
+			for k, j := range xcols {
+				xrow[k] = df.Rows[i][j]
+			}
+			d2.Includ(1, xrow, []float64{df.Rows[i][8], df.Rows[i][6], df.Rows[i][8] + df.Rows[i][6]}, NAN_OMIT_ROW)
+		}
+		for _, bad := range []*MillerLSQ{d, d2} {
+			_, err = bad.Manova([]int{0, 1, 2}, nil, ANOVA_TYPE_I)
+			cv.So(err, cv.ShouldNotBeNil)
+		}
+
+		old := fill(Seq(4), allRows, ycols)
+		old.Sscp = nil
+		_, err = old.Manova([]int{0, 1}, nil, ANOVA_TYPE_I)
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
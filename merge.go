@@ -7,8 +7,7 @@ import "fmt"
 func LsqCombineAllRhs(lsq1 *MillerLSQ, lsq2 *MillerLSQ) (merged *MillerLSQ) {
 
 	nyvar := lsq1.Nyvar
-	nxvar := lsq1.Nxvar
-	combonc := nxvar + 2 // + 2 because one intercept, one y
+	combonc := lsq1.Ncol + 1 // + 1 for y; Ncol counts the intercept, if any
 
 	comboSserr := make([]float64, nyvar)
 	comboRhs := make([][]float64, nyvar)
@@ -30,6 +29,7 @@ func LsqCombineAllRhs(lsq1 *MillerLSQ, lsq2 *MillerLSQ) (merged *MillerLSQ) {
 	merged.UpperTriMatrix = *newRsmall
 	merged.Rhs = comboRhs
 	merged.Sserr = comboSserr
+	merged.Sscp = mergeSscp(lsq1, lsq2, merged)
 
 	//fmt.Printf("\n after wycol loop, merged = %#vn", merged)
 
@@ -44,6 +44,9 @@ func LsqCombine1Rhs(lsq1 *MillerLSQ, lsq2 *MillerLSQ, wycol int, upbig *UpperTri
 	if lsq1.Nxvar != lsq2.Nxvar {
 		panic(fmt.Sprintf("LsqCombine1Rhs() must be called on models over the same data set. Nxvar(%v) != lsq2.Nxvar(%v)", lsq1.Nxvar, lsq2.Nxvar))
 	}
+	if lsq1.NoIntercept != lsq2.NoIntercept {
+		panic(fmt.Sprintf("LsqCombine1Rhs() cannot merge a model with an intercept and one without. NoIntercept(%v) != lsq2.NoIntercept(%v)", lsq1.NoIntercept, lsq2.NoIntercept))
+	}
 
 	covFromQR1, mean1qr, N1qr := lsq1.QR2Cov(wycol) // allocates new SquareMatrix covFromQR1
 	covFromQR2, mean2qr, N2qr := lsq2.QR2Cov(wycol) // allocates new SquareMatrix covFromQR2
@@ -69,7 +72,7 @@ func LsqCombine1Rhs(lsq1 *MillerLSQ, lsq2 *MillerLSQ, wycol int, upbig *UpperTri
 	nc := len(meanc) - 1
 	ymean := meanc[nc]
 	//fmt.Printf("ymean = %v\n", ymean)
-	upbig.Cov2QR(covMerged, combinedN, meanc[:nc], ymean, SetQRCompressed)
+	upbig.cov2QR(covMerged, combinedN, meanc[:nc], ymean, SetQRCompressed)
 
 	uR, uRhs, uSserr := upbig.DeSupplementR(writetoSmall)
 
@@ -80,16 +83,83 @@ func LsqCombine1Rhs(lsq1 *MillerLSQ, lsq2 *MillerLSQ, wycol int, upbig *UpperTri
 	return uRhs, uSserr
 }
 
+// mergeSscp(): the residual cross-products of merged. The total
+// cross-products of the y-targets, Sscp + sum of D*Rhs*Rhs', just add,
+// and merged's own projections come back off them. nil if either model
+// predates Sscp.
+func mergeSscp(lsq1 *MillerLSQ, lsq2 *MillerLSQ, merged *MillerLSQ) []float64 {
+	ny := merged.Nyvar
+	if len(lsq1.Sscp) != ny*ny || len(lsq2.Sscp) != ny*ny {
+		return nil
+	}
+	t1 := lsq1.yCross(0)
+	t2 := lsq2.yCross(0)
+	e := make([]float64, ny*ny)
+	for i := 0; i < ny; i++ {
+		for j := 0; j < ny; j++ {
+			sum := t1[i*ny+j] + t2[i*ny+j]
+			for pos := range merged.D {
+				sum -= merged.D[pos] * merged.Rhs[i][pos] * merged.Rhs[j][pos]
+			}
+			e[i*ny+j] = sum
+		}
+		// the same as Sserr, but for rounding
+		e[i*ny+i] = merged.Sserr[i]
+	}
+	return e
+}
+
+// mergePartial(): the partial factorizations of each y-target merged;
+// nil if neither model has any.
+func mergePartial(p1 []*MillerLSQ, p2 []*MillerLSQ) []*MillerLSQ {
+	n := len(p1)
+	if len(p2) > n {
+		n = len(p2)
+	}
+	if n == 0 {
+		return nil
+	}
+	merged := make([]*MillerLSQ, n)
+	for j := range merged {
+		var a, b *MillerLSQ
+		if j < len(p1) {
+			a = p1[j]
+		}
+		if j < len(p2) {
+			b = p2[j]
+		}
+		switch {
+		case a != nil && b != nil:
+			merged[j] = LsqCombineAllRhs(a, b)
+		case a != nil:
+			merged[j] = a.Clone()
+		case b != nil:
+			merged[j] = b.Clone()
+		}
+	}
+	return merged
+}
+
 // take care of the easy initial combination/merge stuff here.
 func PrepNewMergedLsq(lsq1 *MillerLSQ, lsq2 *MillerLSQ) (merged *MillerLSQ) {
 
-	merged = NewMillerLSQ(lsq1.Nxvar, lsq1.Nyvar)
+	merged = NewMillerLSQIntercept(lsq1.Nxvar, lsq1.Nyvar, !lsq1.NoIntercept)
 
 	merged.RowsSeen = lsq1.RowsSeen + lsq2.RowsSeen
 	merged.Nobs = lsq1.Nobs + lsq2.Nobs
 	merged.CountNaNRowsSkipped = lsq1.CountNaNRowsSkipped + lsq2.CountNaNRowsSkipped
+	merged.XPolicy = lsq1.XPolicy
+	merged.YPolicy = lsq1.YPolicy
+	merged.XMissing = addMissingCounts(lsq1.XMissing, lsq2.XMissing)
+	merged.YMissing = addMissingCounts(lsq1.YMissing, lsq2.YMissing)
+	merged.Partial = mergePartial(lsq1.Partial, lsq2.Partial)
 
 	merged.AccumWeightSum = lsq1.AccumWeightSum + lsq2.AccumWeightSum
+	if lsq1.Decayed || lsq2.Decayed {
+		merged.Decayed = true
+		merged.DecayedNobs = lsq1.NobsEffective() + lsq2.NobsEffective()
+	}
+	merged.ForgettingFactor = lsq1.ForgettingFactor
 	merged.Nxvar = lsq1.Nxvar
 	merged.Nyvar = lsq1.Nyvar
 	merged.NanApproach = lsq1.NanApproach
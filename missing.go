@@ -0,0 +1,237 @@
+package lsq
+
+import (
+	"fmt"
+	"math"
+)
+
+// Missing values, column by column.
+//
+// The nanapproach given to Includ() applies to every column alike: a
+// NaN anywhere either omits the row or becomes zero. A ColumnPolicy,
+// set with SetMissingPolicy(), overrides that for one column: a
+// missing value can omit the row, become zero, become the running mean
+// of the column so far (from XStats or YStats), or become a constant
+// of the caller's choosing. Infinities are never included; they reject
+// the row, unless InfIsMissing says to treat them as missing, and so by
+// the column's policy.
+//
+// Includ() writes whatever it filled in back into xrow and yrow, so
+// the caller holds the row exactly as it was included, ready to be
+// downdated later. XMissing and YMissing count, for each column, the
+// values filled in and the rows left out because of it.
+
+// MissingPolicy says what Includ() does with a missing value in a column.
+type MissingPolicy int
+
+const (
+	MISSING_DEFAULT  MissingPolicy = 0 // as the nanapproach given to Includ()
+	MISSING_OMIT_ROW MissingPolicy = 1
+	MISSING_ZERO     MissingPolicy = 2
+	MISSING_MEAN     MissingPolicy = 3 // the running mean of the column; the row is omitted until there is one
+	MISSING_CONSTANT MissingPolicy = 4 // ColumnPolicy.Constant
+
+	// MISSING_OMIT_TARGET, for a y column only, leaves the row out of
+	// that target's fit but in the others'; see Target().
+	MISSING_OMIT_TARGET MissingPolicy = 5
+)
+
+// ColumnPolicy is the missing-value policy of one column.
+type ColumnPolicy struct {
+	Missing  MissingPolicy
+	Constant float64 // for MISSING_CONSTANT
+
+	// InfIsMissing treats +Inf and -Inf as missing; otherwise a row
+	// holding either is rejected.
+	InfIsMissing bool
+}
+
+// MissingCounts counts what happened to the missing and infinite values
+// of one column.
+type MissingCounts struct {
+	Imputed  int64 // values filled in
+	Rejected int64 // rows left out because of this column (of this target's fit alone, for MISSING_OMIT_TARGET)
+}
+
+// SetMissingPolicy(): the policies for each x-variable and each
+// y-target, in order. Either may be nil, for the nanapproach given to
+// Includ() in every column, as before.
+func (m *MillerLSQ) SetMissingPolicy(x []ColumnPolicy, y []ColumnPolicy) {
+	if x != nil && len(x) != m.Nxvar {
+		panic(fmt.Sprintf("len(x) == %v did not match m.Nxvar == %v", len(x), m.Nxvar))
+	}
+	if y != nil && len(y) != m.Nyvar {
+		panic(fmt.Sprintf("len(y) == %v did not match m.Nyvar == %v", len(y), m.Nyvar))
+	}
+	for j, p := range x {
+		if p.Missing < MISSING_DEFAULT || p.Missing > MISSING_CONSTANT {
+			panic(fmt.Sprintf("MissingPolicy %v cannot be used for x-variable %d", p.Missing, j))
+		}
+	}
+	for j, p := range y {
+		if p.Missing < MISSING_DEFAULT || p.Missing > MISSING_OMIT_TARGET {
+			panic(fmt.Sprintf("unknown MissingPolicy %v for y-target %d", p.Missing, j))
+		}
+	}
+	m.XPolicy = x
+	m.YPolicy = y
+}
+
+// policy(): the policy of column j of ps, with MISSING_DEFAULT resolved
+// by nanapproach; target is set for a y column.
+func policy(ps []ColumnPolicy, j int, nanapproach NanHandling, target bool) ColumnPolicy {
+	var p ColumnPolicy
+	if j < len(ps) {
+		p = ps[j]
+	}
+	if p.Missing == MISSING_DEFAULT {
+		switch {
+		case nanapproach == NAN_OMIT_TARGET && target:
+			p.Missing = MISSING_OMIT_TARGET
+		case nanapproach == NAN_OMIT_ROW || nanapproach == NAN_OMIT_TARGET:
+			p.Missing = MISSING_OMIT_ROW
+		default:
+			p.Missing = MISSING_ZERO
+		}
+	}
+	return p
+}
+
+// fill(): what a missing value becomes under p, given the running stats
+// of its column; ok is false if the row must be omitted.
+func (p ColumnPolicy) fill(stats *SdTracker, j int) (v float64, ok bool) {
+	switch p.Missing {
+	case MISSING_ZERO:
+		return 0, true
+	case MISSING_CONSTANT:
+		return p.Constant, true
+	case MISSING_MEAN:
+		if j < len(stats.W) && stats.W[j] > 0 {
+			return stats.A[j], true
+		}
+	}
+	return 0, false
+}
+
+// fillMissing(): apply the policies to x (the x-variables, without the
+// constant) and y, in place. If the row must be omitted, nothing is
+// changed and false is returned. A y-target left out by
+// MISSING_OMIT_TARGET is set to NaN. With count set, XMissing and
+// YMissing are updated.
+func (m *MillerLSQ) fillMissing(x []float64, y []float64, nanapproach NanHandling, count bool) bool {
+	return m.fillMissingFrom(x, y, nanapproach, count, &m.XStats, &m.YStats)
+}
+
+// fillMissingFrom(): fillMissing(), with the running means for
+// MISSING_MEAN taken from xstats and ystats.
+func (m *MillerLSQ) fillMissingFrom(x []float64, y []float64, nanapproach NanHandling, count bool, xstats *SdTracker, ystats *SdTracker) bool {
+	if count {
+		if len(m.XMissing) != m.Nxvar {
+			m.XMissing = make([]MissingCounts, m.Nxvar)
+		}
+		if len(m.YMissing) != m.Nyvar {
+			m.YMissing = make([]MissingCounts, m.Nyvar)
+		}
+	}
+
+	// first see whether the row stays at all
+	keep := true
+	check := func(vals []float64, ps []ColumnPolicy, stats *SdTracker, counts []MissingCounts, target bool) {
+		for j, v := range vals {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				continue
+			}
+			p := policy(ps, j, nanapproach, target)
+			ok := !math.IsInf(v, 0) || p.InfIsMissing
+			if ok && p.Missing != MISSING_OMIT_TARGET {
+				_, ok = p.fill(stats, j)
+			}
+			if !ok {
+				keep = false
+				if count && j < len(counts) {
+					counts[j].Rejected++
+				}
+			}
+		}
+	}
+	check(x, m.XPolicy, xstats, m.XMissing, false)
+	check(y, m.YPolicy, ystats, m.YMissing, true)
+	if !keep {
+		return false
+	}
+
+	apply := func(vals []float64, ps []ColumnPolicy, stats *SdTracker, counts []MissingCounts, target bool) {
+		for j, v := range vals {
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				continue
+			}
+			p := policy(ps, j, nanapproach, target)
+			if p.Missing == MISSING_OMIT_TARGET {
+				vals[j] = math.NaN()
+				if count && j < len(counts) {
+					counts[j].Rejected++
+				}
+				continue
+			}
+			vals[j], _ = p.fill(stats, j)
+			if count && j < len(counts) {
+				counts[j].Imputed++
+			}
+		}
+	}
+	apply(x, m.XPolicy, xstats, m.XMissing, false)
+	apply(y, m.YPolicy, ystats, m.YMissing, true)
+	return true
+}
+
+// usesMean(): true if any column's policy is MISSING_MEAN.
+func (m *MillerLSQ) usesMean() bool {
+	for _, ps := range [][]ColumnPolicy{m.XPolicy, m.YPolicy} {
+		for _, p := range ps {
+			if p.Missing == MISSING_MEAN {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameStats(): true if a and b hold the same weights and means, to
+// within rounding.
+func sameStats(a *SdTracker, b *SdTracker) bool {
+	if len(a.W) != len(b.W) {
+		return false
+	}
+	for j := range a.W {
+		if math.Abs(a.W[j]-b.W[j]) > 1e-9*(1+math.Abs(b.W[j])) {
+			return false
+		}
+		if math.Abs(a.A[j]-b.A[j]) > 1e-9*(1+math.Abs(b.A[j])) {
+			return false
+		}
+	}
+	return true
+}
+
+// addMissingCounts(): a + b, column by column; nil if neither has any.
+func addMissingCounts(a []MissingCounts, b []MissingCounts) []MissingCounts {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	if n == 0 {
+		return nil
+	}
+	sum := make([]MissingCounts, n)
+	for j := range sum {
+		if j < len(a) {
+			sum[j].Imputed += a[j].Imputed
+			sum[j].Rejected += a[j].Rejected
+		}
+		if j < len(b) {
+			sum[j].Imputed += b[j].Imputed
+			sum[j].Rejected += b[j].Rejected
+		}
+	}
+	return sum
+}
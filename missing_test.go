@@ -0,0 +1,205 @@
+package lsq
+
+import (
+	"math"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestMissingPolicy(t *testing.T) {
+
+	nan := math.NaN()
+	inf := math.Inf(1)
+
+	// y = 1 + 2 x1 - x2, with a little noise
+	rows := [][]float64{
+		{1, 2, 0.1},
+		{2, 1, 3.2},
+		{3, 5, 1.9},
+		{4, 3, 6.1},
+		{5, 8, 2.8},
+		{6, 2, 11.2},
+		{7, 7, 8.1},
+		{8, 4, 13.0},
+	}
+	y := func(i int) float64 {
+		return 1 + 2*rows[i][0] - rows[i][1] + rows[i][2]/10
+	}
+
+	cv.Convey("Without policies, Includ() should omit or zero NaNs as before, and write the zeros back into the row", t, func() {
+		m := NewMillerLSQ(2, 1)
+		x := []float64{nan, 1}
+		cv.So(m.Includ(1, x, []float64{3}, NAN_OMIT_ROW), cv.ShouldBeFalse)
+		cv.So(math.IsNaN(x[0]), cv.ShouldBeTrue)
+		cv.So(m.Nobs, cv.ShouldEqual, 0)
+		cv.So(m.XMissing[0].Rejected, cv.ShouldEqual, 1)
+
+		cv.So(m.Includ(1, x, []float64{3}, NAN_TO_ZERO), cv.ShouldBeTrue)
+		cv.So(x[0], cv.ShouldEqual, 0)
+		cv.So(m.XMissing[0].Imputed, cv.ShouldEqual, 1)
+		cv.So(m.Nobs, cv.ShouldEqual, 1)
+		cv.So(m.CountNaNRowsSkipped, cv.ShouldEqual, 1)
+	})
+
+	cv.Convey("Infinities and non-finite weights should omit the row, rather than poison the factorization", t, func() {
+		m := NewMillerLSQ(2, 1)
+		for i := range rows {
+			m.Includ(1, []float64{rows[i][0], rows[i][1]}, []float64{y(i)}, NAN_TO_ZERO)
+		}
+		before, err := m.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+
+		cv.So(m.Includ(1, []float64{inf, 1}, []float64{3}, NAN_TO_ZERO), cv.ShouldBeFalse)
+		cv.So(m.Includ(1, []float64{1, 1}, []float64{math.Inf(-1)}, NAN_TO_ZERO), cv.ShouldBeFalse)
+		cv.So(m.Includ(nan, []float64{1, 1}, []float64{3}, NAN_TO_ZERO), cv.ShouldBeFalse)
+		cv.So(m.Includ(inf, []float64{1, 1}, []float64{3}, NAN_TO_ZERO), cv.ShouldBeFalse)
+		cv.So(m.CountNaNRowsSkipped, cv.ShouldEqual, 4)
+		cv.So(m.XMissing[0].Rejected, cv.ShouldEqual, 1)
+		cv.So(m.YMissing[0].Rejected, cv.ShouldEqual, 1)
+		cv.So(m.RowsSeen, cv.ShouldEqual, int64(len(rows))+4)
+
+		after, err := m.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(after.Coef, cv.ShouldResemble, before.Coef)
+
+		// unless the column says Inf is missing
+		m.SetMissingPolicy([]ColumnPolicy{{Missing: MISSING_CONSTANT, Constant: 4, InfIsMissing: true}, {}}, nil)
+		x := []float64{inf, 1}
+		cv.So(m.Includ(1, x, []float64{3}, NAN_TO_ZERO), cv.ShouldBeTrue)
+		cv.So(x[0], cv.ShouldEqual, 4)
+		cv.So(m.XMissing[0].Imputed, cv.ShouldEqual, 1)
+	})
+
+	cv.Convey("MISSING_MEAN should fill in the running mean of the column, once there is one, and other columns keep their own policies", t, func() {
+		m := NewMillerLSQ(2, 1)
+		m.SetMissingPolicy([]ColumnPolicy{{Missing: MISSING_MEAN}, {Missing: MISSING_OMIT_ROW}}, nil)
+
+		// the same rows, with the means written in by hand
+		want := NewMillerLSQ(2, 1)
+
+		// no mean yet: omitted
+		cv.So(m.Includ(1, []float64{nan, 1}, []float64{3}, NAN_TO_ZERO), cv.ShouldBeFalse)
+		cv.So(m.XMissing[0].Rejected, cv.ShouldEqual, 1)
+
+		sum, w := 0.0, 0.0
+		for i := range rows {
+			x := []float64{rows[i][0], rows[i][1]}
+			wt := 1 + float64(i%3)
+			if i == 3 || i == 6 {
+				x[0] = nan
+			}
+			cv.So(m.Includ(wt, x, []float64{y(i)}, NAN_TO_ZERO), cv.ShouldBeTrue)
+			if i == 3 || i == 6 {
+				cv.So(x[0], cv.ShouldAlmostEqual, sum/w, 1e-12)
+			}
+			sum += wt * x[0]
+			w += wt
+			want.Includ(wt, x, []float64{y(i)}, NAN_TO_ZERO)
+		}
+		cv.So(m.XMissing[0].Imputed, cv.ShouldEqual, 2)
+
+		// column 2 omits its rows even with NAN_TO_ZERO
+		cv.So(m.Includ(1, []float64{1, nan}, []float64{3}, NAN_TO_ZERO), cv.ShouldBeFalse)
+		cv.So(m.XMissing[1].Rejected, cv.ShouldEqual, 1)
+		// and a row omitted for one column is left as it was in the others
+		x := []float64{nan, nan}
+		cv.So(m.Includ(1, x, []float64{3}, NAN_TO_ZERO), cv.ShouldBeFalse)
+		cv.So(math.IsNaN(x[0]), cv.ShouldBeTrue)
+		cv.So(m.XMissing[0].Imputed, cv.ShouldEqual, 2)
+
+		got, err := m.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		exp, err := want.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(EpsSliceEqual(got.Coef, exp.Coef, 1e-10), cv.ShouldBeTrue)
+		cv.So(m.Nobs, cv.ShouldEqual, len(rows))
+		cv.So(m.CountNaNRowsSkipped, cv.ShouldEqual, 3)
+	})
+
+	cv.Convey("A second pass should fill in MISSING_MEAN with the running mean Includ() used, even when the first row has none", t, func() {
+		// x1, x2, y, weight, with x1 missing in the first row
+		var src [][]float64
+		for i := range rows {
+			x1 := rows[i][0]
+			if i == 0 || i == 3 || i == 6 {
+				x1 = nan
+			}
+			src = append(src, []float64{x1, rows[i][1], y(i), 1 + float64(i%3)})
+		}
+		cols := Columns{X: []int{0, 1}, Y: []int{2}, Weight: 3}
+
+		for _, lambda := range []float64{0, 0.9} {
+			m := NewMillerLSQ(2, 1)
+			m.ForgettingFactor = lambda
+			m.SetMissingPolicy([]ColumnPolicy{{Missing: MISSING_MEAN}, {}}, nil)
+
+			// the rows as they were included, with the means written in
+			want := NewMillerLSQ(2, 1)
+			want.ForgettingFactor = lambda
+			var filled [][]float64
+			for _, r := range src {
+				x := []float64{r[0], r[1]}
+				if m.Includ(r[3], x, r[2:3], NAN_OMIT_ROW) {
+					want.Includ(r[3], x, r[2:3], NAN_OMIT_ROW)
+					filled = append(filled, []float64{x[0], x[1], r[2], r[3]})
+				}
+			}
+			cv.So(m.Nobs, cv.ShouldEqual, len(rows)-1)
+
+			got, err := m.RobustFit(0, NewSliceSource(src), cols, HC0)
+			cv.So(err, cv.ShouldBeNil)
+			exp, err := want.RobustFit(0, NewSliceSource(filled), cols, HC0)
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(EpsSliceEqual(got.StdErr, exp.StdErr, 1e-10), cv.ShouldBeTrue)
+
+			var h, hwant []float64
+			_, err = m.Influence(0, NewSliceSource(src), cols, func(inf *Influence) error {
+				h = append(h, inf.Leverage)
+				return nil
+			})
+			cv.So(err, cv.ShouldBeNil)
+			_, err = want.Influence(0, NewSliceSource(filled), cols, func(inf *Influence) error {
+				hwant = append(hwant, inf.Leverage)
+				return nil
+			})
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(EpsSliceEqual(h, hwant, 1e-10), cv.ShouldBeTrue)
+		}
+
+		// after Decay() by hand, the means cannot be rebuilt from the rows
+		m := NewMillerLSQ(2, 1)
+		m.SetMissingPolicy([]ColumnPolicy{{Missing: MISSING_MEAN}, {}}, nil)
+		for _, r := range src {
+			m.Includ(r[3], []float64{r[0], r[1]}, r[2:3], NAN_OMIT_ROW)
+		}
+		m.Decay(0.5)
+		_, err := m.RobustFit(0, NewSliceSource(src), cols, HC0)
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+
+	cv.Convey("The policies and counts should survive Clone() and merging, and bad policies should panic", t, func() {
+		m := NewMillerLSQ(2, 1)
+		m.SetMissingPolicy(nil, []ColumnPolicy{{Missing: MISSING_CONSTANT, Constant: 2}})
+		m.Includ(1, []float64{1, 2}, []float64{nan}, NAN_OMIT_ROW)
+		cv.So(m.YMissing[0].Imputed, cv.ShouldEqual, 1)
+		c := m.Clone()
+		c.Includ(1, []float64{3, 1}, []float64{nan}, NAN_OMIT_ROW)
+		cv.So(c.YMissing[0].Imputed, cv.ShouldEqual, 2)
+		cv.So(m.YMissing[0].Imputed, cv.ShouldEqual, 1)
+
+		merged := LsqCombineAllRhs(m, c)
+		cv.So(merged.YMissing[0].Imputed, cv.ShouldEqual, 3)
+		cv.So(merged.YPolicy, cv.ShouldResemble, m.YPolicy)
+
+		panics := func(f func()) (p bool) {
+			defer func() {
+				p = recover() != nil
+			}()
+			f()
+			return
+		}
+		cv.So(panics(func() { m.SetMissingPolicy([]ColumnPolicy{{}}, nil) }), cv.ShouldBeTrue)
+		cv.So(panics(func() { m.SetMissingPolicy(nil, []ColumnPolicy{{Missing: 9}}) }), cv.ShouldBeTrue)
+	})
+}
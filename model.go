@@ -0,0 +1,294 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Model is the high-level way to use this package. It pairs
+// a MillerLSQ with the names of its x- and y-variables, so
+// that a single call to Fit() returns a complete regression
+// summary, rather than requiring the caller to string together
+// Tolset(), Regcf(), SS(), Cov() and Pt() by hand.
+//
+// Rows are added exactly as before, with Includ(), which
+// Model inherits from the embedded *MillerLSQ.
+type Model struct {
+	*MillerLSQ
+
+	Xnames []string // one per x-variable, not counting the intercept
+	Ynames []string // one per y-target
+}
+
+// NewModel(): the constructor. The number of x- and y-variables
+// is taken from the lengths of xnames and ynames.
+func NewModel(xnames []string, ynames []string) *Model {
+	return NewModelIntercept(xnames, ynames, AddIntercept)
+}
+
+// NewModelIntercept(): as NewModel(), but with addIntercept false
+// (!AddIntercept) the model is fit through the origin; see
+// NewMillerLSQIntercept().
+func NewModelIntercept(xnames []string, ynames []string, addIntercept bool) *Model {
+	return &Model{
+		MillerLSQ: NewMillerLSQIntercept(len(xnames), len(ynames), addIntercept),
+		Xnames:    xnames,
+		Ynames:    ynames,
+	}
+}
+
+// FitResult holds everything that R's summary(lm(...)) would
+// tell you about a fit of one y-target.
+//
+// Coefficient related slices are in the original variable
+// order (the intercept first, then x-variables 1, 2, ...)
+// regardless of the current m.Vorder.
+type FitResult struct {
+	Yname string
+	Names []string // variable names, "(Intercept)" first if there is one
+	Vars  []int    // the variable number of each coefficient; 0 is the intercept
+
+	Coef   []float64 // the regression coefficients, or betas
+	StdErr []float64 // standard errors of Coef
+	Tvalue []float64 // Coef / StdErr
+	Pvalue []float64 // 2-sided p-values, from the exact Student's t distribution
+
+	// Vcov is the covariance matrix of the coefficients, in the
+	// same order as Coef.
+	Vcov *SquareMatrix
+
+	// CovType is empty for the usual covariance matrix, from Cov(),
+	// or names the robust one used instead, such as "HC3"; see RobustFit().
+	CovType string
+
+	Nobs    int64
+	DfModel float64 // numerator degrees of freedom of the F-test
+	DfResid float64 // residual degrees of freedom
+
+	Rss   float64 // residual sum of squares
+	Tss   float64 // total sum of squares, about the mean of y, or about 0 without an intercept
+	Sigma float64 // residual standard error, sqrt(Rss/DfResid)
+
+	Rsquared    float64
+	AdjRsquared float64
+
+	Fstat   float64 // the overall F statistic against the intercept-only (or empty) model
+	Fpvalue float64
+}
+
+// Fit(): fit every x-variable against the wycol-th y-target,
+// and return the summary.
+func (mod *Model) Fit(wycol int) (*FitResult, error) {
+	r, err := mod.MillerLSQ.Fit(wycol)
+	if err != nil {
+		return nil, err
+	}
+	mod.nameResult(r, wycol)
+	return r, nil
+}
+
+// FitSubset(): like Fit(), but for the sub-model using only the
+// x-variables numbered in wxcol (1-based, as in RegcfSubset()).
+func (mod *Model) FitSubset(wxcol []int, wycol int) (*FitResult, error) {
+	r, err := mod.MillerLSQ.FitSubset(wxcol, wycol)
+	if err != nil {
+		return nil, err
+	}
+	mod.nameResult(r, wycol)
+	return r, nil
+}
+
+// FitAll(): call Fit() for each of the y-targets in turn.
+func (mod *Model) FitAll() ([]*FitResult, error) {
+	res := make([]*FitResult, mod.Nyvar)
+	for wycol := range res {
+		r, err := mod.Fit(wycol)
+		if err != nil {
+			return nil, fmt.Errorf("FitAll() error on y-target %d: %s", wycol, err)
+		}
+		res[wycol] = r
+	}
+	return res, nil
+}
+
+func (mod *Model) nameResult(r *FitResult, wycol int) {
+	for i := range r.Names {
+		v := r.Vars[i]
+		if v > 0 && v-1 < len(mod.Xnames) {
+			r.Names[i] = mod.Xnames[v-1]
+		}
+	}
+	if wycol < len(mod.Ynames) {
+		r.Yname = mod.Ynames[wycol]
+	}
+}
+
+// Fit(): the MillerLSQ level version of Model.Fit(). Variables
+// are given the default names x1, x2, ... and the y-target is
+// named y1, y2, ...
+//
+// Fit needs the constant in the first position of m.Vorder, and
+// will move it there with Vmove() if it has been moved elsewhere.
+// The fitted model is the same either way.
+//
+// Without an intercept, R-squared and the F-test are relative to
+// the empty model, as in R's summary.lm() for y ~ x - 1.
+func (m *MillerLSQ) Fit(wycol int) (*FitResult, error) {
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("Fit() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	err := m.constantToFront()
+	if err != nil {
+		return nil, err
+	}
+	return m.fitFirst(m.Ncol, wycol)
+}
+
+// FitSubset(): the MillerLSQ level version of Model.FitSubset(). Any
+// sub-model can be fit this way from the single pass over the data
+// already made by Includ(). As with RegcfSubset(), m.Vorder is restored
+// to its original order before returning.
+func (m *MillerLSQ) FitSubset(wxcol []int, wycol int) (r *FitResult, err error) {
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("FitSubset() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	err = m.checkSubset(wxcol)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := make([]int, m.Ncol)
+	copy(saved, m.Vorder)
+	defer func() {
+		rerr := m.restoreOrder(saved)
+		if err == nil && rerr != nil {
+			r, err = nil, rerr
+		}
+	}()
+
+	err = m.moveSubsetToFront(wxcol)
+	if err != nil {
+		return nil, err
+	}
+	return m.fitFirst(len(wxcol)+m.nconst(), wycol)
+}
+
+// fitFirst(): fit the variables in the first nreq positions of
+// m.Vorder, which must start with the constant, if there is one.
+func (m *MillerLSQ) fitFirst(nreq int, wycol int) (*FitResult, error) {
+
+	err, beta := m.Regcf(Seq(nreq-m.nconst()), wycol)
+	if err != nil {
+		return nil, err
+	}
+
+	covmat := make([]float64, nreq*(nreq+1)/2)
+	sterr := make([]float64, nreq)
+	err, variance := m.Cov(nreq, covmat, sterr, wycol)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &FitResult{
+		Yname:  fmt.Sprintf("y%d", wycol+1),
+		Names:  make([]string, nreq),
+		Coef:   make([]float64, nreq),
+		StdErr: make([]float64, nreq),
+		Tvalue: make([]float64, nreq),
+		Pvalue: make([]float64, nreq),
+		Vcov:   NewSquareMatrix(nreq),
+		Nobs:   m.Nobs,
+		Vars:   make([]int, nreq),
+	}
+
+	// present the coefficients in original variable order,
+	// whatever their position in Vorder happens to be.
+	pos := sortedPositions(m.Vorder[:nreq])
+	for i, p := range pos {
+		v := m.Vorder[p]
+		r.Vars[i] = v
+		if v == 0 {
+			r.Names[i] = "(Intercept)"
+		} else {
+			r.Names[i] = fmt.Sprintf("x%d", v)
+		}
+		r.Coef[i] = beta[p]
+		r.StdErr[i] = sterr[p]
+	}
+	for i, pi := range pos {
+		for j, pj := range pos {
+			r.Vcov.Set(i, j, packedAt(covmat, nreq, pi, pj))
+		}
+	}
+
+	r.DfResid = m.ResidualDf(nreq)
+	r.DfModel = float64(nreq - m.nconst())
+	for i := range r.Coef {
+		r.Tvalue[i] = r.Coef[i] / r.StdErr[i]
+		r.Pvalue[i] = 2 * Pt(r.Tvalue[i], r.DfResid)
+	}
+
+	r.Rss = m.Rss[wycol][nreq-Adj]
+	r.Tss = m.NullRss(wycol)
+	r.Sigma = math.Sqrt(variance)
+
+	r.Rsquared = 1 - r.Rss/r.Tss
+	r.AdjRsquared = 1 - (1-r.Rsquared)*(r.DfModel+r.DfResid)/r.DfResid
+
+	if r.DfModel > 0 {
+		r.Fstat = ((r.Tss - r.Rss) / r.DfModel) / variance
+		r.Fpvalue = Pf(r.Fstat, r.DfModel, r.DfResid)
+	} else {
+		r.Fstat = math.NaN()
+		r.Fpvalue = math.NaN()
+	}
+
+	return r, nil
+}
+
+// sortedPositions returns the positions 0..len(vorder)-1 ordered so
+// that the variable numbers vorder[pos] are increasing.
+func sortedPositions(vorder []int) []int {
+	pos := make([]int, len(vorder))
+	for i := range pos {
+		pos[i] = i
+	}
+	// insertion sort; nreq is small compared to everything else we do.
+	for i := 1; i < len(pos); i++ {
+		for j := i; j > 0 && vorder[pos[j]] < vorder[pos[j-1]]; j-- {
+			pos[j], pos[j-1] = pos[j-1], pos[j]
+		}
+	}
+	return pos
+}
+
+// packedAt returns element [i,j] (0-based) of the symmetric nreq x nreq
+// matrix whose upper triangle is packed by rows in covmat, as Cov() produces it.
+func packedAt(covmat []float64, nreq int, i int, j int) float64 {
+	if j < i {
+		i, j = j, i
+	}
+	return covmat[i*nreq-i*(i-1)/2+j-i]
+}
+
+// String(): a summary in the style of R's summary.lm()
+func (r *FitResult) String() string {
+	s := fmt.Sprintf("Response: %s\n\nCoefficients:\n", r.Yname)
+
+	names := NormalizeNameLengths(append([]string{""}, r.Names...))
+	s += fmt.Sprintf("%s  %12s  %12s  %8s  %10s\n", names[0], "Estimate", "Std. Error", "t value", "Pr(>|t|)")
+	for i := range r.Coef {
+		s += fmt.Sprintf("%s  %12.5g  %12.5g  %8.3f  %10.4g\n", names[i+1], r.Coef[i], r.StdErr[i], r.Tvalue[i], r.Pvalue[i])
+	}
+
+	if r.CovType != "" {
+		s += fmt.Sprintf("Standard errors: %s\n", r.CovType)
+	}
+	s += fmt.Sprintf("\nResidual standard error: %.4g on %.0f degrees of freedom\n", r.Sigma, r.DfResid)
+	s += fmt.Sprintf("Multiple R-squared: %.4g,  Adjusted R-squared: %.4g\n", r.Rsquared, r.AdjRsquared)
+	if r.DfModel > 0 {
+		s += fmt.Sprintf("F-statistic: %.4g on %.0f and %.0f DF,  p-value: %.4g\n", r.Fstat, r.DfModel, r.DfResid, r.Fpvalue)
+	}
+	return s
+}
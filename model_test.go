@@ -0,0 +1,141 @@
+package lsq
+
+import (
+	"fmt"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestModelFitSummary(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+
+	// lm(Fuel_Pop ~ Tax + Income + RoadMls + DLic)
+	xcols := []int{2, 4, 5, 7}
+	last := df.Ncol - 1
+
+	xnames := []string{}
+	for _, j := range xcols {
+		xnames = append(xnames, df.Colnames[j])
+	}
+	mod := NewModel(xnames, []string{df.Colnames[last]})
+
+	xrow := make([]float64, len(xcols))
+	for i := range df.Rows {
+		for k, j := range xcols {
+			xrow[k] = df.Rows[i][j]
+		}
+		mod.Includ(1.0, xrow, df.Rows[i][last:], NAN_OMIT_ROW)
+	}
+
+	fit, err := mod.Fit(0)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("\n%s\n", fit)
+
+	// these match TestReorderBetasCovarReporting, and R's summary.lm():
+	//
+	// Residual standard error: 66.31 on 43 degrees of freedom
+	// Multiple R-squared:  0.6787,	Adjusted R-squared:  0.6488
+	// F-statistic: 22.71 on 4 and 43 DF,  p-value: 3.907e-10
+	//
+	knownGoodBeta := []float64{377.29114647367385, -34.790149164432734, -66.58875178694376, -2.4258888852597504, 13.364493572600963}
+	knownGoodSterr := []float64{185.5411907474462, 12.97020164493804, 17.221747918313376, 3.3891743597613107, 1.9229814252595065}
+	knownGoodPvalue := []float64{0.04821, 0.01033, 0.0003684, 0.478, 1.521e-08}
+
+	cv.Convey("Given a Model loaded with four of the fuelcons.dat predictors, Fit() should return the complete R-style summary", t, func() {
+		cv.So(fit.Names[0], cv.ShouldEqual, "(Intercept)")
+		cv.So(fit.Names[4], cv.ShouldEqual, df.Colnames[7])
+		cv.So(fit.Yname, cv.ShouldEqual, df.Colnames[last])
+		cv.So(EpsSliceEqual(fit.Coef, knownGoodBeta, 1e-8), cv.ShouldBeTrue)
+		cv.So(EpsSliceEqual(fit.StdErr, knownGoodSterr, 1e-8), cv.ShouldBeTrue)
+		for i := range knownGoodPvalue {
+			cv.So(fit.Pvalue[i], cv.ShouldAlmostEqual, knownGoodPvalue[i], knownGoodPvalue[i]*1e-3)
+		}
+		cv.So(fit.Nobs, cv.ShouldEqual, 48)
+		cv.So(fit.DfModel, cv.ShouldEqual, 4)
+		cv.So(fit.DfResid, cv.ShouldEqual, 43)
+		cv.So(fit.Tss, cv.ShouldAlmostEqual, 588366.4791666667, 1e-6)
+		cv.So(fit.Rss, cv.ShouldAlmostEqual, 189049.96822312832, 1e-6)
+		cv.So(fit.Sigma, cv.ShouldAlmostEqual, 66.31, 1e-2)
+		cv.So(fit.Rsquared, cv.ShouldAlmostEqual, 0.6787, 1e-4)
+		cv.So(fit.AdjRsquared, cv.ShouldAlmostEqual, 0.6488, 1e-4)
+		cv.So(fit.Fstat, cv.ShouldAlmostEqual, 22.71, 1e-2)
+		cv.So(fit.Fpvalue, cv.ShouldAlmostEqual, 3.907e-10, 1e-12)
+	})
+
+	cv.Convey("The coefficient covariance should be square, symmetric, and agree with the standard errors", t, func() {
+		cv.So(fit.Vcov.Ncol, cv.ShouldEqual, 5)
+		for i := 0; i < 5; i++ {
+			cv.So(fit.Vcov.At(i, i), cv.ShouldAlmostEqual, fit.StdErr[i]*fit.StdErr[i], 1e-6)
+			for j := 0; j < 5; j++ {
+				cv.So(fit.Vcov.At(i, j), cv.ShouldEqual, fit.Vcov.At(j, i))
+			}
+		}
+		// Constant-Tax entry of knownGoodCovmat in TestReorderBetasCovarReporting
+		cv.So(fit.Vcov.At(0, 1), cv.ShouldAlmostEqual, -1875.170149827859, 1e-6)
+	})
+
+	cv.Convey("Fit() should report coefficients in variable order even after the factorization has been re-ordered", t, func() {
+		// move DLic in front of even the constant
+		err := mod.Vmove(5, 1)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(IntSliceEqual(mod.Vorder, []int{4, 0, 1, 2, 3}), cv.ShouldBeTrue)
+
+		refit, err := mod.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(IntSliceEqual(refit.Vars, []int{0, 1, 2, 3, 4}), cv.ShouldBeTrue)
+		cv.So(EpsSliceEqual(refit.Coef, knownGoodBeta, 1e-8), cv.ShouldBeTrue)
+		cv.So(EpsSliceEqual(refit.StdErr, knownGoodSterr, 1e-8), cv.ShouldBeTrue)
+		cv.So(refit.Vcov.At(0, 1), cv.ShouldAlmostEqual, -1875.170149827859, 1e-6)
+		cv.So(refit.Rsquared, cv.ShouldAlmostEqual, fit.Rsquared, 1e-10)
+	})
+
+	cv.Convey("FitAll() should fit every y-target", t, func() {
+		all, err := mod.FitAll()
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(len(all), cv.ShouldEqual, 1)
+		cv.So(all[0].Fstat, cv.ShouldAlmostEqual, fit.Fstat, 1e-8)
+	})
+}
+
+func TestModelFitSubset(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+	mod := NewModel(df.Colnames[1:last], df.Colnames[last:])
+	for i := range df.Rows {
+		mod.Includ(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+	}
+	origVorder := DeepCopyInts(mod.Vorder)
+
+	// lm(Fuel_Pop ~ Tax + Income + DLic), from the same single pass
+	fit, err := mod.FitSubset([]int{7, 4, 2}, 0)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("\n%s\n", fit)
+
+	kgBeta := []float64{307.3278964966638, -29.483808565617203, -68.02286155799504, 13.747684110568663}
+	kgSterr := []float64{156.8306699209346, 10.58357586517238, 17.009750282390495, 1.8366953982594196}
+
+	cv.Convey("FitSubset() should fit just the requested sub-model, and leave the factorization's order alone", t, func() {
+		cv.So(IntSliceEqual(fit.Vars, []int{0, 2, 4, 7}), cv.ShouldBeTrue)
+		cv.So(fit.Names[3], cv.ShouldEqual, df.Colnames[7])
+		cv.So(EpsSliceEqual(fit.Coef, kgBeta, 1e-8), cv.ShouldBeTrue)
+		cv.So(EpsSliceEqual(fit.StdErr, kgSterr, 1e-8), cv.ShouldBeTrue)
+		cv.So(fit.Rsquared, cv.ShouldAlmostEqual, 0.6749, 1e-4)
+		cv.So(fit.Sigma, cv.ShouldAlmostEqual, 65.94, 1e-2)
+		cv.So(fit.DfModel, cv.ShouldEqual, 3)
+		cv.So(fit.DfResid, cv.ShouldEqual, 44)
+		cv.So(IntSliceEqual(mod.Vorder, origVorder), cv.ShouldBeTrue)
+	})
+}
@@ -0,0 +1,35 @@
+package lsq
+
+import (
+	"github.com/glycerine/gostat"
+	"math"
+)
+
+// return P[ F > f ], the right-hand tail probability, where
+// F ~ F_{df1, df2}  (Fisher's F-distribution with df1 numerator
+// and df2 denominator degrees of freedom).
+//
+// This is the p-value for the usual regression F-test. We use
+// the identity
+//
+//	P[ F > f ] = I_{df2/(df2 + df1*f)}( df2/2, df1/2 )
+//
+// where I_x(a, b) is the regularized incomplete beta function,
+// the same machinery that Pt() relies upon.
+func Pf(f float64, df1 float64, df2 float64) float64 {
+	if math.IsNaN(f) || math.IsNaN(df1) || math.IsNaN(df2) {
+		return math.NaN()
+	}
+	if df1 <= 0 || df2 <= 0 {
+		panic("degrees of freedom for the F-distribution cannot be <= 0")
+	}
+	if f <= 0 {
+		return 1
+	}
+	if math.IsInf(f, 1) {
+		return 0
+	}
+
+	betaCDF := gostat.Beta_CDF(df2/2, df1/2)
+	return betaCDF(df2 / (df2 + df1*f))
+}
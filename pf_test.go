@@ -0,0 +1,30 @@
+package lsq
+
+import (
+	"math"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestPf(t *testing.T) {
+	const eps = 1e-7
+	cv.Convey("Given the Pf function, it should return F distribution upper tail probabilities", t, func() {
+
+		// F(1, n) is the square of a t_n, so its tail is the 2-sided t tail.
+		cv.So(WithinEpsilon(Pf(9, 1, 5), 2*Pt(3, 5), eps), cv.ShouldBeTrue)
+		cv.So(WithinEpsilon(Pf(1.96*1.96, 1, 45), 2*Pt(1.96, 45), eps), cv.ShouldBeTrue)
+
+		// F(2, d2) has the closed form tail (1 + 2f/d2)^(-d2/2)
+		cv.So(WithinEpsilon(Pf(3, 2, 10), math.Pow(1.6, -5), eps), cv.ShouldBeTrue)
+		cv.So(WithinEpsilon(Pf(0.5, 2, 7), math.Pow(1+1.0/7, -3.5), eps), cv.ShouldBeTrue)
+
+		// from R:
+		// > pf(22.71, 4, 43, lower.tail=FALSE)
+		// [1] 3.906e-10
+		cv.So(Pf(22.71, 4, 43), cv.ShouldAlmostEqual, 3.906e-10, 1e-12)
+
+		cv.So(Pf(0, 3, 10), cv.ShouldEqual, 1)
+		cv.So(Pf(math.Inf(1), 3, 10), cv.ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,167 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Prediction is returned by Predict(): the fitted value of the
+// full model at one row of x-values, with its standard error and
+// two-sided intervals at the requested Level.
+//
+// The confidence interval is for the mean response at x; the
+// prediction interval is for a single new observation at x, and so
+// also allows for the residual variance. Both use the exact t
+// quantile on Df residual degrees of freedom.
+type Prediction struct {
+	Fit    float64 // the fitted value, x'b
+	SeFit  float64 // the standard error of Fit, sqrt(var(x'b))
+	SePred float64 // the standard error for a new observation, sqrt(var(x'b) + sigma^2)
+
+	Level float64 // e.g. 0.95
+	Df    float64 // residual degrees of freedom
+	Tcrit float64 // the t quantile: Qt(1 - (1-Level)/2, Df)
+
+	ConfLower float64
+	ConfUpper float64
+	PredLower float64
+	PredUpper float64
+}
+
+// Predict(): predict the wycol-th y-target at xrow, from the model using
+// the constant (if any) and every x-variable. xrow holds the raw values
+// of x-variables 1, 2, ..., Nxvar, in their original order whatever the
+// current m.Vorder; when SetMeanSd() is in use, xrow is normalized just
+// as Includ() would, and the results are returned on the original scale
+// of y. level is the coverage of the intervals, in (0, 1).
+func (m *MillerLSQ) Predict(xrow []float64, wycol int, level float64) (*Prediction, error) {
+	pr, err := m.newPredictor(wycol, level)
+	if err != nil {
+		return nil, err
+	}
+	return pr.predict(xrow)
+}
+
+// PredictRows(): Predict() for each row of xrows. The coefficients are
+// found once, so this is cheaper than calling Predict() row by row.
+func (m *MillerLSQ) PredictRows(xrows [][]float64, wycol int, level float64) ([]*Prediction, error) {
+	pr, err := m.newPredictor(wycol, level)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*Prediction, len(xrows))
+	for i, xrow := range xrows {
+		res[i], err = pr.predict(xrow)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s (at row %d)", err, i))
+		}
+	}
+	return res, nil
+}
+
+// PredictAll(): Predict() for every y-target at the same xrow, in
+// y-target order.
+func (m *MillerLSQ) PredictAll(xrow []float64, level float64) ([]*Prediction, error) {
+	res := make([]*Prediction, m.Nyvar)
+	for wycol := range res {
+		p, err := m.Predict(xrow, wycol, level)
+		if err != nil {
+			return nil, err
+		}
+		res[wycol] = p
+	}
+	return res, nil
+}
+
+// predictor holds what Predict() needs that doesn't depend on the row.
+type predictor struct {
+	m      *MillerLSQ
+	wycol  int
+	nreq   int
+	beta   []float64 // in position order
+	sigma2 float64   // residual variance, on the normalized scale if UseMeanSd
+	df     float64
+	level  float64
+	tcrit  float64
+	xfull  []float64 // scratch: the row in the original order
+	xpos   []float64 // scratch: the row in position order
+}
+
+func (m *MillerLSQ) newPredictor(wycol int, level float64) (*predictor, error) {
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("Predict() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	if !(level > 0 && level < 1) {
+		return nil, errors.New(fmt.Sprintf("Predict() error: level(%v) must be in (0, 1)", level))
+	}
+	nreq := m.Ncol
+	df := m.ResidualDf(nreq)
+	if df <= 0 {
+		return nil, errors.New(fmt.Sprintf("Predict() error: no residual degrees of freedom with %v observations and %d parameters", m.NobsEffective(), nreq))
+	}
+	err, beta := m.Regcf(Seq(nreq-m.nconst()), wycol)
+	if err != nil {
+		return nil, err
+	}
+	return &predictor{
+		m:      m,
+		wycol:  wycol,
+		nreq:   nreq,
+		beta:   beta,
+		sigma2: m.Sserr[wycol] / df,
+		df:     df,
+		level:  level,
+		tcrit:  Qt(1-(1-level)/2, df),
+		xfull:  make([]float64, nreq),
+		xpos:   make([]float64, nreq),
+	}, nil
+}
+
+func (pr *predictor) predict(xrow []float64) (*Prediction, error) {
+	m := pr.m
+	if len(xrow) != m.Nxvar {
+		return nil, errors.New(fmt.Sprintf("Predict() error: len(xrow)=%d but there are %d x-variables", len(xrow), m.Nxvar))
+	}
+
+	// put the row in position order, normalized as Includ() does
+	m.normalize(xrow, nil, pr.xfull, nil)
+	m.toPositions(pr.xfull, pr.xpos)
+
+	fit := 0.0
+	for i, b := range pr.beta {
+		fit += b * pr.xpos[i]
+	}
+	vfit, err := m.Varprd(pr.xpos, pr.nreq, pr.wycol)
+	if err != nil {
+		return nil, err
+	}
+	vpred := vfit + pr.sigma2
+
+	// back to the original scale of y
+	if m.UseMeanSd && m.Ysd[pr.wycol] != 0.0 {
+		ysd := m.Ysd[pr.wycol]
+		fit = fit*ysd + m.Ymean[pr.wycol]
+		vfit *= ysd * ysd
+		vpred *= ysd * ysd
+	}
+
+	p := &Prediction{
+		Fit:    fit,
+		SeFit:  math.Sqrt(vfit),
+		SePred: math.Sqrt(vpred),
+		Level:  pr.level,
+		Df:     pr.df,
+		Tcrit:  pr.tcrit,
+	}
+	p.ConfLower = fit - p.Tcrit*p.SeFit
+	p.ConfUpper = fit + p.Tcrit*p.SeFit
+	p.PredLower = fit - p.Tcrit*p.SePred
+	p.PredUpper = fit + p.Tcrit*p.SePred
+	return p, nil
+}
+
+func (p *Prediction) String() string {
+	return fmt.Sprintf("fit %.6g (se %.6g); %g%% confidence interval [%.6g, %.6g]; %g%% prediction interval [%.6g, %.6g]",
+		p.Fit, p.SeFit, 100*p.Level, p.ConfLower, p.ConfUpper, 100*p.Level, p.PredLower, p.PredUpper)
+}
@@ -0,0 +1,140 @@
+package lsq
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestPredict(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+
+	// Fuel_Pop ~ Tax + Income + RoadMls + DLic
+	xcols := []int{2, 4, 5, 7}
+	last := df.Ncol - 1
+	p := len(xcols)
+
+	rowOf := func(i int) []float64 {
+		xrow := make([]float64, p)
+		for k, j := range xcols {
+			xrow[k] = df.Rows[i][j]
+		}
+		return xrow
+	}
+
+	m := NewMillerLSQ(p, 1)
+	for i := range df.Rows {
+		m.Includ(1.0, rowOf(i), df.Rows[i][last:], NAN_OMIT_ROW)
+	}
+	ols, err := m.Fit(0)
+	if err != nil {
+		panic(err)
+	}
+
+	// x'b and x'Vx, straight from the coefficients and their covariance
+	direct := func(xrow []float64) (fit float64, se float64) {
+		full := append([]float64{1}, xrow...)
+		n := len(full)
+		v := 0.0
+		for a := 0; a < n; a++ {
+			fit += ols.Coef[a] * full[a]
+			for b := 0; b < n; b++ {
+				v += full[a] * ols.Vcov.A[a*n+b] * full[b]
+			}
+		}
+		return fit, math.Sqrt(v)
+	}
+
+	cv.Convey("Given the fuelcons.dat data in a MillerLSQ, Predict() should agree with the coefficients and their covariance matrix from Fit()", t, func() {
+		for _, i := range []int{0, 17, 39} {
+			xrow := rowOf(i)
+			pr, err := m.Predict(xrow, 0, 0.95)
+			cv.So(err, cv.ShouldBeNil)
+			fmt.Printf("\n%s: %s\n", df.Rownames[i], pr)
+
+			fit, se := direct(xrow)
+			cv.So(pr.Fit, cv.ShouldAlmostEqual, fit, 1e-8)
+			cv.So(pr.SeFit, cv.ShouldAlmostEqual, se, 1e-8)
+			cv.So(pr.SePred, cv.ShouldAlmostEqual, math.Sqrt(se*se+ols.Sigma*ols.Sigma), 1e-8)
+			cv.So(pr.Df, cv.ShouldEqual, ols.DfResid)
+
+			// qt(0.975, 43) from R
+			cv.So(pr.Tcrit, cv.ShouldAlmostEqual, 2.016692, 1e-6)
+			cv.So(pr.ConfUpper-pr.Fit, cv.ShouldAlmostEqual, pr.Tcrit*se, 1e-8)
+			cv.So(pr.Fit-pr.PredLower, cv.ShouldAlmostEqual, pr.Tcrit*pr.SePred, 1e-8)
+			cv.So(pr.PredLower < pr.ConfLower && pr.ConfUpper < pr.PredUpper, cv.ShouldBeTrue)
+		}
+	})
+
+	cv.Convey("Predict() should take raw x in the original variable order, whatever the current Vorder", t, func() {
+		xrow := rowOf(5)
+		before, err := m.Predict(xrow, 0, 0.9)
+		cv.So(err, cv.ShouldBeNil)
+
+		m2 := m.Clone()
+		cv.So(m2.Vmove(2, 5), cv.ShouldBeNil)
+		cv.So(m2.Vorder[1] != m.Vorder[1], cv.ShouldBeTrue)
+		after, err := m2.Predict(xrow, 0, 0.9)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(after.Fit, cv.ShouldAlmostEqual, before.Fit, 1e-8)
+		cv.So(after.SeFit, cv.ShouldAlmostEqual, before.SeFit, 1e-8)
+		cv.So(after.PredUpper, cv.ShouldAlmostEqual, before.PredUpper, 1e-8)
+	})
+
+	cv.Convey("With SetMeanSd(), Predict() should still take raw x, and answer on the original scale of y", t, func() {
+		mn := NewMillerLSQ(p, 1)
+		mn.SetMeanSd(m.XStats.Mean(), m.XStats.Sd(), m.YStats.Mean(), m.YStats.Sd())
+		for i := range df.Rows {
+			mn.Includ(1.0, rowOf(i), df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		for _, i := range []int{3, 44} {
+			xrow := rowOf(i)
+			want, err := m.Predict(xrow, 0, 0.99)
+			cv.So(err, cv.ShouldBeNil)
+			got, err := mn.Predict(xrow, 0, 0.99)
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(got.Fit, cv.ShouldAlmostEqual, want.Fit, 1e-8)
+			cv.So(got.SeFit, cv.ShouldAlmostEqual, want.SeFit, 1e-8)
+			cv.So(got.PredLower, cv.ShouldAlmostEqual, want.PredLower, 1e-8)
+		}
+	})
+
+	cv.Convey("PredictRows() should give the same answers as Predict() row by row", t, func() {
+		xrows := [][]float64{rowOf(1), rowOf(2), rowOf(3)}
+		prs, err := m.PredictRows(xrows, 0, 0.95)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(len(prs), cv.ShouldEqual, 3)
+		for i, xrow := range xrows {
+			pr, err := m.Predict(xrow, 0, 0.95)
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(*prs[i], cv.ShouldResemble, *pr)
+		}
+	})
+
+	cv.Convey("Bad input should be an error rather than a panic", t, func() {
+		_, err := m.Predict(rowOf(0), 1, 0.95)
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = m.Predict(rowOf(0), 0, 1.5)
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = m.Predict(rowOf(0)[:2], 0, 0.95)
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = m.PredictRows([][]float64{rowOf(0), {1}}, 0, 0.95)
+		cv.So(err, cv.ShouldNotBeNil)
+
+		_, err = m.Varprd([]float64{1, 2}, 5, 0)
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = m.Varprd(make([]float64, 5), 6, 0)
+		cv.So(err, cv.ShouldNotBeNil)
+
+		tiny := NewMillerLSQ(p, 1)
+		tiny.Includ(1.0, rowOf(0), df.Rows[0][last:], NAN_OMIT_ROW)
+		_, err = tiny.Predict(rowOf(0), 0, 0.95)
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
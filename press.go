@@ -0,0 +1,173 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// The PRESS statistic, or exact leave-one-out cross-validation error.
+//
+// Leaving row i out of a least squares fit changes its residual from e
+// to e/(1-h), with h its leverage, so
+//
+//	PRESS = sum over rows of  w (e / (1-h))^2
+//
+// is the sum of squared prediction errors of the fits to every n-1 of
+// the rows, found without refitting any of them. 1 - PRESS/TSS is the
+// leave-one-out R-squared, which, unlike R-squared, can fall as
+// variables are added, and so compares subsets of different sizes.
+//
+// The residuals need a second pass over the rows. Each subset gets its
+// own copy of the factorization, reordered with the subset in front,
+// for Hdiag(); one pass then serves every subset.
+
+// PressResult is the PRESS statistic of one subset.
+type PressResult struct {
+	Vars  []int    // x-variable numbers in the subset, in increasing order
+	Names []string // their names, from a Model; otherwise x1, x2, ...
+
+	Nobs        int64
+	Rss         float64
+	Rsquared    float64
+	Press       float64
+	LooRsquared float64 // 1 - Press/Tss, the leave-one-out R-squared
+
+	// Exact counts the rows with leverage 1, which no other row
+	// predicts; they are left out of Press.
+	Exact int64
+}
+
+// pressSubset is the state of one subset during the pass.
+type pressSubset struct {
+	c    *MillerLSQ // a copy of the factorization, with the subset in front
+	nreq int
+	beta []float64 // by position in c
+	xpos []float64
+	res  *PressResult
+}
+
+// Press(): the PRESS statistic and leave-one-out R-squared of each of
+// the subsets of x-variables (1-based, as for FitSubset(); the
+// intercept, if any, is always in) for the wycol-th y-target, from one
+// pass over src, with cols saying where x, y and the weight are, as for
+// RobustFit(). The Vars of the Subsets from BestSubsets() will do as
+// subsets. m itself is not reordered.
+func (m *MillerLSQ) Press(wycol int, src RowSource, cols Columns, subsets [][]int) ([]*PressResult, error) {
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("Press() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	if len(subsets) == 0 {
+		return nil, errors.New("Press() error: no subsets given")
+	}
+
+	tss := 0.0
+	var ps []*pressSubset
+	for _, vars := range subsets {
+		err := m.checkSubset(vars)
+		if err != nil {
+			return nil, err
+		}
+		c := m.Clone()
+		err = c.moveSubsetToFront(vars)
+		if err != nil {
+			return nil, err
+		}
+		nreq := len(vars) + c.nconst()
+		if nreq == 0 {
+			return nil, errors.New("Press() error: an empty subset, without an intercept, predicts nothing")
+		}
+		err, beta := c.Regcf(Seq(len(vars)), wycol)
+		if err != nil {
+			return nil, err
+		}
+		c.SS(wycol)
+		tss = c.NullRss(wycol)
+
+		sorted := DeepCopyInts(vars)
+		sort.Ints(sorted)
+		names := make([]string, len(sorted))
+		for i, v := range sorted {
+			names[i] = fmt.Sprintf("x%d", v)
+		}
+		rss := c.rssFirst(wycol, nreq)
+		ps = append(ps, &pressSubset{
+			c:    c,
+			nreq: nreq,
+			beta: beta,
+			xpos: make([]float64, c.Ncol),
+			res: &PressResult{
+				Vars:     sorted,
+				Names:    names,
+				Nobs:     m.Nobs,
+				Rss:      rss,
+				Rsquared: 1 - rss/tss,
+			},
+		})
+	}
+
+	xfull := make([]float64, m.Nxvar+m.nconst())
+	ynorm := make([]float64, m.Nyvar)
+	err := m.eachRow(src, cols, func(i int64, weight float64, x []float64, y []float64, row []float64) error {
+		if weight < 0 {
+			return errors.New("Press() error: rows with negative weights, downdating others, cannot be used")
+		}
+		m.normalize(x, y, xfull, ynorm)
+		for _, s := range ps {
+			s.c.toPositions(xfull, s.xpos)
+			e := ynorm[wycol]
+			for pos := 0; pos < s.nreq; pos++ {
+				e -= s.beta[pos] * s.xpos[pos]
+			}
+			h, err := s.c.Hdiag(s.xpos, s.nreq)
+			if err != nil {
+				return err
+			}
+			h *= weight
+			if h >= 1-1e-10 {
+				s.res.Exact++
+				continue
+			}
+			loo := e / (1 - h)
+			s.res.Press += weight * loo * loo
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*PressResult, len(ps))
+	for i, s := range ps {
+		r := s.res
+		r.LooRsquared = 1 - r.Press/tss
+		if m.UseMeanSd && m.Ysd[wycol] != 0.0 {
+			// back to the units of y
+			ysd2 := m.Ysd[wycol] * m.Ysd[wycol]
+			r.Rss *= ysd2
+			r.Press *= ysd2
+		}
+		results[i] = r
+	}
+	return results, nil
+}
+
+// Press(): as MillerLSQ.Press(), with the variables named from mod.Xnames.
+func (mod *Model) Press(wycol int, src RowSource, cols Columns, subsets [][]int) ([]*PressResult, error) {
+	res, err := mod.MillerLSQ.Press(wycol, src, cols, subsets)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range res {
+		for i, v := range r.Vars {
+			if v-1 < len(mod.Xnames) {
+				r.Names[i] = mod.Xnames[v-1]
+			}
+		}
+	}
+	return res, nil
+}
+
+func (r *PressResult) String() string {
+	return fmt.Sprintf("%v: PRESS %.6g (RSS %.6g), leave-one-out R^2 %.4f (R^2 %.4f)", r.Names, r.Press, r.Rss, r.LooRsquared, r.Rsquared)
+}
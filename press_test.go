@@ -0,0 +1,135 @@
+package lsq
+
+import (
+	"fmt"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestPress(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+
+	// x1..x4 are Tax, Income, RoadMls, DLic
+	xcols := []int{2, 4, 5, 7}
+	names := []string{"Tax", "Income", "RoadMls", "DLic"}
+
+	fill := func(weighted bool, vars []int, skip int) *Model {
+		var xn []string
+		for _, v := range vars {
+			xn = append(xn, names[v-1])
+		}
+		mod := NewModel(xn, []string{"Fuel_Pop"})
+		xrow := make([]float64, len(vars))
+		for i := range df.Rows {
+			if i == skip {
+				continue
+			}
+			for k, v := range vars {
+				xrow[k] = df.Rows[i][xcols[v-1]]
+			}
+			w := 1.0
+			if weighted {
+				w = df.Rows[i][1]
+			}
+			mod.Includ(w, xrow, df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		return mod
+	}
+
+	// PRESS by refitting without each row in turn
+	direct := func(weighted bool, vars []int) float64 {
+		press := 0.0
+		for i := range df.Rows {
+			fit, err := fill(weighted, vars, i).Fit(0)
+			if err != nil {
+				panic(err)
+			}
+			pred := fit.Coef[0]
+			for k, v := range vars {
+				pred += fit.Coef[k+1] * df.Rows[i][xcols[v-1]]
+			}
+			w := 1.0
+			if weighted {
+				w = df.Rows[i][1]
+			}
+			e := df.Rows[i][last] - pred
+			press += w * e * e
+		}
+		return press
+	}
+
+	subsets := [][]int{{}, {4}, {2, 4}, {4, 2, 1}, {1, 2, 3, 4}}
+	src := NewSliceSource(df.Rows)
+	cols := Columns{X: xcols, Y: []int{last}, Weight: NoWeight}
+
+	for _, weighted := range []bool{false, true} {
+		wcols := cols
+		if weighted {
+			wcols.Weight = 1
+		}
+		cv.Convey(fmt.Sprintf("Given the fuelcons.dat data (weighted: %v), Press() should match refitting without each row, for every subset", weighted), t, func() {
+			mod := fill(weighted, []int{1, 2, 3, 4}, -1)
+			mod.Reorder([]int{3, 1}, 1)
+			before := DeepCopyInts(mod.Vorder)
+
+			res, err := mod.Press(0, src, wcols, subsets)
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(len(res), cv.ShouldEqual, len(subsets))
+			cv.So(mod.Vorder, cv.ShouldResemble, before)
+
+			for k, r := range res {
+				want := direct(weighted, subsets[k])
+				cv.So(r.Press, cv.ShouldAlmostEqual, want, 1e-8*want)
+				fit, err := mod.FitSubset(subsets[k], 0)
+				cv.So(err, cv.ShouldBeNil)
+				cv.So(r.Rss, cv.ShouldAlmostEqual, fit.Rss, 1e-8*fit.Rss)
+				cv.So(r.Rsquared, cv.ShouldAlmostEqual, 1-fit.Rss/fit.Tss, 1e-10)
+				cv.So(r.LooRsquared, cv.ShouldAlmostEqual, 1-want/fit.Tss, 1e-8)
+				cv.So(r.LooRsquared < r.Rsquared, cv.ShouldBeTrue)
+				cv.So(r.Exact, cv.ShouldEqual, 0)
+				if !weighted {
+					fmt.Printf("\n%s", r)
+				}
+			}
+			fmt.Printf("\n")
+			cv.So(res[3].Vars, cv.ShouldResemble, []int{1, 2, 4})
+			cv.So(res[3].Names, cv.ShouldResemble, []string{"Tax", "Income", "DLic"})
+		})
+	}
+
+	cv.Convey("After SetMeanSd(), PRESS should still be in the units of y", t, func() {
+		mod := fill(false, []int{1, 2, 3, 4}, -1)
+		plain, err := mod.Press(0, src, cols, subsets[2:3])
+		cv.So(err, cv.ShouldBeNil)
+
+		scaled := NewMillerLSQ(4, 1)
+		scaled.SetMeanSd([]float64{7, 4, 5, 57}, []float64{1, 0.5, 3, 5}, []float64{576}, []float64{111})
+		xrow := make([]float64, 4)
+		for i := range df.Rows {
+			for k, j := range xcols {
+				xrow[k] = df.Rows[i][j]
+			}
+			scaled.Includ(1, xrow, df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		res, err := scaled.Press(0, src, cols, subsets[2:3])
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(res[0].Press, cv.ShouldAlmostEqual, plain[0].Press, 1e-8*plain[0].Press)
+		cv.So(res[0].LooRsquared, cv.ShouldAlmostEqual, plain[0].LooRsquared, 1e-10)
+	})
+
+	cv.Convey("Bad subsets should be errors", t, func() {
+		mod := fill(false, []int{1, 2, 3, 4}, -1)
+		_, err := mod.Press(0, src, cols, [][]int{{5}})
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = mod.Press(0, src, cols, [][]int{{1, 1}})
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = mod.Press(0, src, cols, nil)
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
@@ -68,3 +68,50 @@ func Pt(x float64, n float64) float64 {
 	val /= 2 // default to 1-sided p-value. Double it for 2-sided.
 	return val
 }
+
+// Qt returns the quantile function (the inverse CDF) of Student's
+// t-distribution with n degrees of freedom: the t such that
+// P[ T <= t ] = p, as R's qt(p, n) does. For a two-sided interval
+// at level 0.95, use Qt(0.975, n).
+//
+// The quantile is found by bisection on Pt(), so it is as exact as
+// Pt() is.
+func Qt(p float64, n float64) float64 {
+	if math.IsNaN(p) || math.IsNaN(n) || p < 0 || p > 1 {
+		return math.NaN()
+	}
+	switch p {
+	case 0:
+		return math.Inf(-1)
+	case 0.5:
+		return 0
+	case 1:
+		return math.Inf(1)
+	}
+	if p < 0.5 {
+		return -Qt(1-p, n)
+	}
+
+	// now the upper tail is q = 1-p < 0.5, and t > 0; bracket it first.
+	q := 1 - p
+	lo, hi := 0.0, 1.0
+	for Pt(hi, n) > q {
+		lo = hi
+		hi *= 2
+		if math.IsInf(hi, 0) {
+			return hi
+		}
+	}
+	for i := 0; i < 200; i++ {
+		mid := lo + (hi-lo)/2
+		if mid <= lo || mid >= hi {
+			break
+		}
+		if Pt(mid, n) > q {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo + (hi-lo)/2
+}
@@ -60,3 +60,25 @@ func WithinEpsilon(x float64, y float64, epsilon float64) bool {
 	fmt.Printf("\nWithinEpsilon failing! difference: abs(%v - %v) = %v\n", x, y, math.Abs(x-y))
 	return false
 }
+
+func TestQt(t *testing.T) {
+	const eps = 1e-5
+	cv.Convey("Given the Qt function, it should invert Pt, matching R's qt()", t, func() {
+		//
+		// from R:
+		//
+		// > qt(c(0.975, 0.995, 0.05, 0.9), c(40, 10, 5, 1))
+		// [1]  2.021075  3.169273 -2.015048  3.077684
+		cv.So(WithinEpsilon(Qt(0.975, 40), 2.021075, eps), cv.ShouldBeTrue)
+		cv.So(WithinEpsilon(Qt(0.995, 10), 3.169273, eps), cv.ShouldBeTrue)
+		cv.So(WithinEpsilon(Qt(0.05, 5), -2.015048, eps), cv.ShouldBeTrue)
+		cv.So(WithinEpsilon(Qt(0.9, 1), 3.077684, eps), cv.ShouldBeTrue)
+
+		cv.So(Qt(0.5, 7), cv.ShouldEqual, 0)
+		cv.So(math.IsInf(Qt(1, 7), 1), cv.ShouldBeTrue)
+		cv.So(math.IsNaN(Qt(1.1, 7)), cv.ShouldBeTrue)
+		for _, p := range []float64{0.6, 0.9, 0.999} {
+			cv.So(WithinEpsilon(Pt(Qt(p, 12), 12), 1-p, 1e-12), cv.ShouldBeTrue)
+		}
+	})
+}
@@ -49,8 +49,12 @@ func (qr *MillerLSQ) QR2Cov(wycol int) (covFromQR *CovMatrix, means []float64, N
 	xmeans := qr.XStats.Mean()
 	ymeans := qr.YStats.Mean()
 
-	// fill the mean of the 1s column
-	means = append([]float64{1}, xmeans...)
+	// fill the mean of the 1s column, if there is one
+	if qr.NoIntercept {
+		means = append([]float64{}, xmeans...)
+	} else {
+		means = append([]float64{1}, xmeans...)
+	}
 	// just do one y-target column, wycol, to avoid colinearity issues
 	// and to try and preserve the accuracy of the y-predictions as
 	// much as possible.
@@ -123,12 +127,18 @@ const SetQRCompressed = true
 //
 func (m *UpperTriMatrix) Cov2QR(cov *CovMatrix, nr float64, xmean []float64, ymean float64, bCompress bool) {
 
-	Numxvar := len(xmean) - 1
 	if xmean[0] != 1 {
 		panic("xmean[0] should be 1, for the const column")
 	}
+	m.cov2QR(cov, nr, xmean, ymean, bCompress)
+}
+
+// cov2QR(): Cov2QR() without the constant column check, so that it
+// also serves models without an intercept, whose xmean are those of
+// the x-variables alone.
+func (m *UpperTriMatrix) cov2QR(cov *CovMatrix, nr float64, xmean []float64, ymean float64, bCompress bool) {
 
-	nc := Numxvar + 2 // + 1 is for the constant, +1 for y
+	nc := len(xmean) + 1 // +1 for y
 
 	if nc != m.Ncol {
 		panic(fmt.Sprintf("m.Ncol is the wrong size(%d). We expected %d since len(xmean) was %d\n", m.Ncol, nc, len(xmean)))
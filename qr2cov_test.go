@@ -452,3 +452,32 @@ func TestCombineOfTwoLSQWithTwoY(t *testing.T) {
 	})
 
 }
+
+func TestCombineOfTwoLSQNoIntercept(t *testing.T) {
+	xf, err := readData("smallfuel.dat")
+	if err != nil {
+		panic(err)
+	}
+
+	nxvar := xf.Ncol - 1
+	nyvar := 1
+	qr1 := NewMillerLSQIntercept(nxvar, nyvar, !AddIntercept)
+	qr2 := NewMillerLSQIntercept(nxvar, nyvar, !AddIntercept)
+	qrf := NewMillerLSQIntercept(nxvar, nyvar, !AddIntercept)
+	for i := range xf.Rows {
+		if i < 5 {
+			qr1.Includ(1.0, xf.Rows[i][:nxvar], xf.Rows[i][nxvar:], NAN_OMIT_ROW)
+		} else {
+			qr2.Includ(1.0, xf.Rows[i][:nxvar], xf.Rows[i][nxvar:], NAN_OMIT_ROW)
+		}
+		qrf.Includ(1.0, xf.Rows[i][:nxvar], xf.Rows[i][nxvar:], NAN_OMIT_ROW)
+	}
+
+	lsqRe := LsqCombineAllRhs(qr1, qr2)
+
+	cv.Convey("Merging two MillerLSQ without an intercept should match the sequentially computed state", t, func() {
+		cv.So(lsqRe.NoIntercept, cv.ShouldBeTrue)
+		cv.So(lsqRe.Ncol, cv.ShouldEqual, nxvar)
+		cv.So(CompareLSQ(lsqRe, qrf), cv.ShouldEqual, true)
+	})
+}
@@ -0,0 +1,222 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Ridge regression from an already populated MillerLSQ.
+//
+// Minimizing ||y - Xb||^2 + lambda * sum_j w_j * b_j^2 is the same as
+// ordinary least squares on X with one extra row sqrt(lambda*w_j)*e_j
+// (and a y of 0) for every penalized variable j. So for each lambda we
+// rotate those pseudo-rows into a copy of the factorization with the
+// same Givens sweep that Includ() uses, and then call Regcf() and Inv()
+// exactly as for an ordinary fit. The data is not looked at again.
+
+// RidgeOptions controls RidgePath().
+type RidgeOptions struct {
+	// Lambdas is the path of penalties to fit, in the order given.
+	// Each must be >= 0. A lambda of 0 gives the ordinary least
+	// squares fit.
+	Lambdas []float64
+
+	// Standardize penalizes the coefficients of the x-variables after
+	// scaling each to unit variance (dividing by n, as MASS::lm.ridge
+	// does), so that the penalty does not depend on the units of x.
+	// Without an intercept, the x-variables are scaled about zero.
+	// The coefficients returned are always on the original scale.
+	// Without Standardize, the raw coefficients are penalized.
+	Standardize bool
+
+	// PenalizeIntercept also shrinks the intercept. Normally it is
+	// left alone, so that the fit still goes through the means.
+	PenalizeIntercept bool
+}
+
+// RidgeFit is the ridge fit for one lambda.
+type RidgeFit struct {
+	Lambda float64
+
+	// Coef is in the original variable order: the intercept first,
+	// if there is one, then x-variables 1, 2, ..., whatever the
+	// current m.Vorder.
+	Coef []float64
+
+	Df  float64 // effective degrees of freedom, the trace of the hat matrix
+	Rss float64 // residual sum of squares of the fit
+
+	// GCV is the generalized cross-validation score,
+	// n * Rss / (n - Df)^2.
+	GCV float64
+}
+
+// RidgePath is returned by RidgePath().
+type RidgePath struct {
+	Wycol int
+	Fits  []*RidgeFit
+	Best  int // index into Fits of the smallest GCV
+}
+
+// RidgePath(): fit a ridge regression of the wycol-th y-target on every
+// x-variable, for each lambda in opt.Lambdas. m itself is not modified.
+func (m *MillerLSQ) RidgePath(wycol int, opt RidgeOptions) (*RidgePath, error) {
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("RidgePath() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	if len(opt.Lambdas) == 0 {
+		return nil, errors.New("RidgePath() error: no Lambdas given")
+	}
+	for _, lambda := range opt.Lambdas {
+		if lambda < 0 || math.IsNaN(lambda) || math.IsInf(lambda, 0) {
+			return nil, errors.New(fmt.Sprintf("RidgePath() error: lambda(%v) must be finite and >= 0", lambda))
+		}
+	}
+	if !m.Tol_set {
+		m.Tolset(1e-12)
+	}
+
+	weights, err := m.ridgeWeights(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &RidgePath{Wycol: wycol}
+	for _, lambda := range opt.Lambdas {
+		fit, err := m.ridgeFit(wycol, lambda, weights)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Fits) > 0 && fit.GCV < res.Fits[res.Best].GCV {
+			res.Best = len(res.Fits)
+		}
+		res.Fits = append(res.Fits, fit)
+	}
+	return res, nil
+}
+
+// ridgeWeights(): the penalty weight w for each position in m.Vorder,
+// zero for positions that are not penalized.
+func (m *MillerLSQ) ridgeWeights(opt RidgeOptions) ([]float64, error) {
+	w := make([]float64, m.Ncol)
+
+	// the constant's position, for the variances
+	c := -1
+	for i, v := range m.Vorder {
+		if v == 0 && !m.NoIntercept {
+			c = i
+		}
+	}
+	n := m.AccumWeightSum
+	if c >= 0 {
+		n = m.DotColumns(c, c)
+	}
+	if n <= 0 {
+		return nil, errors.New("RidgePath() error: no observations have been included")
+	}
+
+	for i := range m.Vorder {
+		switch {
+		case c == i:
+			if opt.PenalizeIntercept {
+				w[i] = 1
+			}
+		case opt.Standardize:
+			// the variance of x about its mean, from X'X
+			sx := 0.0
+			if c >= 0 {
+				sx = m.DotColumns(c, i)
+			}
+			w[i] = (m.DotColumns(i, i) - sx*sx/n) / n
+		default:
+			w[i] = 1
+		}
+	}
+	return w, nil
+}
+
+// ridgeFit(): fit one lambda on a copy of the factorization.
+func (m *MillerLSQ) ridgeFit(wycol int, lambda float64, weights []float64) (*RidgeFit, error) {
+	nreq := m.Ncol
+	aug := m.Clone()
+	if lambda > 0 {
+		for i, w := range weights {
+			if w == 0 {
+				continue
+			}
+			zero_out(aug.Curxrow)
+			zero_out(aug.Curyrow)
+			aug.Curxrow[i] = 1
+			aug.givens(lambda * w)
+		}
+	}
+
+	err, beta := aug.Regcf(Seq(nreq-m.nconst()), wycol)
+	if err != nil {
+		return nil, err
+	}
+
+	fit := &RidgeFit{
+		Lambda: lambda,
+		Coef:   make([]float64, nreq),
+		Df:     float64(nreq),
+	}
+	for i, v := range m.Vorder {
+		fit.Coef[v-1+m.nconst()] = beta[i]
+	}
+
+	// The hat matrix is X (X'X + L)^-1 X', so its trace is
+	// nreq - sum_j lambda*w_j * [(X'X + L)^-1]_jj.
+	if lambda > 0 {
+		aug.Dim_rinv = nreq * (nreq - 1) / 2
+		aug.Rinv = make([]float64, aug.Dim_rinv)
+		aug.Inv(nreq, aug.Rinv)
+		for i, w := range weights {
+			if w != 0 {
+				fit.Df -= lambda * w * aug.invDiag(nreq, i)
+			}
+		}
+	}
+
+	// Rss = Sserr + sum_i D_i * (Rhs_i - (R beta)_i)^2, on the
+	// original factorization.
+	fit.Rss = m.Sserr[wycol]
+	for i := 0; i < nreq; i++ {
+		r := m.Rhs[wycol][i] - beta[i]
+		nextr := m.Row_ptr[i]
+		for j := i + 1; j < nreq; j++ {
+			r -= m.R[nextr] * beta[j]
+			nextr++
+		}
+		fit.Rss += m.D[i] * r * r
+	}
+
+	n := m.AccumWeightSum
+	fit.GCV = n * fit.Rss / ((n - fit.Df) * (n - fit.Df))
+	return fit, nil
+}
+
+// invDiag(): element [i,i] (0-based position) of (R'DR)^-1 for the
+// first nreq positions, after Inv() has filled m.Rinv. This is the
+// diagonal of the loop in Cov(), without the residual variance.
+func (m *MillerLSQ) invDiag(nreq int, i int) float64 {
+	total := 1.0 / m.D[i]
+
+	// start of row i of Rinv, which is packed like R
+	start := i*nreq - i*(i+1)/2
+	for k := i + 1; k < nreq; k++ {
+		rk := m.Rinv[start+k-i-1]
+		total += rk * rk / m.D[k]
+	}
+	return total
+}
+
+func (r *RidgePath) String() string {
+	s := fmt.Sprintf("Ridge path for y-target %d (best GCV at lambda %.6g)\n", r.Wycol, r.Fits[r.Best].Lambda)
+	s += fmt.Sprintf("%12s  %8s  %14s  %12s  %s\n", "lambda", "df", "RSS", "GCV", "coefficients")
+	for _, f := range r.Fits {
+		s += fmt.Sprintf("%12.6g  %8.4f  %14.6g  %12.6g  %v\n", f.Lambda, f.Df, f.Rss, f.GCV, f.Coef)
+	}
+	return s
+}
@@ -0,0 +1,202 @@
+package lsq
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestRidgePath(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+
+	// Fuel_Pop ~ Tax + Income + RoadMls + DLic
+	xcols := []int{2, 4, 5, 7}
+	last := df.Ncol - 1
+	p := len(xcols) + 1
+
+	m := NewMillerLSQ(len(xcols), 1)
+
+	// the normal equations, built directly from the data, to check against
+	xtx := make([][]float64, p)
+	for i := range xtx {
+		xtx[i] = make([]float64, p)
+	}
+	xty := make([]float64, p)
+
+	xrow := make([]float64, len(xcols))
+	full := make([]float64, p)
+	for i := range df.Rows {
+		full[0] = 1
+		for k, j := range xcols {
+			xrow[k] = df.Rows[i][j]
+			full[k+1] = df.Rows[i][j]
+		}
+		m.Includ(1.0, xrow, df.Rows[i][last:], NAN_OMIT_ROW)
+
+		y := df.Rows[i][last]
+		for a := 0; a < p; a++ {
+			xty[a] += full[a] * y
+			for b := 0; b < p; b++ {
+				xtx[a][b] += full[a] * full[b]
+			}
+		}
+	}
+	n := float64(len(df.Rows))
+
+	// solve (X'X + diag(pen)) b = X'y, and the trace of (X'X + diag(pen))^-1 X'X
+	direct := func(pen []float64) (beta []float64, edf float64) {
+		a := make([][]float64, p)
+		for i := range a {
+			a[i] = make([]float64, 2*p+1)
+			copy(a[i], xtx[i])
+			a[i][i] += pen[i]
+			copy(a[i][p:], xtx[i])
+			a[i][2*p] = xty[i]
+		}
+		for c := 0; c < p; c++ {
+			for r := 0; r < p; r++ {
+				if r == c {
+					continue
+				}
+				f := a[r][c] / a[c][c]
+				for k := c; k <= 2*p; k++ {
+					a[r][k] -= f * a[c][k]
+				}
+			}
+		}
+		beta = make([]float64, p)
+		for i := 0; i < p; i++ {
+			beta[i] = a[i][2*p] / a[i][i]
+			edf += a[i][p+i] / a[i][i]
+		}
+		return
+	}
+
+	lambdas := []float64{0, 0.5, 5, 50, 500}
+	mean := func(j int) float64 { return xtx[0][j] / n }
+
+	cv.Convey("Given the fuelcons.dat data in a MillerLSQ, RidgePath() with lambda of 0 should give the ordinary least squares fit", t, func() {
+		path, err := m.RidgePath(0, RidgeOptions{Lambdas: lambdas})
+		cv.So(err, cv.ShouldBeNil)
+		fmt.Printf("\n%s\n", path)
+
+		ols, err := m.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(EpsSliceEqual(path.Fits[0].Coef, ols.Coef, 1e-8), cv.ShouldBeTrue)
+		cv.So(path.Fits[0].Df, cv.ShouldAlmostEqual, float64(p), 1e-12)
+		cv.So(path.Fits[0].Rss, cv.ShouldAlmostEqual, ols.Rss, 1e-6)
+
+		cv.Convey("and each lambda should agree with solving the penalized normal equations directly", func() {
+			for _, f := range path.Fits {
+				pen := make([]float64, p)
+				for j := 1; j < p; j++ {
+					pen[j] = f.Lambda
+				}
+				beta, edf := direct(pen)
+				for j := range beta {
+					cv.So(f.Coef[j], cv.ShouldAlmostEqual, beta[j], 1e-6*(1+math.Abs(beta[j])))
+				}
+				cv.So(f.Df, cv.ShouldAlmostEqual, edf, 1e-8)
+				cv.So(f.GCV, cv.ShouldAlmostEqual, n*f.Rss/((n-f.Df)*(n-f.Df)), 1e-6)
+			}
+		})
+
+		cv.Convey("and the effective degrees of freedom should shrink, and the RSS grow, as lambda grows", func() {
+			for i := 1; i < len(path.Fits); i++ {
+				cv.So(path.Fits[i].Df, cv.ShouldBeLessThan, path.Fits[i-1].Df)
+				cv.So(path.Fits[i].Rss, cv.ShouldBeGreaterThan, path.Fits[i-1].Rss)
+			}
+			for i := range path.Fits {
+				cv.So(path.Fits[path.Best].GCV <= path.Fits[i].GCV, cv.ShouldBeTrue)
+			}
+		})
+	})
+
+	cv.Convey("With Standardize, the penalty on each raw coefficient should be lambda times the variance of its x", t, func() {
+		path, err := m.RidgePath(0, RidgeOptions{Lambdas: []float64{10}, Standardize: true})
+		cv.So(err, cv.ShouldBeNil)
+
+		pen := make([]float64, p)
+		for j := 1; j < p; j++ {
+			pen[j] = 10 * (xtx[j][j]/n - mean(j)*mean(j))
+		}
+		beta, edf := direct(pen)
+		for j := range beta {
+			cv.So(path.Fits[0].Coef[j], cv.ShouldAlmostEqual, beta[j], 1e-6*(1+math.Abs(beta[j])))
+		}
+		cv.So(path.Fits[0].Df, cv.ShouldAlmostEqual, edf, 1e-8)
+	})
+
+	cv.Convey("With PenalizeIntercept, the intercept should be shrunk as well, and m should be left untouched", t, func() {
+		before := m.Clone()
+		path, err := m.RidgePath(0, RidgeOptions{Lambdas: []float64{5}, PenalizeIntercept: true})
+		cv.So(err, cv.ShouldBeNil)
+
+		pen := []float64{5, 5, 5, 5, 5}
+		beta, edf := direct(pen)
+		for j := range beta {
+			cv.So(path.Fits[0].Coef[j], cv.ShouldAlmostEqual, beta[j], 1e-6*(1+math.Abs(beta[j])))
+		}
+		cv.So(path.Fits[0].Df, cv.ShouldAlmostEqual, edf, 1e-8)
+		cv.So(CompareLSQ(before, m), cv.ShouldBeTrue)
+	})
+
+	cv.Convey("Without an intercept, each lambda should agree with the uncentered penalized normal equations", t, func() {
+		// Fuel_Pop ~ Tax + DLic - 1
+		cols := []int{2, 7}
+		q := len(cols)
+		m0 := NewMillerLSQIntercept(q, 1, !AddIntercept)
+		a := [][]float64{make([]float64, q), make([]float64, q)}
+		b := make([]float64, q)
+		row := make([]float64, q)
+		for i := range df.Rows {
+			for k, j := range cols {
+				row[k] = df.Rows[i][j]
+			}
+			m0.Includ(1.0, row, df.Rows[i][last:], NAN_OMIT_ROW)
+			for r := 0; r < q; r++ {
+				b[r] += row[r] * df.Rows[i][last]
+				for c := 0; c < q; c++ {
+					a[r][c] += row[r] * row[c]
+				}
+			}
+		}
+
+		// (X'X + diag(pen)) beta = X'y, for two x-variables
+		solve := func(pen []float64) []float64 {
+			a00, a01, a11 := a[0][0]+pen[0], a[0][1], a[1][1]+pen[1]
+			det := a00*a11 - a01*a01
+			return []float64{(a11*b[0] - a01*b[1]) / det, (a00*b[1] - a01*b[0]) / det}
+		}
+
+		path, err := m0.RidgePath(0, RidgeOptions{Lambdas: lambdas})
+		cv.So(err, cv.ShouldBeNil)
+		for _, f := range path.Fits {
+			cv.So(len(f.Coef), cv.ShouldEqual, q)
+			beta := solve([]float64{f.Lambda, f.Lambda})
+			for j := range beta {
+				cv.So(f.Coef[j], cv.ShouldAlmostEqual, beta[j], 1e-6*(1+math.Abs(beta[j])))
+			}
+		}
+		cv.So(path.Fits[0].Df, cv.ShouldAlmostEqual, float64(q), 1e-12)
+
+		// standardized about zero, as there is no mean to take out
+		path, err = m0.RidgePath(0, RidgeOptions{Lambdas: []float64{10}, Standardize: true})
+		cv.So(err, cv.ShouldBeNil)
+		beta := solve([]float64{10 * a[0][0] / n, 10 * a[1][1] / n})
+		for j := range beta {
+			cv.So(path.Fits[0].Coef[j], cv.ShouldAlmostEqual, beta[j], 1e-6*(1+math.Abs(beta[j])))
+		}
+	})
+
+	cv.Convey("A negative lambda should be an error", t, func() {
+		_, err := m.RidgePath(0, RidgeOptions{Lambdas: []float64{-1}})
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,210 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Heteroscedasticity-consistent (sandwich) covariance matrices.
+//
+// Cov() assumes every observation has the same error variance. The
+// sandwich estimator does not: with B = (X'WX)^-1, the bread, and
+//
+//	M = sum over rows of  w^2 e^2 omega x x'
+//
+// the meat, the covariance of the coefficients is B M B. The residuals e
+// are only known once the fit is, so M takes a second pass over the
+// rows, from a RowSource. The HC types differ only in omega, which
+// corrects for the leverage h of each row, from Hdiag():
+//
+//	HC0: omega = 1                   (White, 1980)
+//	HC1: HC0 scaled by n/(n-p)       (Stata's "robust")
+//	HC2: omega = 1/(1-h)
+//	HC3: omega = 1/(1-h)^2           (the default of R's sandwich::vcovHC)
+
+type HCType int
+
+const (
+	HC0 HCType = 0
+	HC1 HCType = 1
+	HC2 HCType = 2
+	HC3 HCType = 3
+)
+
+func (hc HCType) String() string {
+	if hc < HC0 || hc > HC3 {
+		return fmt.Sprintf("HCType(%d)", int(hc))
+	}
+	return fmt.Sprintf("HC%d", int(hc))
+}
+
+// RobustFit(): Fit() for the wycol-th y-target, but with the
+// heteroscedasticity-consistent covariance matrix of type hc, and the
+// standard errors, t-values, p-values and overall (Wald) F test that
+// follow from it. src must hold the rows that were given to Includ(),
+// with cols saying where x, y and the weight are in each row; it is read
+// once, from the start. Rows with NaNs are skipped or zeroed as
+// m.NanApproach says, which should match what Includ() was told.
+func (m *MillerLSQ) RobustFit(wycol int, src RowSource, cols Columns, hc HCType) (*FitResult, error) {
+	if hc < HC0 || hc > HC3 {
+		return nil, errors.New(fmt.Sprintf("RobustFit() error: unknown type %v", hc))
+	}
+	fit, bread, err := m.robustStart(wycol)
+	if err != nil {
+		return nil, err
+	}
+	p := len(fit.Coef)
+	meat := NewSquareMatrix(p)
+
+	xfull := make([]float64, p)
+	xpos := make([]float64, p)
+	ynorm := make([]float64, m.Nyvar)
+	err = m.eachRow(src, cols, func(i int64, weight float64, x []float64, y []float64, row []float64) error {
+		if weight < 0 {
+			return errors.New("RobustFit() error: rows with negative weights, downdating others, cannot be used")
+		}
+		m.normalize(x, y, xfull, ynorm)
+		e := ynorm[wycol]
+		for i, b := range fit.Coef {
+			e -= b * xfull[i]
+		}
+
+		omega := 1.0
+		if hc == HC2 || hc == HC3 {
+			m.toPositions(xfull, xpos)
+			h, err := m.Hdiag(xpos, p)
+			if err != nil {
+				return err
+			}
+			h *= weight
+			if h >= 1-1e-10 {
+				// the row is fit exactly, whatever its error
+				return nil
+			}
+			omega = 1 / (1 - h)
+			if hc == HC3 {
+				omega *= omega
+			}
+		}
+		s := weight * weight * e * e * omega
+		addOuter(meat, xfull, s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	v := sandwich(bread, meat)
+	if hc == HC1 {
+		v.DivideBy(fit.DfResid / (fit.DfResid + float64(p)))
+	}
+	return fit.withCov(v, hc.String())
+}
+
+// robustStart(): the usual fit, and the bread of the sandwich, (X'WX)^-1,
+// in the order of fit.Coef.
+func (m *MillerLSQ) robustStart(wycol int) (fit *FitResult, bread *SquareMatrix, err error) {
+	fit, err = m.Fit(wycol)
+	if err != nil {
+		return nil, nil, err
+	}
+	sigma2 := fit.Sigma * fit.Sigma
+	if sigma2 <= 0 || math.IsNaN(sigma2) {
+		return nil, nil, errors.New(fmt.Sprintf("robust covariance error: the residual variance is %v", sigma2))
+	}
+	p := len(fit.Coef)
+	bread = NewSquareMatrix(p)
+	copy(bread.A, fit.Vcov.A)
+	bread.DivideBy(sigma2)
+	return fit, bread, nil
+}
+
+// addOuter(): a += s x x'
+func addOuter(a *SquareMatrix, x []float64, s float64) {
+	n := a.Ncol
+	for i := 0; i < n; i++ {
+		if x[i] == 0 {
+			continue
+		}
+		sxi := s * x[i]
+		for j := 0; j < n; j++ {
+			a.A[i*n+j] += sxi * x[j]
+		}
+	}
+}
+
+// sandwich(): b m b, for symmetric b.
+func sandwich(b *SquareMatrix, m *SquareMatrix) *SquareMatrix {
+	n := b.Ncol
+	bm := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for k := 0; k < n; k++ {
+			bik := b.A[i*n+k]
+			if bik == 0 {
+				continue
+			}
+			for j := 0; j < n; j++ {
+				bm[i*n+j] += bik * m.A[k*n+j]
+			}
+		}
+	}
+	v := NewSquareMatrix(n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			s := 0.0
+			for k := 0; k < n; k++ {
+				s += bm[i*n+k] * b.A[k*n+j]
+			}
+			v.A[i*n+j] = s
+		}
+	}
+	return v
+}
+
+// withCov(): a copy of r, with the covariance matrix v, and the standard
+// errors, t-values, p-values and overall F test recomputed from it. The
+// F test becomes the Wald test that every slope is zero, or NaN if v is
+// not positive definite enough to compute it.
+func (r *FitResult) withCov(v *SquareMatrix, covType string) (*FitResult, error) {
+	res := *r
+	res.CovType = covType
+	res.Vcov = v
+	res.StdErr = make([]float64, len(r.Coef))
+	res.Tvalue = make([]float64, len(r.Coef))
+	res.Pvalue = make([]float64, len(r.Coef))
+	for i := range r.Coef {
+		res.StdErr[i] = math.Sqrt(v.At(i, i))
+		res.Tvalue[i] = r.Coef[i] / res.StdErr[i]
+		res.Pvalue[i] = 2 * Pt(res.Tvalue[i], r.DfResid)
+	}
+
+	if r.DfModel > 0 {
+		var L [][]float64
+		for i, v := range r.Vars {
+			if v == 0 {
+				continue
+			}
+			row := make([]float64, len(r.Coef))
+			row[i] = 1
+			L = append(L, row)
+		}
+		res.Fstat, res.Fpvalue = math.NaN(), math.NaN()
+		w, err := res.TestLinearHypothesis(L, nil)
+		if err == nil {
+			res.Fstat = w.F
+			res.Fpvalue = w.Pvalue
+		}
+	}
+	return &res, nil
+}
+
+// RobustFit(): as MillerLSQ.RobustFit(), with the names of the Model.
+func (mod *Model) RobustFit(wycol int, src RowSource, cols Columns, hc HCType) (*FitResult, error) {
+	r, err := mod.MillerLSQ.RobustFit(wycol, src, cols, hc)
+	if err != nil {
+		return nil, err
+	}
+	mod.nameResult(r, wycol)
+	return r, nil
+}
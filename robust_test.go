@@ -0,0 +1,211 @@
+package lsq
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+// invert(): the inverse of a small symmetric positive definite matrix, by
+// Gauss-Jordan elimination, to check against.
+func invert(a [][]float64) [][]float64 {
+	n := len(a)
+	w := make([][]float64, n)
+	for i := range w {
+		w[i] = make([]float64, 2*n)
+		copy(w[i], a[i])
+		w[i][n+i] = 1
+	}
+	for c := 0; c < n; c++ {
+		for r := 0; r < n; r++ {
+			if r == c {
+				continue
+			}
+			f := w[r][c] / w[c][c]
+			for k := c; k < 2*n; k++ {
+				w[r][k] -= f * w[c][k]
+			}
+		}
+	}
+	inv := make([][]float64, n)
+	for i := range inv {
+		inv[i] = make([]float64, n)
+		for j := range inv[i] {
+			inv[i][j] = w[i][n+j] / w[i][i]
+		}
+	}
+	return inv
+}
+
+func TestRobustFit(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+
+	// Fuel_Pop ~ Tax + Income + RoadMls + DLic, optionally weighted by Popn
+	xcols := []int{2, 4, 5, 7}
+	p := len(xcols) + 1
+	n := len(df.Rows)
+
+	// the sandwich, computed directly from the data
+	direct := func(weighted bool, hc HCType) [][]float64 {
+		x := make([][]float64, n)
+		w := make([]float64, n)
+		xtx := make([][]float64, p)
+		xty := make([]float64, p)
+		for a := range xtx {
+			xtx[a] = make([]float64, p)
+		}
+		for i := range df.Rows {
+			x[i] = []float64{1}
+			for _, j := range xcols {
+				x[i] = append(x[i], df.Rows[i][j])
+			}
+			w[i] = 1
+			if weighted {
+				w[i] = df.Rows[i][1]
+			}
+			for a := 0; a < p; a++ {
+				xty[a] += w[i] * x[i][a] * df.Rows[i][last]
+				for b := 0; b < p; b++ {
+					xtx[a][b] += w[i] * x[i][a] * x[i][b]
+				}
+			}
+		}
+		bread := invert(xtx)
+		beta := make([]float64, p)
+		for a := 0; a < p; a++ {
+			for b := 0; b < p; b++ {
+				beta[a] += bread[a][b] * xty[b]
+			}
+		}
+		meat := make([][]float64, p)
+		for a := range meat {
+			meat[a] = make([]float64, p)
+		}
+		for i := range df.Rows {
+			e := df.Rows[i][last]
+			h := 0.0
+			for a := 0; a < p; a++ {
+				e -= beta[a] * x[i][a]
+				for b := 0; b < p; b++ {
+					h += x[i][a] * bread[a][b] * x[i][b]
+				}
+			}
+			h *= w[i]
+			omega := 1.0
+			switch hc {
+			case HC2:
+				omega = 1 / (1 - h)
+			case HC3:
+				omega = 1 / ((1 - h) * (1 - h))
+			}
+			for a := 0; a < p; a++ {
+				for b := 0; b < p; b++ {
+					meat[a][b] += w[i] * w[i] * e * e * omega * x[i][a] * x[i][b]
+				}
+			}
+		}
+		v := make([][]float64, p)
+		for a := range v {
+			v[a] = make([]float64, p)
+			for b := 0; b < p; b++ {
+				for k := 0; k < p; k++ {
+					for l := 0; l < p; l++ {
+						v[a][b] += bread[a][k] * meat[k][l] * bread[l][b]
+					}
+				}
+				if hc == HC1 {
+					v[a][b] *= float64(n) / float64(n-p)
+				}
+			}
+		}
+		return v
+	}
+
+	fill := func(weighted bool) *Model {
+		mod := NewModel([]string{"Tax", "Income", "RoadMls", "DLic"}, []string{"Fuel_Pop"})
+		xrow := make([]float64, len(xcols))
+		for i := range df.Rows {
+			for k, j := range xcols {
+				xrow[k] = df.Rows[i][j]
+			}
+			w := 1.0
+			if weighted {
+				w = df.Rows[i][1]
+			}
+			mod.Includ(w, xrow, df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		return mod
+	}
+
+	src := NewSliceSource(df.Rows)
+	cols := Columns{X: xcols, Y: []int{last}, Weight: NoWeight}
+
+	cv.Convey("Given the fuelcons.dat data, RobustFit() should give the sandwich covariance of each HC type", t, func() {
+		mod := fill(false)
+		ols, err := mod.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		for _, hc := range []HCType{HC0, HC1, HC2, HC3} {
+			r, err := mod.RobustFit(0, src, cols, hc)
+			cv.So(err, cv.ShouldBeNil)
+			want := direct(false, hc)
+			for a := 0; a < p; a++ {
+				for b := 0; b < p; b++ {
+					cv.So(r.Vcov.At(a, b), cv.ShouldAlmostEqual, want[a][b], 1e-8*math.Abs(want[a][a]))
+				}
+				cv.So(r.StdErr[a], cv.ShouldAlmostEqual, math.Sqrt(want[a][a]), 1e-8*math.Sqrt(want[a][a]))
+				cv.So(r.Pvalue[a], cv.ShouldAlmostEqual, 2*Pt(r.Coef[a]/r.StdErr[a], ols.DfResid), 1e-12)
+			}
+			cv.So(EpsSliceEqual(r.Coef, ols.Coef, 1e-12), cv.ShouldBeTrue)
+			cv.So(r.CovType, cv.ShouldEqual, hc.String())
+			cv.So(r.Names[4], cv.ShouldEqual, "DLic")
+			if hc == HC3 {
+				fmt.Printf("\n%s\n", r)
+			}
+		}
+		// the usual fit is untouched
+		cv.So(ols.CovType, cv.ShouldEqual, "")
+	})
+
+	cv.Convey("With weights, the meat should use the weighted scores, and the leverages of the weighted fit", t, func() {
+		mod := fill(true)
+		wcols := cols
+		wcols.Weight = 1
+		for _, hc := range []HCType{HC0, HC3} {
+			r, err := mod.RobustFit(0, src, wcols, hc)
+			cv.So(err, cv.ShouldBeNil)
+			want := direct(true, hc)
+			for a := 0; a < p; a++ {
+				cv.So(r.StdErr[a], cv.ShouldAlmostEqual, math.Sqrt(want[a][a]), 1e-8*math.Sqrt(want[a][a]))
+			}
+		}
+	})
+
+	cv.Convey("The robust F test should be the robust Wald test that every slope is zero", t, func() {
+		mod := fill(false)
+		r, err := mod.RobustFit(0, src, cols, HC3)
+		cv.So(err, cv.ShouldBeNil)
+		L, c, err := mod.AllZeroHypothesis(1, 2, 3, 4)
+		cv.So(err, cv.ShouldBeNil)
+		w, err := r.TestLinearHypothesis(L, c)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(r.Fstat, cv.ShouldAlmostEqual, w.F, 1e-12)
+		cv.So(r.Fpvalue, cv.ShouldAlmostEqual, w.Pvalue, 1e-12)
+	})
+
+	cv.Convey("A source that doesn't hold the rows that were included should be an error", t, func() {
+		mod := fill(false)
+		_, err := mod.RobustFit(0, NewSliceSource(df.Rows[1:]), cols, HC0)
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = mod.RobustFit(0, src, Columns{X: xcols[1:], Y: []int{last}, Weight: NoWeight}, HC0)
+		cv.So(err, cv.ShouldNotBeNil)
+		_, err = mod.RobustFit(0, src, cols, HCType(7))
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,200 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// RowSource supplies rows of data one at a time, and can be rewound to
+// read them all again. A single pass is enough to fit a model, but the
+// robust standard errors and the other diagnostics that need each row's
+// residual take a second pass over the same rows, from a RowSource.
+type RowSource interface {
+	// Next returns the next row, or nil when there are no more rows or
+	// after an error; Err() tells which. The slice returned may be
+	// overwritten by the following call to Next.
+	Next() []float64
+
+	// Err returns the error that ended the rows, or nil at a clean end.
+	Err() error
+
+	// Reset rewinds the source to its first row.
+	Reset() error
+}
+
+// SliceSource is a RowSource over rows already in memory, such as
+// the Rows of a DataFrame.
+type SliceSource struct {
+	Rows [][]float64
+	next int
+}
+
+func NewSliceSource(rows [][]float64) *SliceSource {
+	return &SliceSource{Rows: rows}
+}
+
+func (s *SliceSource) Next() []float64 {
+	if s.next >= len(s.Rows) {
+		return nil
+	}
+	s.next++
+	return s.Rows[s.next-1]
+}
+
+func (s *SliceSource) Err() error {
+	return nil
+}
+
+func (s *SliceSource) Reset() error {
+	s.next = 0
+	return nil
+}
+
+// NoWeight, as Columns.Weight, gives every row a weight of 1.
+const NoWeight = -1
+
+// Columns says which columns, numbered from 0, of the rows from a
+// RowSource hold the x-variables, the y-targets, and the weight.
+type Columns struct {
+	X      []int // one per x-variable, in order
+	Y      []int // one per y-target, in order
+	Weight int   // the column of weights, or NoWeight
+}
+
+// split(): pick x, y and the weight out of row.
+func (c *Columns) split(row []float64, x []float64, y []float64) (weight float64, err error) {
+	for k, j := range c.X {
+		if j < 0 || j >= len(row) {
+			return 0, errors.New(fmt.Sprintf("x column %d is out of range for a row of %d columns", j, len(row)))
+		}
+		x[k] = row[j]
+	}
+	for k, j := range c.Y {
+		if j < 0 || j >= len(row) {
+			return 0, errors.New(fmt.Sprintf("y column %d is out of range for a row of %d columns", j, len(row)))
+		}
+		y[k] = row[j]
+	}
+	if c.Weight == NoWeight {
+		return 1, nil
+	}
+	if c.Weight < 0 || c.Weight >= len(row) {
+		return 0, errors.New(fmt.Sprintf("weight column %d is out of range for a row of %d columns", c.Weight, len(row)))
+	}
+	return row[c.Weight], nil
+}
+
+// eachRow(): read src from its first row, and call f with each row that
+// Includ() would have added to m: the raw x and y (not normalized by
+// SetMeanSd()), with missing values filled in or the row skipped as
+// m.NanApproach and SetMissingPolicy() say, and no rows of zero weight.
+// For MISSING_MEAN, the running means are rebuilt as the rows go by,
+// decayed as ForgettingFactor says, so that each row is filled in with
+// just the mean that Includ() used. f also gets the whole row, as read,
+// and its index i among all the rows of src, from 0.
+// The rows are checked against m.Nobs, and the rebuilt means against
+// XStats and YStats, to catch a source that doesn't hold the rows that
+// were included, or a model, decayed by hand or merged, whose running
+// means cannot be rebuilt from its rows.
+func (m *MillerLSQ) eachRow(src RowSource, cols Columns, f func(i int64, weight float64, x []float64, y []float64, row []float64) error) error {
+	if len(cols.X) != m.Nxvar || len(cols.Y) != m.Nyvar {
+		return errors.New(fmt.Sprintf("second pass error: %d x columns and %d y columns given, for a model of %d x-variables and %d y-targets", len(cols.X), len(cols.Y), m.Nxvar, m.Nyvar))
+	}
+	err := src.Reset()
+	if err != nil {
+		return err
+	}
+	x := make([]float64, m.Nxvar)
+	y := make([]float64, m.Nyvar)
+
+	// the running means, as Includ() saw them, for MISSING_MEAN
+	replay := m.usesMean()
+	forget := m.ForgettingFactor > 0 && m.ForgettingFactor < 1
+	if replay && m.Decayed && !forget {
+		return errors.New("second pass error: MISSING_MEAN cannot be replayed after Decay() was called by hand")
+	}
+	xstats := NewSdTracker(m.Nxvar)
+	ystats := NewSdTracker(m.Nyvar)
+
+	var nobs int64
+	var line int64
+	for row := src.Next(); row != nil; row = src.Next() {
+		line++
+		weight, err := cols.split(row, x, y)
+		if err != nil {
+			return errors.New(fmt.Sprintf("second pass error at row %d: %s", line, err))
+		}
+		if math.IsNaN(weight) || math.IsInf(weight, 0) || !m.fillMissingFrom(x, y, m.NanApproach, false, xstats, ystats) {
+			continue
+		}
+		if weight == 0 || math.Abs(weight) < m.Vsmall {
+			continue
+		}
+		if replay && forget {
+			xstats.Decay(m.ForgettingFactor)
+			ystats.Decay(m.ForgettingFactor)
+		}
+		if missingTargets(y) {
+			// included in m.Partial, not m
+			continue
+		}
+		if replay {
+			xstats.AddObs(x, weight)
+			ystats.AddObs(y, weight)
+		}
+		if weight > 0 {
+			nobs++
+		} else {
+			nobs--
+		}
+		err = f(line-1, weight, x, y, row)
+		if err != nil {
+			return err
+		}
+	}
+	if src.Err() != nil {
+		return src.Err()
+	}
+	if !m.Decayed && nobs != m.Nobs {
+		return errors.New(fmt.Sprintf("second pass error: the source had %d observations, but %d were included in the model", nobs, m.Nobs))
+	}
+	if replay && (!sameStats(xstats, &m.XStats) || !sameStats(ystats, &m.YStats)) {
+		return errors.New("second pass error: the rows of the source do not rebuild the running means that MISSING_MEAN filled in from")
+	}
+	return nil
+}
+
+// normalize(): the row as the factorization saw it: x with the constant
+// (if any) in front, in the original variable order, and x and y
+// normalized if SetMeanSd() is in use. xfull has Nxvar+nconst() entries.
+func (m *MillerLSQ) normalize(x []float64, y []float64, xfull []float64, ynorm []float64) {
+	c := m.nconst()
+	if c == 1 {
+		xfull[0] = 1
+	}
+	copy(xfull[c:], x)
+	copy(ynorm, y)
+	if !m.UseMeanSd {
+		return
+	}
+	for j := range x {
+		if m.Xsd[j] != 0.0 {
+			xfull[j+c] = (x[j] - m.Xmean[j]) / m.Xsd[j]
+		}
+	}
+	for j := range y {
+		if m.Ysd[j] != 0.0 {
+			ynorm[j] = (y[j] - m.Ymean[j]) / m.Ysd[j]
+		}
+	}
+}
+
+// toPositions(): xfull, in the original variable order, rearranged into
+// the current position order of m.Vorder, as Hdiag() and Varprd() want it.
+func (m *MillerLSQ) toPositions(xfull []float64, xpos []float64) {
+	c := m.nconst()
+	for i, v := range m.Vorder {
+		xpos[i] = xfull[v-1+c]
+	}
+}
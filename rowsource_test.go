@@ -0,0 +1,67 @@
+package lsq
+
+import (
+	"math"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestSliceSource(t *testing.T) {
+
+	rows := [][]float64{
+		{1, 2, 3, 0.5},
+		{4, math.NaN(), 6, 1},
+		{7, 8, 9, 0},
+		{10, 11, 12, 2},
+	}
+
+	cv.Convey("Given a SliceSource, it should give each row in turn, and start over after Reset()", t, func() {
+		src := NewSliceSource(rows)
+		for pass := 0; pass < 2; pass++ {
+			n := 0
+			for row := src.Next(); row != nil; row = src.Next() {
+				cv.So(row[0], cv.ShouldEqual, rows[n][0])
+				n++
+			}
+			cv.So(n, cv.ShouldEqual, 4)
+			cv.So(src.Err(), cv.ShouldBeNil)
+			cv.So(src.Reset(), cv.ShouldBeNil)
+		}
+	})
+
+	cv.Convey("A second pass should see only the rows Includ() would have added", t, func() {
+		m := NewMillerLSQ(1, 1)
+		cols := Columns{X: []int{1}, Y: []int{2}, Weight: 3}
+		x := make([]float64, 1)
+		y := make([]float64, 1)
+		for _, row := range rows {
+			w, err := cols.split(row, x, y)
+			cv.So(err, cv.ShouldBeNil)
+			m.Includ(w, x, y, NAN_OMIT_ROW)
+		}
+		cv.So(m.Nobs, cv.ShouldEqual, 2)
+
+		var seen []float64
+		var index []int64
+		err := m.eachRow(NewSliceSource(rows), cols, func(i int64, weight float64, x []float64, y []float64, row []float64) error {
+			seen = append(seen, weight)
+			index = append(index, i)
+			return nil
+		})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(seen, cv.ShouldResemble, []float64{0.5, 2})
+		cv.So(index, cv.ShouldResemble, []int64{0, 3})
+
+		// with NaNs zeroed instead, there is one more row, and it no longer matches m
+		m.NanApproach = NAN_TO_ZERO
+		err = m.eachRow(NewSliceSource(rows), cols, func(i int64, weight float64, x []float64, y []float64, row []float64) error { return nil })
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+
+	cv.Convey("A column out of range should be an error", t, func() {
+		cols := Columns{X: []int{5}, Y: []int{2}, Weight: NoWeight}
+		_, err := cols.split(rows[0], make([]float64, 1), make([]float64, 1))
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,241 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Standardized and original scales.
+//
+// With an intercept in the model, a least squares fit does not care
+// where the x-variables and y are centered, or in what units they are
+// measured: standardizing each column, (v - mean)/sd, before the fit
+// gives exactly the coefficients
+//
+//	b*_j = b_j sd_j / sd_y,    b*_0 = (b_0 - mean_y + sum_j b_j mean_j) / sd_y
+//
+// of the fit to the raw columns, and the reverse. Both are linear in
+// b, so the covariance matrix goes along as A V A'. So there is no need
+// to know the means and sds ahead of time, as SetMeanSd() does: fit the
+// raw rows, and standardize at the end with the running means and sds
+// in XStats and YStats; FitStandardized() does just that. FitOriginal()
+// goes the other way, undoing SetMeanSd().
+//
+// Without an intercept only the scaling, and not the centering, can be
+// undone, so the means must then be zero.
+
+// Scale holds the means and sds that standardize the x-variables and
+// one y-target. An sd of zero leaves that column as it is, as it does
+// for SetMeanSd().
+type Scale struct {
+	Xmean []float64 // one per x-variable
+	Xsd   []float64
+	Ymean float64
+	Ysd   float64
+}
+
+// RunningScale(): the means and sds of the rows so far, from XStats and
+// YStats, for the wycol-th y-target.
+func (m *MillerLSQ) RunningScale(wycol int) Scale {
+	if wycol < 0 || wycol >= m.Nyvar {
+		panic(fmt.Sprintf("RunningScale() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	ysd := m.YStats.Sd()
+	return Scale{
+		Xmean: DeepCopy(m.XStats.Mean()),
+		Xsd:   m.XStats.Sd(),
+		Ymean: m.YStats.Mean()[wycol],
+		Ysd:   ysd[wycol],
+	}
+}
+
+// MeanSdScale(): the means and sds given to SetMeanSd(), for the
+// wycol-th y-target; all zero if it was not called, which leaves
+// everything as it is.
+func (m *MillerLSQ) MeanSdScale(wycol int) Scale {
+	if wycol < 0 || wycol >= m.Nyvar {
+		panic(fmt.Sprintf("MeanSdScale() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	if !m.UseMeanSd {
+		return Scale{Xmean: make([]float64, m.Nxvar), Xsd: make([]float64, m.Nxvar)}
+	}
+	return Scale{
+		Xmean: DeepCopy(m.Xmean),
+		Xsd:   DeepCopy(m.Xsd),
+		Ymean: m.Ymean[wycol],
+		Ysd:   m.Ysd[wycol],
+	}
+}
+
+// at(): the mean and sd of column v (0 for y, otherwise the x-variable),
+// with a zero or unusable sd meaning the column is left as it is.
+func (s *Scale) at(v int) (mean float64, sd float64) {
+	if v == 0 {
+		mean, sd = s.Ymean, s.Ysd
+	} else {
+		mean, sd = s.Xmean[v-1], s.Xsd[v-1]
+	}
+	if sd == 0 || math.IsNaN(sd) || math.IsInf(sd, 0) {
+		return 0, 1
+	}
+	return mean, sd
+}
+
+// Standardize(): the fit r, to raw columns, as it would be to the
+// columns standardized by s.
+func (r *FitResult) Standardize(s Scale) (*FitResult, error) {
+	return r.rescale(s, true)
+}
+
+// Unstandardize(): the fit r, to columns standardized by s, as it
+// would be to the raw columns.
+func (r *FitResult) Unstandardize(s Scale) (*FitResult, error) {
+	return r.rescale(s, false)
+}
+
+// rescale(): the coefficients go to A b + c, and the covariance to
+// A V A'; the residual sums of squares scale with the sd of y.
+func (r *FitResult) rescale(s Scale, standardize bool) (*FitResult, error) {
+	p := len(r.Coef)
+	icpt := -1
+	for i, v := range r.Vars {
+		if v == 0 {
+			icpt = i
+			continue
+		}
+		if v-1 >= len(s.Xmean) || v-1 >= len(s.Xsd) {
+			return nil, errors.New(fmt.Sprintf("rescale error: no mean and sd for x-variable %d", v))
+		}
+	}
+	ymean, ysd := s.at(0)
+
+	A := NewSquareMatrix(p)
+	c := make([]float64, p)
+	for i, v := range r.Vars {
+		if v == 0 {
+			continue
+		}
+		mean, sd := s.at(v)
+		if icpt < 0 && mean != 0 {
+			return nil, errors.New(fmt.Sprintf("rescale error: x-variable %d cannot be centered in a model without an intercept", v))
+		}
+		if standardize {
+			A.Set(i, i, sd/ysd)
+			if icpt >= 0 {
+				A.Set(icpt, i, mean/ysd)
+			}
+		} else {
+			A.Set(i, i, ysd/sd)
+			if icpt >= 0 {
+				A.Set(icpt, i, -mean*ysd/sd)
+			}
+		}
+	}
+	if icpt >= 0 {
+		if standardize {
+			A.Set(icpt, icpt, 1/ysd)
+			c[icpt] = -ymean / ysd
+		} else {
+			A.Set(icpt, icpt, ysd)
+			c[icpt] = ymean
+		}
+	} else if ymean != 0 {
+		return nil, errors.New("rescale error: y cannot be centered in a model without an intercept")
+	}
+
+	res := *r
+	res.Names = append([]string{}, r.Names...)
+	res.Vars = DeepCopyInts(r.Vars)
+	res.Coef = make([]float64, p)
+	res.StdErr = make([]float64, p)
+	res.Tvalue = make([]float64, p)
+	res.Pvalue = make([]float64, p)
+	res.Vcov = NewSquareMatrix(p)
+	for i := 0; i < p; i++ {
+		b := c[i]
+		for k := 0; k < p; k++ {
+			b += A.At(i, k) * r.Coef[k]
+		}
+		res.Coef[i] = b
+	}
+	// A V A'
+	av := NewSquareMatrix(p)
+	for i := 0; i < p; i++ {
+		for j := 0; j < p; j++ {
+			sum := 0.0
+			for k := 0; k < p; k++ {
+				sum += A.At(i, k) * r.Vcov.At(k, j)
+			}
+			av.Set(i, j, sum)
+		}
+	}
+	for i := 0; i < p; i++ {
+		for j := 0; j < p; j++ {
+			sum := 0.0
+			for k := 0; k < p; k++ {
+				sum += av.At(i, k) * A.At(j, k)
+			}
+			res.Vcov.Set(i, j, sum)
+		}
+	}
+	for i := range res.Coef {
+		res.StdErr[i] = math.Sqrt(res.Vcov.At(i, i))
+		res.Tvalue[i] = res.Coef[i] / res.StdErr[i]
+		res.Pvalue[i] = 2 * Pt(res.Tvalue[i], res.DfResid)
+	}
+
+	f := ysd
+	if standardize {
+		f = 1 / ysd
+	}
+	res.Sigma *= f
+	res.Rss *= f * f
+	res.Tss *= f * f
+	return &res, nil
+}
+
+// FitOriginal(): Fit(), in the units of the raw x and y even after
+// SetMeanSd().
+func (m *MillerLSQ) FitOriginal(wycol int) (*FitResult, error) {
+	r, err := m.Fit(wycol)
+	if err != nil {
+		return nil, err
+	}
+	if !m.UseMeanSd {
+		return r, nil
+	}
+	return r.Unstandardize(m.MeanSdScale(wycol))
+}
+
+// FitStandardized(): Fit(), with every column standardized by the
+// means and sds of the rows so far, as RunningScale() gives them: the
+// standardized coefficients, or effect sizes.
+func (m *MillerLSQ) FitStandardized(wycol int) (*FitResult, error) {
+	r, err := m.FitOriginal(wycol)
+	if err != nil {
+		return nil, err
+	}
+	return r.Standardize(m.RunningScale(wycol))
+}
+
+// FitOriginal(): as MillerLSQ.FitOriginal(), with the names filled in.
+func (mod *Model) FitOriginal(wycol int) (*FitResult, error) {
+	r, err := mod.MillerLSQ.FitOriginal(wycol)
+	if err != nil {
+		return nil, err
+	}
+	mod.nameResult(r, wycol)
+	return r, nil
+}
+
+// FitStandardized(): as MillerLSQ.FitStandardized(), with the names
+// filled in.
+func (mod *Model) FitStandardized(wycol int) (*FitResult, error) {
+	r, err := mod.MillerLSQ.FitStandardized(wycol)
+	if err != nil {
+		return nil, err
+	}
+	mod.nameResult(r, wycol)
+	return r, nil
+}
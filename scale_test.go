@@ -0,0 +1,137 @@
+package lsq
+
+import (
+	"math"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestScale(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+
+	// Fuel_Pop ~ Tax + Income + RoadMls + DLic
+	xcols := []int{2, 4, 5, 7}
+	nx := len(xcols)
+	n := float64(len(df.Rows))
+
+	// the means and sds, straight from the data
+	cols := append(append([]int{}, xcols...), last)
+	mean := make([]float64, len(cols))
+	sd := make([]float64, len(cols))
+	for k, j := range cols {
+		for i := range df.Rows {
+			mean[k] += df.Rows[i][j] / n
+		}
+		for i := range df.Rows {
+			d := df.Rows[i][j] - mean[k]
+			sd[k] += d * d
+		}
+		sd[k] = math.Sqrt(sd[k] / (n - 1))
+	}
+
+	fill := func(standardize bool, setMeanSd bool) *Model {
+		mod := NewModel([]string{"Tax", "Income", "RoadMls", "DLic"}, []string{"Fuel_Pop"})
+		if setMeanSd {
+			mod.SetMeanSd([]float64{7, 4, 5, 57}, []float64{1, 0.5, 0, 5}, []float64{576}, []float64{111})
+		}
+		xrow := make([]float64, nx)
+		for i := range df.Rows {
+			for k, j := range xcols {
+				xrow[k] = df.Rows[i][j]
+				if standardize {
+					xrow[k] = (xrow[k] - mean[k]) / sd[k]
+				}
+			}
+			y := df.Rows[i][last]
+			if standardize {
+				y = (y - mean[nx]) / sd[nx]
+			}
+			mod.Includ(1, xrow, []float64{y}, NAN_OMIT_ROW)
+		}
+		return mod
+	}
+
+	same := func(got *FitResult, want *FitResult) {
+		cv.So(got.Names, cv.ShouldResemble, want.Names)
+		for i := range want.Coef {
+			cv.So(got.Coef[i], cv.ShouldAlmostEqual, want.Coef[i], 1e-9*(math.Abs(want.Coef[i])+want.StdErr[i]))
+			cv.So(got.StdErr[i], cv.ShouldAlmostEqual, want.StdErr[i], 1e-9*want.StdErr[i])
+			cv.So(got.Tvalue[i], cv.ShouldAlmostEqual, want.Tvalue[i], 1e-8*(1+math.Abs(want.Tvalue[i])))
+			for j := range want.Coef {
+				cv.So(got.Vcov.At(i, j), cv.ShouldAlmostEqual, want.Vcov.At(i, j), 1e-9*want.StdErr[i]*want.StdErr[j])
+			}
+		}
+		cv.So(got.Sigma, cv.ShouldAlmostEqual, want.Sigma, 1e-10*want.Sigma)
+		cv.So(got.Rss, cv.ShouldAlmostEqual, want.Rss, 1e-10*want.Rss)
+		cv.So(got.Rsquared, cv.ShouldAlmostEqual, want.Rsquared, 1e-12)
+	}
+
+	cv.Convey("Given the fuelcons.dat data, FitStandardized() should match a fit to columns standardized beforehand", t, func() {
+		want, err := fill(true, false).Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+
+		raw := fill(false, false)
+		s := raw.RunningScale(0)
+		for k := range xcols {
+			cv.So(s.Xmean[k], cv.ShouldAlmostEqual, mean[k], 1e-10*math.Abs(mean[k]))
+			cv.So(s.Xsd[k], cv.ShouldAlmostEqual, sd[k], 1e-10*sd[k])
+		}
+		got, err := raw.FitStandardized(0)
+		cv.So(err, cv.ShouldBeNil)
+		same(got, want)
+		cv.So(math.Abs(got.Coef[0]) < 1e-10, cv.ShouldBeTrue)
+
+		// and the same after SetMeanSd() with other means and sds
+		got, err = fill(false, true).FitStandardized(0)
+		cv.So(err, cv.ShouldBeNil)
+		same(got, want)
+	})
+
+	cv.Convey("FitOriginal() should undo SetMeanSd(), and Unstandardize() should undo Standardize()", t, func() {
+		want, err := fill(false, false).Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		scaled := fill(false, true)
+		norm, err := scaled.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(math.Abs(norm.Coef[1]-want.Coef[1]) > 1, cv.ShouldBeTrue)
+
+		got, err := scaled.FitOriginal(0)
+		cv.So(err, cv.ShouldBeNil)
+		same(got, want)
+
+		s := scaled.RunningScale(0)
+		std, err := want.Standardize(s)
+		cv.So(err, cv.ShouldBeNil)
+		back, err := std.Unstandardize(s)
+		cv.So(err, cv.ShouldBeNil)
+		same(back, want)
+	})
+
+	cv.Convey("Without an intercept, only the scaling can be undone", t, func() {
+		m := NewMillerLSQIntercept(nx, 1, !AddIntercept)
+		xrow := make([]float64, nx)
+		for i := range df.Rows {
+			for k, j := range xcols {
+				xrow[k] = df.Rows[i][j]
+			}
+			m.Includ(1, xrow, df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		fit, err := m.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		_, err = fit.Standardize(m.RunningScale(0))
+		cv.So(err, cv.ShouldNotBeNil)
+
+		s := Scale{Xmean: make([]float64, nx), Xsd: []float64{2, 3, 4, 5}, Ysd: 10}
+		std, err := fit.Standardize(s)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(std.Coef[1], cv.ShouldAlmostEqual, fit.Coef[1]*3/10, 1e-12*math.Abs(fit.Coef[1]))
+		cv.So(std.Tvalue[1], cv.ShouldAlmostEqual, fit.Tvalue[1], 1e-10*math.Abs(fit.Tvalue[1]))
+		cv.So(std.Rsquared, cv.ShouldEqual, fit.Rsquared)
+	})
+}
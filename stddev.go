@@ -103,6 +103,16 @@ func (s *SdTracker) AddObs(x []float64, weight float64) {
 	}
 }
 
+// Decay(): discount all the observations seen so far by factor, as
+// though each had been added with its weight multiplied by factor.
+// The means are unchanged.
+func (s *SdTracker) Decay(factor float64) {
+	for i := range s.W {
+		s.W[i] *= factor
+		s.Q[i] *= factor
+	}
+}
+
 func (s *SdTracker) Merge(src *SdTracker) {
 
 	if src.Nc != s.Nc {
@@ -0,0 +1,347 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Stepwise variable selection: forward selection, backward elimination,
+// and Efroymson's stepwise method, as in Alan Miller's "Subset Selection
+// in Regression", chapter 3.
+//
+// Like BestSubsets(), this never looks at the data again. The variables
+// currently selected are kept in the positions right after the intercept
+// (and any forced-in variables), and each candidate is tried by moving it
+// with Vmove() to the edge of that block, where m.Rss gives the residual
+// sum of squares with and without it.
+
+type StepMethod int
+
+const (
+	STEP_FORWARD   StepMethod = 0
+	STEP_BACKWARD  StepMethod = 1
+	STEP_EFROYMSON StepMethod = 2
+)
+
+type StepAction int
+
+const (
+	STEP_START  StepAction = 0 // the starting model
+	STEP_ENTER  StepAction = 1
+	STEP_REMOVE StepAction = 2
+)
+
+// StepwiseOptions controls Stepwise().
+type StepwiseOptions struct {
+	Method StepMethod
+
+	// Force and Candidates are as in SubsetOptions.
+	Force      []int
+	Candidates []int
+
+	// Fin is the F-to-enter: a candidate enters only if its F statistic
+	// is at least Fin. Fout is the F-to-remove: a selected variable is
+	// removed if its F statistic is below Fout. Both default to 4.
+	// Efroymson's method needs Fout <= Fin, or it could cycle.
+	Fin  float64
+	Fout float64
+
+	// MaxSteps limits the number of entries and removals. 0 means
+	// no limit beyond the natural one for each method.
+	MaxSteps int
+}
+
+// Step is one line of the trace kept by Stepwise().
+type Step struct {
+	Action StepAction
+	Var    int   // the variable entered or removed; 0 for STEP_START
+	Vars   []int // the x-variables selected after this step, in increasing order
+
+	Rss     float64 // residual sum of squares after this step
+	F       float64 // F statistic for the variable entered or removed
+	Pvalue  float64 // p-value for F, on 1 and the larger model's residual df
+	DfResid float64 // residual degrees of freedom after this step
+}
+
+// StepwiseResult is returned by Stepwise().
+type StepwiseResult struct {
+	Wycol int
+	Trace []*Step
+	Vars  []int // the final selection, not counting Force
+	Rss   float64
+}
+
+// Stepwise(): select x-variables for predicting the wycol-th y-target.
+// The factorization is restored to its original order before returning.
+func (m *MillerLSQ) Stepwise(wycol int, opt StepwiseOptions) (res *StepwiseResult, err error) {
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("Stepwise() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	err = m.checkSubset(append(DeepCopyInts(opt.Force), opt.Candidates...))
+	if err != nil {
+		return nil, err
+	}
+	if opt.Fin <= 0 {
+		opt.Fin = 4
+	}
+	if opt.Fout <= 0 {
+		opt.Fout = 4
+	}
+	if opt.Method == STEP_EFROYMSON && opt.Fout > opt.Fin {
+		return nil, errors.New(fmt.Sprintf("Stepwise() error: Fout(%v) must not exceed Fin(%v) for Efroymson's method", opt.Fout, opt.Fin))
+	}
+
+	cand := opt.Candidates
+	if len(cand) == 0 {
+		forced := make(map[int]bool, len(opt.Force))
+		for _, v := range opt.Force {
+			forced[v] = true
+		}
+		for v := 1; v <= m.Nxvar; v++ {
+			if !forced[v] {
+				cand = append(cand, v)
+			}
+		}
+	}
+
+	if !m.Tol_set {
+		m.Tolset(1e-12)
+	}
+	saved := DeepCopyInts(m.Vorder)
+	defer func() {
+		rerr := m.restoreOrder(saved)
+		if err == nil && rerr != nil {
+			res, err = nil, rerr
+		}
+	}()
+
+	// layout: [ constant | Force | selected | not selected | everything else ]
+	err = m.moveSubsetToFront(opt.Force)
+	if err != nil {
+		return nil, err
+	}
+	err = m.Reorder(cand, m.nconst()+len(opt.Force))
+	if err != nil {
+		return nil, err
+	}
+	for k := range m.Rhs {
+		m.SS(k)
+	}
+
+	s := &stepper{
+		m:     m,
+		wycol: wycol,
+		first: m.nconst() + len(opt.Force),
+		ncand: len(cand),
+		res:   &StepwiseResult{Wycol: wycol},
+	}
+	if opt.Method == STEP_BACKWARD {
+		s.nin = s.ncand
+	}
+	s.trace(STEP_START, 0, 0)
+
+	maxSteps := opt.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = 2*s.ncand + 2
+		if opt.Method == STEP_EFROYMSON {
+			maxSteps = 10 * (s.ncand + 1)
+		}
+	}
+
+	for steps := 0; steps < maxSteps; {
+		switch opt.Method {
+		case STEP_FORWARD:
+			entered, err := s.forward(opt.Fin)
+			if err != nil || !entered {
+				return s.finish(err)
+			}
+			steps++
+
+		case STEP_BACKWARD:
+			removed, err := s.backward(opt.Fout)
+			if err != nil || !removed {
+				return s.finish(err)
+			}
+			steps++
+
+		case STEP_EFROYMSON:
+			entered, err := s.forward(opt.Fin)
+			if err != nil || !entered {
+				return s.finish(err)
+			}
+			steps++
+			for steps < maxSteps {
+				removed, err := s.backward(opt.Fout)
+				if err != nil {
+					return s.finish(err)
+				}
+				if !removed {
+					break
+				}
+				steps++
+			}
+
+		default:
+			return nil, errors.New(fmt.Sprintf("Stepwise() error: unknown Method %v", opt.Method))
+		}
+	}
+	return s.finish(nil)
+}
+
+type stepper struct {
+	m     *MillerLSQ
+	wycol int
+	first int // 0-based position of the first selected variable
+	nin   int // number selected; they are in positions [first, first+nin)
+	ncand int
+	res   *StepwiseResult
+}
+
+// rssIn(): residual sum of squares with the first k selected variables.
+func (s *stepper) rssIn(k int) float64 {
+	return s.m.rssFirst(s.wycol, s.first+k)
+}
+
+func (s *stepper) dfResid(k int) float64 {
+	return s.m.ResidualDf(s.first + k)
+}
+
+// forward(): enter the unselected candidate with the largest F, if that F
+// is at least fin.
+func (s *stepper) forward(fin float64) (entered bool, err error) {
+	if s.nin == s.ncand {
+		return false, nil
+	}
+	m := s.m
+	edge := s.first + s.nin // position a new variable would occupy
+	before := s.rssIn(s.nin)
+
+	bestF, bestVar := -1.0, -1
+	for i := 0; i < s.ncand-s.nin; i++ {
+		// bring the variable at the back of the unselected block to the edge;
+		// after ncand-nin moves, every one of them has had a turn, and
+		// they are back in their original order.
+		if s.ncand-s.nin > 1 {
+			err = m.Vmove(s.first+s.ncand, edge+1) // Vmove is 1-based
+			if err != nil {
+				return false, err
+			}
+		}
+		after := s.rssIn(s.nin + 1)
+		f := (before - after) / (after / s.dfResid(s.nin+1))
+		if f > bestF {
+			bestF, bestVar = f, m.Vorder[edge]
+		}
+	}
+	if bestVar < 0 || bestF < fin {
+		return false, nil
+	}
+
+	err = s.moveTo(bestVar, edge)
+	if err != nil {
+		return false, err
+	}
+	s.nin++
+	s.trace(STEP_ENTER, bestVar, bestF)
+	return true, nil
+}
+
+// backward(): remove the selected variable with the smallest F, if that F
+// is below fout.
+func (s *stepper) backward(fout float64) (removed bool, err error) {
+	if s.nin == 0 {
+		return false, nil
+	}
+	m := s.m
+	edge := s.first + s.nin - 1 // position of the last selected variable
+
+	bestF, bestVar := -1.0, -1
+	for i := 0; i < s.nin; i++ {
+		if s.nin > 1 {
+			err = m.Vmove(s.first+1, edge+1) // Vmove is 1-based
+			if err != nil {
+				return false, err
+			}
+		}
+		with := s.rssIn(s.nin)
+		without := s.rssIn(s.nin - 1)
+		f := (without - with) / (with / s.dfResid(s.nin))
+		if bestVar < 0 || f < bestF {
+			bestF, bestVar = f, m.Vorder[edge]
+		}
+	}
+	if bestF >= fout {
+		return false, nil
+	}
+
+	err = s.moveTo(bestVar, edge)
+	if err != nil {
+		return false, err
+	}
+	s.nin--
+	s.trace(STEP_REMOVE, bestVar, bestF)
+	return true, nil
+}
+
+// moveTo(): Vmove variable v to 0-based position pos.
+func (s *stepper) moveTo(v int, pos int) error {
+	for i, w := range s.m.Vorder {
+		if w == v {
+			if i == pos {
+				return nil
+			}
+			return s.m.Vmove(i+1, pos+1)
+		}
+	}
+	return errors.New(fmt.Sprintf("Stepwise() error: lost track of variable %d", v))
+}
+
+func (s *stepper) selected() []int {
+	vars := DeepCopyInts(s.m.Vorder[s.first : s.first+s.nin])
+	sort.Ints(vars)
+	return vars
+}
+
+func (s *stepper) trace(action StepAction, v int, f float64) {
+	st := &Step{
+		Action:  action,
+		Var:     v,
+		Vars:    s.selected(),
+		Rss:     s.rssIn(s.nin),
+		F:       f,
+		DfResid: s.dfResid(s.nin),
+	}
+	switch action {
+	case STEP_ENTER:
+		st.Pvalue = Pf(f, 1, s.dfResid(s.nin))
+	case STEP_REMOVE:
+		st.Pvalue = Pf(f, 1, s.dfResid(s.nin+1))
+	}
+	s.res.Trace = append(s.res.Trace, st)
+}
+
+func (s *stepper) finish(err error) (*StepwiseResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	s.res.Vars = s.selected()
+	s.res.Rss = s.rssIn(s.nin)
+	return s.res, nil
+}
+
+func (r *StepwiseResult) String() string {
+	s := fmt.Sprintf("Stepwise selection for y-target %d\n", r.Wycol)
+	s += fmt.Sprintf("%-7s  %4s  %14s  %10s  %10s  %s\n", "step", "var", "RSS", "F", "p-value", "variables")
+	for _, st := range r.Trace {
+		switch st.Action {
+		case STEP_START:
+			s += fmt.Sprintf("%-7s  %4s  %14.6g  %10s  %10s  %v\n", "start", "", st.Rss, "", "", st.Vars)
+		case STEP_ENTER:
+			s += fmt.Sprintf("%-7s  %4d  %14.6g  %10.4g  %10.4g  %v\n", "enter", st.Var, st.Rss, st.F, st.Pvalue, st.Vars)
+		case STEP_REMOVE:
+			s += fmt.Sprintf("%-7s  %4d  %14.6g  %10.4g  %10.4g  %v\n", "remove", st.Var, st.Rss, st.F, st.Pvalue, st.Vars)
+		}
+	}
+	return s
+}
@@ -0,0 +1,122 @@
+package lsq
+
+import (
+	"fmt"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestStepwise(t *testing.T) {
+
+	nvar := 7
+	m := NewMillerLSQ(nvar, 1)
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+	for i := range df.Rows {
+		m.Includ(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+	}
+	origVorder := DeepCopyInts(m.Vorder)
+
+	rssOf := func(vars []int) float64 {
+		fit, err := m.FitSubset(vars, 0)
+		if err != nil {
+			panic(err)
+		}
+		return fit.Rss
+	}
+
+	// check every step of a trace against independent fits of its models.
+	checkTrace := func(r *StepwiseResult) {
+		for k, st := range r.Trace {
+			cv.So(st.Rss, cv.ShouldAlmostEqual, rssOf(st.Vars), 1e-6)
+			cv.So(st.DfResid, cv.ShouldEqual, float64(48-1-len(st.Vars)))
+			if k == 0 {
+				cv.So(st.Action, cv.ShouldEqual, STEP_START)
+				continue
+			}
+			prev := r.Trace[k-1]
+			switch st.Action {
+			case STEP_ENTER:
+				cv.So(len(st.Vars), cv.ShouldEqual, len(prev.Vars)+1)
+				cv.So(st.F, cv.ShouldAlmostEqual, (prev.Rss-st.Rss)/(st.Rss/st.DfResid), 1e-8)
+			case STEP_REMOVE:
+				cv.So(len(st.Vars), cv.ShouldEqual, len(prev.Vars)-1)
+				cv.So(st.F, cv.ShouldAlmostEqual, (st.Rss-prev.Rss)/(prev.Rss/prev.DfResid), 1e-8)
+			}
+		}
+		cv.So(IntSliceEqual(m.Vorder, origVorder), cv.ShouldBeTrue)
+	}
+
+	cv.Convey("Given the fuelcons.dat data, forward selection with F-to-enter of 4", t, func() {
+		r, err := m.Stepwise(0, StepwiseOptions{Method: STEP_FORWARD, Fin: 4})
+		cv.So(err, cv.ShouldBeNil)
+		fmt.Printf("\n%s\n", r)
+
+		cv.Convey("should enter DLic, then Income, then Tax, and stop", func() {
+			cv.So(len(r.Trace), cv.ShouldEqual, 4)
+			cv.So(r.Trace[1].Var, cv.ShouldEqual, 7)
+			cv.So(r.Trace[2].Var, cv.ShouldEqual, 4)
+			cv.So(r.Trace[3].Var, cv.ShouldEqual, 2)
+			cv.So(IntSliceEqual(r.Vars, []int{2, 4, 7}), cv.ShouldBeTrue)
+			cv.So(r.Rss, cv.ShouldAlmostEqual, 191302.45441913296, 1e-6)
+			checkTrace(r)
+		})
+	})
+
+	cv.Convey("Backward elimination with F-to-remove of 4", t, func() {
+		r, err := m.Stepwise(0, StepwiseOptions{Method: STEP_BACKWARD, Fout: 4})
+		cv.So(err, cv.ShouldBeNil)
+		fmt.Printf("\n%s\n", r)
+
+		cv.Convey("should start from the full model, and each variable left should have F >= 4", func() {
+			cv.So(len(r.Trace[0].Vars), cv.ShouldEqual, nvar)
+			checkTrace(r)
+			df := float64(48 - 1 - len(r.Vars))
+			for _, v := range r.Vars {
+				without := []int{}
+				for _, w := range r.Vars {
+					if w != v {
+						without = append(without, w)
+					}
+				}
+				f := (rssOf(without) - r.Rss) / (r.Rss / df)
+				cv.So(f, cv.ShouldBeGreaterThan, 4)
+			}
+		})
+	})
+
+	cv.Convey("Efroymson's method", t, func() {
+		r, err := m.Stepwise(0, StepwiseOptions{Method: STEP_EFROYMSON, Fin: 4, Fout: 3.9})
+		cv.So(err, cv.ShouldBeNil)
+		fmt.Printf("\n%s\n", r)
+		checkTrace(r)
+
+		cv.Convey("should refuse Fout > Fin", func() {
+			_, err := m.Stepwise(0, StepwiseOptions{Method: STEP_EFROYMSON, Fin: 2, Fout: 4})
+			cv.So(err, cv.ShouldNotBeNil)
+		})
+
+		cv.Convey("should keep forced variables in, and only consider the candidates", func() {
+			r, err := m.Stepwise(0, StepwiseOptions{Method: STEP_EFROYMSON, Force: []int{1}, Candidates: []int{2, 3, 7}})
+			cv.So(err, cv.ShouldBeNil)
+			checkForced := func(vars []int) bool {
+				for _, v := range vars {
+					if v == 4 || v == 5 || v == 6 || v == 1 {
+						return false
+					}
+				}
+				return true
+			}
+			for _, st := range r.Trace {
+				cv.So(checkForced(st.Vars), cv.ShouldBeTrue)
+				cv.So(st.Rss, cv.ShouldAlmostEqual, rssOf(append([]int{1}, st.Vars...)), 1e-6)
+			}
+			cv.So(IntSliceEqual(m.Vorder, origVorder), cv.ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,332 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Best-subset regression, in the spirit of Alan Miller's SUBSETS
+// module that AS 274 was written to drive, and of Furnival and
+// Wilson's "Regressions by Leaps and Bounds", Technometrics (1974).
+//
+// Nothing here touches the data again. Every subset's residual sum
+// of squares is read from m.Rss after moving variables around in the
+// factorization with Vmove().
+
+// SelectionCriterion chooses how subsets of different sizes are compared.
+// Within one size, all the criteria rank subsets identically (by RSS).
+type SelectionCriterion int
+
+const (
+	CRIT_RSS   SelectionCriterion = 0
+	CRIT_ADJR2 SelectionCriterion = 1 // adjusted R-squared, larger is better
+	CRIT_CP    SelectionCriterion = 2 // Mallows' Cp
+	CRIT_AIC   SelectionCriterion = 3
+	CRIT_BIC   SelectionCriterion = 4
+)
+
+// SubsetOptions controls BestSubsets().
+type SubsetOptions struct {
+	// Force lists x-variables (1-based, as in RegcfSubset()) that are in
+	// every subset considered. The intercept is always forced in.
+	Force []int
+
+	// Candidates lists the x-variables to search over. If empty,
+	// every x-variable not in Force is a candidate.
+	Candidates []int
+
+	// Nvmax is the largest subset size to search, counting the Force
+	// variables but not the intercept. 0 means no limit.
+	Nvmax int
+
+	// Nbest is the number of subsets to keep for each size. 0 means 1.
+	Nbest int
+
+	// Exhaustive turns off the branch-and-bound pruning, so that
+	// every subset is visited. The answers are the same either way.
+	Exhaustive bool
+}
+
+// Subset describes one fitted subset, with the usual selection criteria.
+type Subset struct {
+	Vars []int // x-variable numbers in the subset, in increasing order
+	Size int   // len(Vars); the intercept is not counted
+
+	Rss         float64
+	Rsquared    float64
+	AdjRsquared float64
+	Cp          float64 // Mallows' Cp, using the full model's residual variance; NaN if it has none
+	AIC         float64 // n*log(Rss/n) + 2*p, as R's extractAIC() for lm
+	BIC         float64 // n*log(Rss/n) + log(n)*p
+}
+
+// SubsetSearch is returned by BestSubsets().
+type SubsetSearch struct {
+	Wycol int
+	Force []int
+
+	// BySize[s] holds up to Nbest subsets of s x-variables, best first.
+	// Sizes smaller than len(Force) are left empty.
+	BySize [][]*Subset
+
+	// Visited counts the subsets whose RSS was examined; compare it
+	// with 2^len(Candidates) to see what the bounds saved.
+	Visited int64
+}
+
+// BestSubsets(): find the best subsets of each size for predicting the
+// wycol-th y-target.
+//
+// The factorization is restored to its original order before returning.
+func (m *MillerLSQ) BestSubsets(wycol int, opt SubsetOptions) (res *SubsetSearch, err error) {
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("BestSubsets() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	err = m.checkSubset(append(DeepCopyInts(opt.Force), opt.Candidates...))
+	if err != nil {
+		return nil, err
+	}
+
+	cand := opt.Candidates
+	if len(cand) == 0 {
+		forced := make(map[int]bool, len(opt.Force))
+		for _, v := range opt.Force {
+			forced[v] = true
+		}
+		for v := 1; v <= m.Nxvar; v++ {
+			if !forced[v] {
+				cand = append(cand, v)
+			}
+		}
+	}
+
+	s := &subsetSearcher{
+		m:          m,
+		wycol:      wycol,
+		nforce:     len(opt.Force),
+		nvmax:      opt.Nvmax,
+		nbest:      opt.Nbest,
+		exhaustive: opt.Exhaustive,
+	}
+	if s.nvmax <= 0 || s.nvmax > s.nforce+len(cand) {
+		s.nvmax = s.nforce + len(cand)
+	}
+	if s.nbest <= 0 {
+		s.nbest = 1
+	}
+	s.first = m.nconst() + s.nforce
+	s.res = &SubsetSearch{
+		Wycol:  wycol,
+		Force:  DeepCopyInts(opt.Force),
+		BySize: make([][]*Subset, s.nvmax+1),
+	}
+
+	if !m.Tol_set {
+		m.Tolset(1e-12)
+	}
+	saved := DeepCopyInts(m.Vorder)
+	defer func() {
+		rerr := m.restoreOrder(saved)
+		if err == nil && rerr != nil {
+			res, err = nil, rerr
+		}
+	}()
+
+	// layout: [ constant | Force | Candidates | everything else ]
+	err = m.moveSubsetToFront(opt.Force)
+	if err != nil {
+		return nil, err
+	}
+	err = m.Reorder(cand, s.first)
+	if err != nil {
+		return nil, err
+	}
+	for k := range m.Rhs {
+		m.SS(k)
+	}
+
+	s.setScale()
+
+	// the forced-in variables alone
+	s.record(s.first - 1)
+
+	err = s.node(s.first, len(cand))
+	if err != nil {
+		return nil, err
+	}
+	return s.res, nil
+}
+
+// Best(): the subset that is best overall according to crit. Subsets
+// for which crit is NaN, such as Cp with no more observations than
+// columns, are left out; nil if that leaves none.
+func (r *SubsetSearch) Best(crit SelectionCriterion) *Subset {
+	var best *Subset
+	for _, list := range r.BySize {
+		if len(list) == 0 || math.IsNaN(list[0].score(crit)) {
+			continue
+		}
+		if best == nil || list[0].score(crit) < best.score(crit) {
+			best = list[0]
+		}
+	}
+	return best
+}
+
+// score: smaller is better, for every criterion.
+func (s *Subset) score(crit SelectionCriterion) float64 {
+	switch crit {
+	case CRIT_ADJR2:
+		return -s.AdjRsquared
+	case CRIT_CP:
+		return s.Cp
+	case CRIT_AIC:
+		return s.AIC
+	case CRIT_BIC:
+		return s.BIC
+	}
+	return s.Rss
+}
+
+func (r *SubsetSearch) String() string {
+	s := fmt.Sprintf("Best subsets for y-target %d (forced in: %v; %d subsets examined)\n", r.Wycol, r.Force, r.Visited)
+	s += fmt.Sprintf("%4s  %14s  %8s  %8s  %10s  %10s  %10s  %s\n", "size", "RSS", "R^2", "adj.R^2", "Cp", "AIC", "BIC", "variables")
+	for _, list := range r.BySize {
+		for _, b := range list {
+			s += fmt.Sprintf("%4d  %14.6g  %8.4f  %8.4f  %10.4g  %10.4g  %10.4g  %v\n", b.Size, b.Rss, b.Rsquared, b.AdjRsquared, b.Cp, b.AIC, b.BIC, b.Vars)
+		}
+	}
+	return s
+}
+
+type subsetSearcher struct {
+	m          *MillerLSQ
+	wycol      int
+	first      int // 0-based position of the first candidate in m.Vorder
+	nforce     int
+	nvmax      int
+	nbest      int
+	exhaustive bool
+	res        *SubsetSearch
+
+	// for the criteria
+	n      float64
+	tss    float64
+	sigma2 float64 // residual variance of the full model
+}
+
+// setScale(): n, the total sum of squares, and the residual variance of
+// the full model for Cp, which is NaN when there are no more
+// observations than columns, so that Cp is NaN too.
+func (s *subsetSearcher) setScale() {
+	m := s.m
+	s.n = m.NobsEffective()
+	s.tss = m.NullRss(s.wycol)
+	s.sigma2 = math.NaN()
+	if df := s.n - float64(m.Ncol); df > 0 {
+		s.sigma2 = m.Sserr[s.wycol] / df
+	}
+}
+
+// node(): the variables in positions [first, k) are in the current subset,
+// and the m variables in positions [k, k+m) are still allowed to join it.
+// Every subset formed by adding some of the allowed variables is visited,
+// unless the bounds show it cannot make any of the best lists.
+//
+// Children move the variables they exclude to the end of their own range,
+// so when a child returns, positions [k+1, k+m) again hold the same set
+// of variables, if perhaps in a different order.
+func (s *subsetSearcher) node(k int, m int) error {
+	rss := s.m.Rss[s.wycol]
+
+	for i := 0; i < m; i++ {
+		remaining := m - i // allowed variables, including the one now in position k
+		size := s.nforce + (k - s.first) + 1
+		if size > s.nvmax {
+			return nil
+		}
+
+		// adding every remaining variable gives the smallest RSS in this branch;
+		// later branches only have fewer variables to add, so they can't do better.
+		hi := size + remaining - 1
+		if hi > s.nvmax {
+			hi = s.nvmax
+		}
+		if !s.exhaustive && s.cannotImprove(rss[k+remaining-1], size, hi) {
+			return nil
+		}
+
+		s.record(k)
+
+		if remaining > 1 {
+			if size < s.nvmax {
+				err := s.node(k+1, remaining-1)
+				if err != nil {
+					return err
+				}
+			}
+			// exclude the variable in position k from the rest of this branch
+			err := s.m.Vmove(k+1, k+m) // Vmove is 1-based
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cannotImprove(): true if a subset with RSS of at least bound would
+// fail to make the best lists for every size in [lo, hi].
+func (s *subsetSearcher) cannotImprove(bound float64, lo int, hi int) bool {
+	// allow for rounding differences between orderings of the factorization
+	bound *= 1 - 1e-10
+	for size := lo; size <= hi; size++ {
+		list := s.res.BySize[size]
+		if len(list) < s.nbest || bound < list[len(list)-1].Rss {
+			return false
+		}
+	}
+	return true
+}
+
+// record(): the subset made of the x-variables in positions up to and
+// including last.
+func (s *subsetSearcher) record(last int) {
+	m := s.m
+	s.res.Visited++
+
+	c := m.nconst() // the constant, if any, is in position 0
+	size := last + 1 - c
+	r := m.rssFirst(s.wycol, last+1)
+
+	list := s.res.BySize[size]
+	if len(list) == s.nbest && r >= list[len(list)-1].Rss {
+		return
+	}
+
+	b := &Subset{
+		Vars: DeepCopyInts(m.Vorder[c : last+1]),
+		Size: size,
+		Rss:  r,
+	}
+	sort.Ints(b.Vars)
+
+	p := float64(size + c) // parameters, counting the intercept
+	b.Rsquared = 1 - r/s.tss
+	b.AdjRsquared = 1 - (1-b.Rsquared)*(s.n-float64(c))/(s.n-p)
+	b.Cp = r/s.sigma2 - (s.n - 2*p)
+	b.AIC = s.n*math.Log(r/s.n) + 2*p
+	b.BIC = s.n*math.Log(r/s.n) + math.Log(s.n)*p
+
+	// insert, keeping the list sorted by Rss
+	j := sort.Search(len(list), func(i int) bool { return list[i].Rss > r })
+	list = append(list, nil)
+	copy(list[j+1:], list[j:])
+	list[j] = b
+	if len(list) > s.nbest {
+		list = list[:s.nbest]
+	}
+	s.res.BySize[size] = list
+}
@@ -0,0 +1,144 @@
+package lsq
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+// the slow way: fit every subset of candidates (plus force) with FitSubset(),
+// and keep the lowest RSS for each size.
+func bruteForceBestRss(m *MillerLSQ, force []int, cand []int) []float64 {
+	nsize := len(force) + len(cand) + 1
+	best := make([]float64, nsize)
+	for i := range best {
+		best[i] = -1
+	}
+	for mask := 0; mask < 1<<uint(len(cand)); mask++ {
+		vars := DeepCopyInts(force)
+		for j := range cand {
+			if mask&(1<<uint(j)) != 0 {
+				vars = append(vars, cand[j])
+			}
+		}
+		fit, err := m.FitSubset(vars, 0)
+		if err != nil {
+			panic(err)
+		}
+		if best[len(vars)] < 0 || fit.Rss < best[len(vars)] {
+			best[len(vars)] = fit.Rss
+		}
+	}
+	return best
+}
+
+func TestBestSubsets(t *testing.T) {
+
+	nvar := 7
+	m := NewMillerLSQ(nvar, 1)
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+	for i := range df.Rows {
+		m.Includ(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+	}
+	origVorder := DeepCopyInts(m.Vorder)
+
+	all := Seq(nvar)
+	expected := bruteForceBestRss(m, []int{}, all)
+
+	bb, err := m.BestSubsets(0, SubsetOptions{})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("\n%s\n", bb)
+
+	ex, err := m.BestSubsets(0, SubsetOptions{Exhaustive: true})
+	if err != nil {
+		panic(err)
+	}
+
+	cv.Convey("Given the fuelcons.dat data, BestSubsets() should find the lowest RSS subset of each size", t, func() {
+		cv.So(len(bb.BySize), cv.ShouldEqual, nvar+1)
+		for size := 0; size <= nvar; size++ {
+			cv.So(len(bb.BySize[size]), cv.ShouldEqual, 1)
+			cv.So(bb.BySize[size][0].Rss, cv.ShouldAlmostEqual, expected[size], 1e-6)
+			cv.So(ex.BySize[size][0].Rss, cv.ShouldAlmostEqual, expected[size], 1e-6)
+			cv.So(IntSliceEqual(bb.BySize[size][0].Vars, ex.BySize[size][0].Vars), cv.ShouldBeTrue)
+		}
+		cv.So(bb.BySize[0][0].Rss, cv.ShouldAlmostEqual, 588366.4791666667, 1e-6)
+		cv.So(bb.BySize[nvar][0].Rss, cv.ShouldAlmostEqual, 150444.92571767746, 1e-6)
+		cv.So(IntSliceEqual(m.Vorder, origVorder), cv.ShouldBeTrue)
+	})
+
+	cv.Convey("The exhaustive search should visit every subset, and the bounds should save some of that work", t, func() {
+		cv.So(ex.Visited, cv.ShouldEqual, 1<<uint(nvar))
+		cv.So(bb.Visited, cv.ShouldBeLessThan, ex.Visited)
+	})
+
+	cv.Convey("The criteria should agree with FitSubset() on the subsets they report", t, func() {
+		b := bb.BySize[3][0]
+		fit, err := m.FitSubset(b.Vars, 0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(b.Rsquared, cv.ShouldAlmostEqual, fit.Rsquared, 1e-10)
+		cv.So(b.AdjRsquared, cv.ShouldAlmostEqual, fit.AdjRsquared, 1e-10)
+
+		// Cp of the full model is always p, the number of parameters.
+		full := bb.BySize[nvar][0]
+		cv.So(full.Cp, cv.ShouldAlmostEqual, float64(nvar+1), 1e-8)
+
+		cv.So(bb.Best(CRIT_RSS), cv.ShouldEqual, full)
+		for _, crit := range []SelectionCriterion{CRIT_ADJR2, CRIT_CP, CRIT_AIC, CRIT_BIC} {
+			best := bb.Best(crit)
+			for _, list := range bb.BySize {
+				cv.So(best.score(crit), cv.ShouldBeLessThan, list[0].score(crit)+1e-12)
+			}
+		}
+	})
+
+	cv.Convey("Nbest, Nvmax and Force should be honored", t, func() {
+		force := []int{7}
+		cand := []int{1, 2, 3, 4, 5, 6}
+		expForce := bruteForceBestRss(m, force, cand)
+
+		r, err := m.BestSubsets(0, SubsetOptions{Force: force, Nvmax: 4, Nbest: 3})
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(len(r.BySize), cv.ShouldEqual, 5)
+		cv.So(len(r.BySize[0]), cv.ShouldEqual, 0)
+		cv.So(len(r.BySize[1]), cv.ShouldEqual, 1) // just DLic
+		for size := 2; size <= 4; size++ {
+			list := r.BySize[size]
+			cv.So(len(list), cv.ShouldEqual, 3)
+			cv.So(list[0].Rss, cv.ShouldAlmostEqual, expForce[size], 1e-6)
+			for k, b := range list {
+				cv.So(b.Vars[len(b.Vars)-1], cv.ShouldEqual, 7)
+				if k > 0 {
+					cv.So(list[k-1].Rss, cv.ShouldBeLessThan, b.Rss)
+				}
+			}
+		}
+		cv.So(IntSliceEqual(m.Vorder, origVorder), cv.ShouldBeTrue)
+	})
+	cv.Convey("With no more observations than columns, Cp should be NaN and left out of the ranking", t, func() {
+		for _, nrow := range []int{6, 8} {
+			wide := NewMillerLSQ(nvar, 1)
+			for i := 0; i < nrow; i++ {
+				wide.Includ(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+			}
+			r, err := wide.BestSubsets(0, SubsetOptions{Nvmax: 3})
+			cv.So(err, cv.ShouldBeNil)
+			for _, list := range r.BySize {
+				for _, b := range list {
+					cv.So(math.IsNaN(b.Cp), cv.ShouldBeTrue)
+				}
+			}
+			cv.So(r.Best(CRIT_CP), cv.ShouldBeNil)
+			cv.So(r.Best(CRIT_BIC), cv.ShouldNotBeNil)
+		}
+	})
+}
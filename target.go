@@ -0,0 +1,176 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Per-target missingness.
+//
+// Each y-target's coefficients are R^-1 Rhs over the rows it was seen
+// in, so targets seen in different rows need different R. With
+// NAN_OMIT_TARGET (or MISSING_OMIT_TARGET on a y column), a row that
+// has every y-target goes into the shared factorization as usual,
+// while a row missing some of them goes, for each target it does have,
+// into a small single-target factorization of its own, m.Partial[j].
+// Complete rows, the common case, still cost one sweep.
+//
+// m by itself is then the fit to the complete rows. Target(j) puts the
+// two together, by including the rows sqrt(D) R of m.Partial[j] into a
+// copy of m's factorization for target j, giving the fit to every row
+// that had target j, with its own Nobs and residual degrees of freedom.
+
+// missingTargets(): true if some y-target of the row is missing, as only
+// MISSING_OMIT_TARGET leaves it after fillMissing().
+func missingTargets(y []float64) bool {
+	for _, v := range y {
+		if math.IsNaN(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// partial(): the factorization of the rows that had y-target j but
+// lacked some other, made on first use.
+func (m *MillerLSQ) partial(j int) *MillerLSQ {
+	if len(m.Partial) != m.Nyvar {
+		m.Partial = make([]*MillerLSQ, m.Nyvar)
+	}
+	if m.Partial[j] == nil {
+		p := NewMillerLSQIntercept(m.Nxvar, 1, !m.NoIntercept)
+		p.Vsmall = m.Vsmall
+		if m.UseMeanSd {
+			p.SetMeanSd(m.Xmean, m.Xsd, m.Ymean[j:j+1], m.Ysd[j:j+1])
+		}
+		m.Partial[j] = p
+	}
+	return m.Partial[j]
+}
+
+// includPartial(): add a row missing some of its y-targets to the
+// partial factorization of each target it has.
+func (m *MillerLSQ) includPartial(weight float64, xrow []float64, yrow []float64) {
+	for j, y := range yrow {
+		if math.IsNaN(y) {
+			continue
+		}
+		m.partial(j).Includ(weight, xrow, []float64{y}, NAN_OMIT_ROW)
+	}
+}
+
+// NobsTarget(): the number of observations of the wycol-th y-target,
+// counting the rows that lacked other targets.
+func (m *MillerLSQ) NobsTarget(wycol int) int64 {
+	n := m.Nobs
+	if wycol < len(m.Partial) && m.Partial[wycol] != nil {
+		n += m.Partial[wycol].Nobs
+	}
+	return n
+}
+
+// Target(): a model of the wycol-th y-target alone, fitted to every row
+// that had it, including those missing other y-targets. It is a new
+// MillerLSQ, with Nyvar 1, in m's variable order; m is not changed.
+func (m *MillerLSQ) Target(wycol int) (*MillerLSQ, error) {
+	if wycol < 0 || wycol >= m.Nyvar {
+		return nil, errors.New(fmt.Sprintf("Target() error: wycol(%d) out of range [0, %d)", wycol, m.Nyvar))
+	}
+	t := m.Clone()
+	t.Nyvar = 1
+	t.Rhs = t.Rhs[wycol : wycol+1]
+	t.Sserr = t.Sserr[wycol : wycol+1]
+	t.Sscp = []float64{t.Sserr[0]}
+	t.Rss = t.Rss[wycol : wycol+1]
+	t.Rss_set = []bool{false}
+	t.Ymean = t.Ymean[wycol : wycol+1]
+	t.Ysd = t.Ysd[wycol : wycol+1]
+	t.Curyrow = t.Curyrow[:1]
+	t.YStats = SdTracker{
+		Nc:   1,
+		W:    []float64{m.YStats.W[wycol]},
+		A:    []float64{m.YStats.A[wycol]},
+		Q:    []float64{m.YStats.Q[wycol]},
+		Nobs: m.YStats.Nobs,
+	}
+	if len(t.YPolicy) == m.Nyvar {
+		t.YPolicy = t.YPolicy[wycol : wycol+1]
+	}
+	if len(t.YMissing) == m.Nyvar {
+		t.YMissing = t.YMissing[wycol : wycol+1]
+	}
+	t.Partial = nil
+
+	if wycol < len(m.Partial) && m.Partial[wycol] != nil {
+		p := m.Partial[wycol]
+		t.absorb(p)
+		if t.Decayed || p.Decayed {
+			t.DecayedNobs = t.NobsEffective() + p.NobsEffective()
+			t.Decayed = true
+		}
+		t.Nobs += p.Nobs
+		t.AccumWeightSum += p.AccumWeightSum
+		t.XStats.Merge(&p.XStats)
+		t.YStats.Merge(&p.YStats)
+	}
+	return t, nil
+}
+
+// FitTarget(): Fit() of the wycol-th y-target, on every row that had it.
+func (m *MillerLSQ) FitTarget(wycol int) (*FitResult, error) {
+	t, err := m.Target(wycol)
+	if err != nil {
+		return nil, err
+	}
+	return t.Fit(0)
+}
+
+// absorb(): include the rows of p, a model of the same x-variables and
+// of m's one y-target, into m, as the pseudo-rows sqrt(D) R of p's
+// factorization, rearranged into m's variable order.
+func (m *MillerLSQ) absorb(p *MillerLSQ) {
+	// by variable: the constant, if any, then the x-variables
+	c := m.nconst()
+	xv := make([]float64, m.Ncol)
+	for i := 0; i < p.Ncol; i++ {
+		if p.D[i] == 0 {
+			continue
+		}
+		zero_out(xv)
+		xv[p.Vorder[i]+c-1] = 1
+		for k := i + 1; k < p.Ncol; k++ {
+			xv[p.Vorder[k]+c-1] = p.rAt(i, k)
+		}
+		for pos := 0; pos < m.Ncol; pos++ {
+			m.Curxrow[pos] = xv[m.Vorder[pos]+c-1]
+		}
+		m.Curyrow[0] = p.Rhs[0][i]
+		m.givens(p.D[i])
+	}
+	m.Sserr[0] += p.Sserr[0]
+	m.Sscp[0] += p.Sserr[0]
+	m.Tol_set = false
+}
+
+// Target(): as MillerLSQ.Target(), keeping the names.
+func (mod *Model) Target(wycol int) (*Model, error) {
+	t, err := mod.MillerLSQ.Target(wycol)
+	if err != nil {
+		return nil, err
+	}
+	res := &Model{MillerLSQ: t, Xnames: mod.Xnames}
+	if wycol < len(mod.Ynames) {
+		res.Ynames = []string{mod.Ynames[wycol]}
+	}
+	return res, nil
+}
+
+// FitTarget(): as MillerLSQ.FitTarget(), with the names filled in.
+func (mod *Model) FitTarget(wycol int) (*FitResult, error) {
+	t, err := mod.Target(wycol)
+	if err != nil {
+		return nil, err
+	}
+	return t.Fit(0)
+}
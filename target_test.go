@@ -0,0 +1,178 @@
+package lsq
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestOmitTarget(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+
+	// Fuel_Pop and FuelCon on Tax, Income, RoadMls, DLic, with some of
+	// each y-target missing, and one row missing both
+	xcols := []int{2, 4, 5, 7}
+	ycols := []int{8, 6}
+	n := len(df.Rows)
+	x := func(i int) []float64 {
+		r := make([]float64, len(xcols))
+		for k, j := range xcols {
+			r[k] = df.Rows[i][j]
+		}
+		return r
+	}
+	y := func(i int) []float64 {
+		r := []float64{df.Rows[i][ycols[0]], df.Rows[i][ycols[1]]}
+		if i%5 == 1 {
+			r[0] = math.NaN()
+		}
+		if i%7 == 3 {
+			r[1] = math.NaN()
+		}
+		return r
+	}
+	present := func(i int, j int) bool {
+		return !math.IsNaN(y(i)[j])
+	}
+
+	// the fit of target j on the rows in [from, to) that have it
+	separate := func(j int, from int, to int, intercept bool, complete bool) *FitResult {
+		s := NewMillerLSQIntercept(len(xcols), 1, intercept)
+		for i := from; i < to; i++ {
+			if !present(i, j) || (complete && !present(i, 1-j)) {
+				continue
+			}
+			s.Includ(1, x(i), y(i)[j:j+1], NAN_OMIT_ROW)
+		}
+		fit, err := s.Fit(0)
+		if err != nil {
+			panic(err)
+		}
+		return fit
+	}
+
+	// compare by variable, whatever the order of the coefficients
+	same := func(got *FitResult, want *FitResult) {
+		cv.So(got.Nobs, cv.ShouldEqual, want.Nobs)
+		cv.So(got.DfResid, cv.ShouldEqual, want.DfResid)
+		cv.So(got.Sigma, cv.ShouldAlmostEqual, want.Sigma, 1e-8*want.Sigma)
+		for a, v := range got.Vars {
+			for b, u := range want.Vars {
+				if u == v {
+					cv.So(got.Coef[a], cv.ShouldAlmostEqual, want.Coef[b], 1e-7*(math.Abs(want.Coef[b])+want.StdErr[b]))
+					cv.So(got.StdErr[a], cv.ShouldAlmostEqual, want.StdErr[b], 1e-7*want.StdErr[b])
+				}
+			}
+		}
+	}
+
+	fill := func(intercept bool, from int, to int) *MillerLSQ {
+		m := NewMillerLSQIntercept(len(xcols), 2, intercept)
+		for i := from; i < to; i++ {
+			m.Includ(1, x(i), y(i), NAN_OMIT_TARGET)
+		}
+		return m
+	}
+
+	for _, intercept := range []bool{true, false} {
+		cv.Convey("Given fuelcons.dat with y-targets missing here and there, NAN_OMIT_TARGET should fit each target on every row that has it", t, func() {
+			m := fill(intercept, 0, n)
+			m.Reorder([]int{3, 1}, 1)
+
+			complete, both := 0, 0
+			for i := 0; i < n; i++ {
+				switch {
+				case present(i, 0) && present(i, 1):
+					complete++
+				case !present(i, 0) && !present(i, 1):
+					both++
+				}
+			}
+			cv.So(both, cv.ShouldEqual, 1)
+			cv.So(m.Nobs, cv.ShouldEqual, complete)
+			cv.So(m.CountNaNRowsSkipped, cv.ShouldEqual, 1)
+			cv.So(m.RowsSeen, cv.ShouldEqual, n)
+
+			for j := 0; j < 2; j++ {
+				want := separate(j, 0, n, intercept, false)
+				cv.So(m.NobsTarget(j), cv.ShouldEqual, want.Nobs)
+				got, err := m.FitTarget(j)
+				cv.So(err, cv.ShouldBeNil)
+				same(got, want)
+
+				// m alone is the fit to the complete rows
+				cc, err := m.Fit(j)
+				cv.So(err, cv.ShouldBeNil)
+				same(cc, separate(j, 0, n, intercept, true))
+			}
+			// the row missing both is left out of both fits
+			cv.So(m.YMissing[0].Rejected+m.YMissing[1].Rejected, cv.ShouldEqual, n-complete+both)
+		})
+	}
+
+	cv.Convey("Target() should carry through Clone(), merging and Decay(), and name its y-target from a Model", t, func() {
+		m := fill(true, 0, n)
+		want := separate(1, 0, n, true, false)
+
+		c := m.Clone()
+		c.Includ(1, x(0), []float64{math.NaN(), 1e6}, NAN_OMIT_TARGET)
+		got, err := m.FitTarget(1)
+		cv.So(err, cv.ShouldBeNil)
+		same(got, want)
+
+		merged := LsqCombineAllRhs(fill(true, 0, 20), fill(true, 20, n))
+		got, err = merged.FitTarget(1)
+		cv.So(err, cv.ShouldBeNil)
+		same(got, want)
+
+		m.Decay(0.5)
+		got, err = m.FitTarget(1)
+		cv.So(err, cv.ShouldBeNil)
+		for k := range got.Coef {
+			cv.So(got.Coef[k], cv.ShouldAlmostEqual, want.Coef[k], 1e-7*(math.Abs(want.Coef[k])+want.StdErr[k]))
+		}
+
+		mod := &Model{MillerLSQ: fill(true, 0, n), Xnames: []string{"Tax", "Income", "RoadMls", "DLic"}, Ynames: []string{"Fuel_Pop", "FuelCon"}}
+		named, err := mod.FitTarget(1)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(named.Yname, cv.ShouldEqual, "FuelCon")
+		cv.So(named.Names[1], cv.ShouldEqual, "Tax")
+
+		_, err = m.Target(2)
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+
+	cv.Convey("A WindowedLSQ should downdate and rebuild rows missing a y-target the same way", t, func() {
+		w := NewWindowedLSQ(len(xcols), 2, 20, 0)
+		w.RebuildEvery = 7
+		start := time.Now()
+		for i := 0; i < n; i++ {
+			w.IncludAt(start.Add(time.Duration(i)*time.Second), 1, x(i), y(i), NAN_OMIT_TARGET)
+		}
+		for j := 0; j < 2; j++ {
+			got, err := w.FitTarget(j)
+			cv.So(err, cv.ShouldBeNil)
+			// the row missing both never entered the window
+			same(got, separate(j, n-21, n, true, false))
+		}
+	})
+
+	cv.Convey("Second passes over m should skip the rows missing a y-target, as m did", t, func() {
+		m := fill(true, 0, n)
+		m.NanApproach = NAN_OMIT_TARGET
+		cols := Columns{X: xcols, Y: ycols, Weight: NoWeight}
+		rows := make([][]float64, n)
+		for i := range rows {
+			rows[i] = append([]float64{}, df.Rows[i]...)
+			rows[i][ycols[0]], rows[i][ycols[1]] = y(i)[0], y(i)[1]
+		}
+		_, err := m.RobustFit(0, NewSliceSource(rows), cols, HC0)
+		cv.So(err, cv.ShouldBeNil)
+	})
+}
@@ -0,0 +1,162 @@
+package lsq
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// TextSource is a RowSource over whitespace-delimited text, one row per
+// line, from a file or any io.Reader. Each line is read and parsed with
+// LineToFloatSlice() as it is asked for, so only the current row is ever
+// in memory, however big the file. A field that is not a number, such
+// as a row name, becomes NaN, and blank lines are skipped.
+type TextSource struct {
+	// Colnames holds the fields of the header line, if there is one.
+	Colnames []string
+
+	header  bool
+	path    string
+	f       *os.File // when opened from path
+	r       io.Reader
+	br      *bufio.Reader
+	started bool
+	line    int64
+	err     error
+}
+
+// NewFileSource(): a TextSource over the file at path, with the first
+// line taken as a header if header is set. Close() it when done.
+func NewFileSource(path string, header bool) (*TextSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &TextSource{header: header, path: path, f: f, r: f}
+	s.br = bufio.NewReader(f)
+	return s, nil
+}
+
+// NewReaderSource(): a TextSource over r, with the first line taken as
+// a header if header is set. Reset() needs r to be an io.Seeker, as
+// files and strings.Readers are, once any row has been read.
+func NewReaderSource(r io.Reader, header bool) *TextSource {
+	return &TextSource{header: header, r: r, br: bufio.NewReader(r)}
+}
+
+// Next(): the next row, or nil at the end or on an error.
+func (s *TextSource) Next() []float64 {
+	if s.err != nil || s.br == nil {
+		return nil
+	}
+	for {
+		line, err := s.br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			s.err = errors.New(fmt.Sprintf("TextSource error after line %d: %s", s.line, err))
+			return nil
+		}
+		if err == io.EOF && line == "" {
+			return nil
+		}
+		s.line++
+		if s.header && !s.started {
+			s.started = true
+			s.Colnames = LineToStringSlice(line)
+			continue
+		}
+		s.started = true
+		if strings.TrimSpace(line) == "" {
+			if err == io.EOF {
+				return nil
+			}
+			continue
+		}
+		return LineToFloatSlice(line)
+	}
+}
+
+// Err(): the error that ended the rows, or nil.
+func (s *TextSource) Err() error {
+	return s.err
+}
+
+// Line(): the line number, from 1 and counting the header, of the row
+// last returned by Next().
+func (s *TextSource) Line() int64 {
+	return s.line
+}
+
+// Reset(): back to the first row.
+func (s *TextSource) Reset() error {
+	if !s.started && s.err == nil {
+		return nil
+	}
+	seeker, ok := s.r.(io.Seeker)
+	if !ok {
+		return errors.New("TextSource error: cannot Reset(), as the reader is not an io.Seeker")
+	}
+	_, err := seeker.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	s.br.Reset(s.r)
+	s.started = false
+	s.line = 0
+	s.err = nil
+	return nil
+}
+
+// Close(): close the file, if the source opened one.
+func (s *TextSource) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	s.br = nil
+	return err
+}
+
+// IncludSource(): Includ() every row of src, from its first, with x,
+// y and the weight taken from the columns cols, and NaNs handled as
+// m.NanApproach says. Rows are read one at a time, so memory stays
+// constant however many there are. Returns the number of rows included.
+func (m *MillerLSQ) IncludSource(src RowSource, cols Columns) (int64, error) {
+	if len(cols.X) != m.Nxvar || len(cols.Y) != m.Nyvar {
+		return 0, errors.New(fmt.Sprintf("IncludSource() error: %d x columns and %d y columns given, for a model of %d x-variables and %d y-targets", len(cols.X), len(cols.Y), m.Nxvar, m.Nyvar))
+	}
+	err := src.Reset()
+	if err != nil {
+		return 0, err
+	}
+	x := make([]float64, m.Nxvar)
+	y := make([]float64, m.Nyvar)
+	var n, row int64
+	for r := src.Next(); r != nil; r = src.Next() {
+		row++
+		weight, err := cols.split(r, x, y)
+		if err != nil {
+			return n, errors.New(fmt.Sprintf("IncludSource() error at row %d: %s", row, err))
+		}
+		if m.Includ(weight, x, y, m.NanApproach) {
+			n++
+		}
+	}
+	return n, src.Err()
+}
+
+// FitFromSource(): a new model, with an intercept, of the y-targets in
+// columns ycols on the x-variables in columns xcols, from every row of
+// src, weighted by column weightcol, or NoWeight. Columns are numbered
+// from 0. Rows with NaNs are omitted.
+func FitFromSource(src RowSource, xcols []int, ycols []int, weightcol int) (*MillerLSQ, error) {
+	m := NewMillerLSQ(len(xcols), len(ycols))
+	_, err := m.IncludSource(src, Columns{X: xcols, Y: ycols, Weight: weightcol})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
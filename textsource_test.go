@@ -0,0 +1,85 @@
+package lsq
+
+import (
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestTextSource(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+	xcols := []int{2, 4, 5, 7}
+
+	cv.Convey("Given fuelcons.dat, FitFromSource() over a file should fit what Includ() of the DataFrame's rows does", t, func() {
+		want := NewMillerLSQ(len(xcols), 1)
+		_, err := want.IncludSource(NewSliceSource(df.Rows), Columns{X: xcols, Y: []int{last}, Weight: 1})
+		cv.So(err, cv.ShouldBeNil)
+		wfit, err := want.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+
+		src, err := NewFileSource("fuelcons.dat", true)
+		cv.So(err, cv.ShouldBeNil)
+		defer src.Close()
+		m, err := FitFromSource(src, xcols, []int{last}, 1)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(src.Colnames[last], cv.ShouldEqual, "Fuel_Pop")
+		cv.So(m.Nobs, cv.ShouldEqual, len(df.Rows))
+		cv.So(src.Line(), cv.ShouldEqual, len(df.Rows)+1)
+		fit, err := m.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(EpsSliceEqual(fit.Coef, wfit.Coef, 1e-10), cv.ShouldBeTrue)
+
+		// and the file can be read again, for a second pass
+		cols := Columns{X: xcols, Y: []int{last}, Weight: 1}
+		rob, err := m.RobustFit(0, src, cols, HC3)
+		cv.So(err, cv.ShouldBeNil)
+		wrob, err := want.RobustFit(0, NewSliceSource(df.Rows), cols, HC3)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(EpsSliceEqual(rob.StdErr, wrob.StdErr, 1e-10), cv.ShouldBeTrue)
+	})
+
+	cv.Convey("A reader source should skip blank lines, keep a last line without a newline, and turn words into NaN", t, func() {
+		text := "a b c\n\n1 2 3\n  \nname 5 6\n7 8 9"
+		src := NewReaderSource(strings.NewReader(text), true)
+		var rows [][]float64
+		for r := src.Next(); r != nil; r = src.Next() {
+			rows = append(rows, r)
+		}
+		cv.So(src.Err(), cv.ShouldBeNil)
+		cv.So(src.Colnames, cv.ShouldResemble, []string{"a", "b", "c"})
+		cv.So(len(rows), cv.ShouldEqual, 3)
+		cv.So(math.IsNaN(rows[1][0]), cv.ShouldBeTrue)
+		cv.So(rows[2], cv.ShouldResemble, []float64{7, 8, 9})
+
+		cv.So(src.Reset(), cv.ShouldBeNil)
+		cv.So(src.Next(), cv.ShouldResemble, []float64{1, 2, 3})
+
+		// without a header, the first line is a row
+		plain := NewReaderSource(strings.NewReader("1 2\n3 4\n"), false)
+		cv.So(plain.Next(), cv.ShouldResemble, []float64{1, 2})
+	})
+
+	cv.Convey("A reader that cannot seek can be read once, and bad columns should be errors with the row", t, func() {
+		once := NewReaderSource(io.MultiReader(strings.NewReader("1 2\n2 3\n4 5\n")), false)
+		m, err := FitFromSource(once, []int{0}, []int{1}, NoWeight)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(m.Nobs, cv.ShouldEqual, 3)
+		cv.So(once.Reset(), cv.ShouldNotBeNil)
+
+		short := NewReaderSource(strings.NewReader("1 2 3\n4 5\n"), false)
+		_, err = FitFromSource(short, []int{0}, []int{2}, NoWeight)
+		cv.So(err, cv.ShouldNotBeNil)
+		cv.So(strings.Contains(err.Error(), "row 2"), cv.ShouldBeTrue)
+
+		_, err = NewFileSource("no-such-file.dat", true)
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,188 @@
+package lsq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// WaldTest is returned by TestLinearHypothesis(): the F test of the
+// hypothesis L b = c, for the coefficients b of the full model.
+type WaldTest struct {
+	Wycol int
+
+	Estimate []float64 // L b, one per row of L
+	C        []float64 // the hypothesized values of L b
+	StdErr   []float64 // standard errors of Estimate
+
+	Df      float64 // the number of rows of L
+	DfResid float64
+	F       float64 // (Lb - c)' (L V L')^-1 (Lb - c) / Df
+	Pvalue  float64
+}
+
+// TestLinearHypothesis(): the Wald test of L b = c, where b holds the
+// coefficients of the wycol-th y-target on the constant (if any) and
+// every x-variable, in the order of FitResult.Coef: the intercept
+// first, if there is one, then x-variables 1, 2, ... whatever the
+// current m.Vorder. Each row of L must have one entry per coefficient,
+// and the rows must be linearly independent. c may be nil for zeros.
+//
+// The covariance V of b is that of Cov(), so F has Df and DfResid
+// degrees of freedom, as R's car::linearHypothesis(fit, L, c) gives.
+// AllZeroHypothesis() and EqualHypothesis() build the common L and c.
+func (m *MillerLSQ) TestLinearHypothesis(L [][]float64, c []float64, wycol int) (*WaldTest, error) {
+	fit, err := m.Fit(wycol)
+	if err != nil {
+		return nil, err
+	}
+	w, err := fit.TestLinearHypothesis(L, c)
+	if err != nil {
+		return nil, err
+	}
+	w.Wycol = wycol
+	return w, nil
+}
+
+// TestLinearHypothesis(): as MillerLSQ.TestLinearHypothesis(), using
+// the coefficients and covariance matrix of r. For a fit from RobustFit()
+// this is the robust Wald test.
+func (r *FitResult) TestLinearHypothesis(L [][]float64, c []float64) (*WaldTest, error) {
+	q := len(L)
+	ncoef := len(r.Coef)
+	if q == 0 {
+		return nil, errors.New("TestLinearHypothesis() error: L has no rows")
+	}
+	for i, row := range L {
+		if len(row) != ncoef {
+			return nil, errors.New(fmt.Sprintf("TestLinearHypothesis() error: row %d of L has %d entries, but there are %d coefficients", i, len(row), ncoef))
+		}
+	}
+	if c == nil {
+		c = make([]float64, q)
+	}
+	if len(c) != q {
+		return nil, errors.New(fmt.Sprintf("TestLinearHypothesis() error: len(c)=%d but L has %d rows", len(c), q))
+	}
+
+	v := r.Vcov
+
+	res := &WaldTest{
+		Estimate: make([]float64, q),
+		C:        DeepCopy(c),
+		StdErr:   make([]float64, q),
+		Df:       float64(q),
+		DfResid:  r.DfResid,
+	}
+
+	// d = Lb - c, and the q x q covariance of Lb, L V L'
+	d := make([]float64, q)
+	lv := make([][]float64, q)
+	for i, row := range L {
+		for k, lik := range row {
+			res.Estimate[i] += lik * r.Coef[k]
+		}
+		d[i] = res.Estimate[i] - c[i]
+		lv[i] = make([]float64, ncoef)
+		for j := 0; j < ncoef; j++ {
+			for k, lik := range row {
+				lv[i][j] += lik * v.At(k, j)
+			}
+		}
+	}
+	lvl := NewSquareMatrix(q)
+	for i := 0; i < q; i++ {
+		for j := 0; j < q; j++ {
+			s := 0.0
+			for k, ljk := range L[j] {
+				s += lv[i][k] * ljk
+			}
+			lvl.Set(i, j, s)
+		}
+		res.StdErr[i] = math.Sqrt(lvl.At(i, i))
+	}
+
+	// with L V L' = G G', the quadratic form is |inv(G) d|^2
+	g, err, _ := lvl.FactorToCholeskyLower(CHOL_RETURN_FRESH_MATRIX)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("TestLinearHypothesis() error: L V L' is not positive definite: %s", err))
+	}
+	z := make([]float64, q)
+	quad := 0.0
+	for i := 0; i < q; i++ {
+		s := d[i]
+		for k := 0; k < i; k++ {
+			s -= g.At(i, k) * z[k]
+		}
+		// gii*gii is what is left of the variance of row i of Lb after the
+		// rows before it; relative to that variance, rounding alone leaves
+		// about 1e-16
+		gii := g.At(i, i)
+		if gii <= 1e-6*res.StdErr[i] {
+			return nil, errors.New(fmt.Sprintf("TestLinearHypothesis() error: the rows of L are linearly dependent, or test only aliased coefficients (at row %d)", i))
+		}
+		z[i] = s / gii
+		quad += z[i] * z[i]
+	}
+
+	res.F = quad / res.Df
+	res.Pvalue = Pf(res.F, res.Df, res.DfResid)
+	return res, nil
+}
+
+// AllZeroHypothesis(): L and c for TestLinearHypothesis() to test that
+// the coefficients of the variables listed are all zero. Variables are
+// numbered as in FitResult.Vars: 0 for the intercept, then 1, 2, ...
+// for the x-variables.
+func (m *MillerLSQ) AllZeroHypothesis(vars ...int) (L [][]float64, c []float64, err error) {
+	L = make([][]float64, len(vars))
+	for i, v := range vars {
+		k, err := m.coefIndex(v)
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("AllZeroHypothesis() error: %s", err))
+		}
+		L[i] = make([]float64, m.Nxvar+m.nconst())
+		L[i][k] = 1
+	}
+	return L, make([]float64, len(vars)), nil
+}
+
+// EqualHypothesis(): L and c for TestLinearHypothesis() to test that
+// the coefficients of variables v1 and v2, numbered as for
+// AllZeroHypothesis(), are equal.
+func (m *MillerLSQ) EqualHypothesis(v1 int, v2 int) (L [][]float64, c []float64, err error) {
+	if v1 == v2 {
+		return nil, nil, errors.New(fmt.Sprintf("EqualHypothesis() error: v1 and v2 are both %d", v1))
+	}
+	k1, err := m.coefIndex(v1)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("EqualHypothesis() error: %s", err))
+	}
+	k2, err := m.coefIndex(v2)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("EqualHypothesis() error: %s", err))
+	}
+	row := make([]float64, m.Nxvar+m.nconst())
+	row[k1] = 1
+	row[k2] = -1
+	return [][]float64{row}, []float64{0}, nil
+}
+
+// coefIndex(): the index in FitResult.Coef of variable v.
+func (m *MillerLSQ) coefIndex(v int) (int, error) {
+	lo := 1 - m.nconst() // 0, the intercept, only if there is one
+	if v < lo || v > m.Nxvar {
+		return -1, errors.New(fmt.Sprintf("variable %d is out of range [%d, %d]", v, lo, m.Nxvar))
+	}
+	return v - 1 + m.nconst(), nil
+}
+
+func (w *WaldTest) String() string {
+	s := fmt.Sprintf("Linear hypothesis test for y-target %d\n", w.Wycol)
+	s += fmt.Sprintf("%6s  %14s  %14s  %14s\n", "row", "Estimate", "Hypothesis", "Std. Error")
+	for i := range w.Estimate {
+		s += fmt.Sprintf("%6d  %14.6g  %14.6g  %14.6g\n", i, w.Estimate[i], w.C[i], w.StdErr[i])
+	}
+	s += fmt.Sprintf("F-statistic: %.4g on %.0f and %.0f DF,  p-value: %.4g\n", w.F, w.Df, w.DfResid, w.Pvalue)
+	return s
+}
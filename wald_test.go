@@ -0,0 +1,115 @@
+package lsq
+
+import (
+	"fmt"
+	"testing"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestLinearHypothesis(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+
+	// Fuel_Pop ~ Tax + Income + RoadMls + DLic
+	xcols := []int{2, 4, 5, 7}
+	m := NewMillerLSQ(len(xcols), 1)
+	xrow := make([]float64, len(xcols))
+	for i := range df.Rows {
+		for k, j := range xcols {
+			xrow[k] = df.Rows[i][j]
+		}
+		m.Includ(1.0, xrow, df.Rows[i][last:], NAN_OMIT_ROW)
+	}
+	fit, err := m.Fit(0)
+	if err != nil {
+		panic(err)
+	}
+
+	cv.Convey("Given the fuelcons.dat data, the Wald test that one coefficient is zero should be the square of its t test", t, func() {
+		for v := 0; v <= len(xcols); v++ {
+			L, c, err := m.AllZeroHypothesis(v)
+			cv.So(err, cv.ShouldBeNil)
+			w, err := m.TestLinearHypothesis(L, c, 0)
+			cv.So(err, cv.ShouldBeNil)
+			cv.So(w.Estimate[0], cv.ShouldAlmostEqual, fit.Coef[v], 1e-9)
+			cv.So(w.StdErr[0], cv.ShouldAlmostEqual, fit.StdErr[v], 1e-9)
+			cv.So(w.F, cv.ShouldAlmostEqual, fit.Tvalue[v]*fit.Tvalue[v], 1e-8)
+			cv.So(w.Pvalue, cv.ShouldAlmostEqual, fit.Pvalue[v], 1e-10)
+		}
+	})
+
+	cv.Convey("The Wald test that every slope is zero should be the overall F test", t, func() {
+		L, c, err := m.AllZeroHypothesis(1, 2, 3, 4)
+		cv.So(err, cv.ShouldBeNil)
+		w, err := m.TestLinearHypothesis(L, c, 0)
+		cv.So(err, cv.ShouldBeNil)
+		fmt.Printf("\n%s\n", w)
+		cv.So(w.Df, cv.ShouldEqual, 4)
+		cv.So(w.DfResid, cv.ShouldEqual, fit.DfResid)
+		cv.So(w.F, cv.ShouldAlmostEqual, fit.Fstat, 1e-8)
+		cv.So(w.Pvalue, cv.ShouldAlmostEqual, fit.Fpvalue, 1e-12)
+	})
+
+	cv.Convey("The Wald test that two coefficients are all zero should match NestedFTest()", t, func() {
+		L, c, err := m.AllZeroHypothesis(1, 3)
+		cv.So(err, cv.ShouldBeNil)
+		w, err := m.TestLinearHypothesis(L, c, 0)
+		cv.So(err, cv.ShouldBeNil)
+		ft, err := m.NestedFTest([]int{2, 4}, []int{1, 2, 3, 4}, 0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(w.F, cv.ShouldAlmostEqual, ft.F, 1e-8)
+	})
+
+	cv.Convey("The Wald test that two coefficients are equal should match the F test against the model that fits them as one", t, func() {
+		// under Tax == RoadMls, the model has the single column Tax + RoadMls
+		r := NewMillerLSQ(3, 1)
+		for i := range df.Rows {
+			row := df.Rows[i]
+			r.Includ(1.0, []float64{row[2] + row[5], row[4], row[7]}, row[last:], NAN_OMIT_ROW)
+		}
+		rfit, err := r.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		want := (rfit.Rss - fit.Rss) / (fit.Rss / fit.DfResid)
+
+		L, c, err := m.EqualHypothesis(1, 3)
+		cv.So(err, cv.ShouldBeNil)
+		w, err := m.TestLinearHypothesis(L, c, 0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(w.Estimate[0], cv.ShouldAlmostEqual, fit.Coef[1]-fit.Coef[3], 1e-9)
+		cv.So(w.F, cv.ShouldAlmostEqual, want, 1e-6)
+	})
+
+	cv.Convey("A non-zero c should shift the hypothesis", t, func() {
+		L, _, err := m.AllZeroHypothesis(4)
+		cv.So(err, cv.ShouldBeNil)
+		w, err := m.TestLinearHypothesis(L, []float64{fit.Coef[4]}, 0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(w.F, cv.ShouldAlmostEqual, 0, 1e-12)
+		cv.So(w.Pvalue, cv.ShouldAlmostEqual, 1, 1e-12)
+	})
+
+	cv.Convey("Badly shaped or dependent hypotheses should be errors", t, func() {
+		_, err := m.TestLinearHypothesis([][]float64{{1, 2}}, nil, 0)
+		cv.So(err, cv.ShouldNotBeNil)
+		L, _, err := m.AllZeroHypothesis(1, 2)
+		cv.So(err, cv.ShouldBeNil)
+		_, err = m.TestLinearHypothesis(L, []float64{0}, 0)
+		cv.So(err, cv.ShouldNotBeNil)
+		L, c, err := m.AllZeroHypothesis(2, 2)
+		cv.So(err, cv.ShouldBeNil)
+		_, err = m.TestLinearHypothesis(L, c, 0)
+		cv.So(err, cv.ShouldNotBeNil)
+
+		_, _, err = m.AllZeroHypothesis(1, 5)
+		cv.So(err, cv.ShouldNotBeNil)
+		_, _, err = m.EqualHypothesis(2, 2)
+		cv.So(err, cv.ShouldNotBeNil)
+		_, _, err = m.EqualHypothesis(-1, 2)
+		cv.So(err, cv.ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,210 @@
+package lsq
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// WindowedLSQ fits a regression to a sliding window over a stream: the
+// last MaxRows rows, or the rows from the last MaxAge, or whichever of
+// the two is smaller when both are set.
+//
+// The rows in the window are kept in a ring buffer. When a row falls out
+// of the window it is downdated from the factorization by including it
+// again with its weight negated, as the README describes. Downdating can
+// lose precision, so if D stops being positive (semi-)definite the
+// factorization is rebuilt from the rows still in the window.
+//
+// Everything else, Fit(), Regcf(), Cov() and so on, comes from the
+// embedded *MillerLSQ, and describes just the rows in the window. Its
+// ForgettingFactor should be left at zero: forgotten rows cannot be
+// downdated at their original weight.
+type WindowedLSQ struct {
+	*MillerLSQ
+
+	MaxRows int           // 0 means no limit on the number of rows
+	MaxAge  time.Duration // 0 means no limit on the age of rows
+
+	// RebuildEvery, if > 0, rebuilds the factorization from the window
+	// after that many evictions, whether or not precision has visibly
+	// been lost, to keep rounding error from accumulating.
+	RebuildEvery int64
+
+	Evictions int64 // rows removed from the window so far
+	Rebuilds  int64 // times the factorization has been rebuilt
+
+	buf   []windowRow // the ring buffer
+	head  int         // index in buf of the oldest row
+	count int         // rows in the window
+	since int64       // evictions since the last rebuild
+}
+
+type windowRow struct {
+	t time.Time
+	w float64
+	x []float64
+	y []float64
+}
+
+// NewWindowedLSQ(): the constructor. At least one of maxRows and maxAge
+// should be positive, or nothing will ever leave the window.
+func NewWindowedLSQ(nxvar int, nyvar int, maxRows int, maxAge time.Duration) *WindowedLSQ {
+	return NewWindowedLSQIntercept(nxvar, nyvar, maxRows, maxAge, AddIntercept)
+}
+
+// NewWindowedLSQIntercept(): as NewWindowedLSQ(), but with addIntercept
+// false (!AddIntercept) the model has no intercept; see NewMillerLSQIntercept().
+func NewWindowedLSQIntercept(nxvar int, nyvar int, maxRows int, maxAge time.Duration, addIntercept bool) *WindowedLSQ {
+	if maxRows < 0 || maxAge < 0 {
+		panic(fmt.Sprintf("NewWindowedLSQ() error: maxRows(%v) and maxAge(%v) cannot be negative", maxRows, maxAge))
+	}
+	w := &WindowedLSQ{
+		MillerLSQ: NewMillerLSQIntercept(nxvar, nyvar, addIntercept),
+		MaxRows:   maxRows,
+		MaxAge:    maxAge,
+	}
+	size := 16
+	if maxRows > 0 {
+		size = maxRows + 1
+	}
+	w.buf = make([]windowRow, size)
+	return w
+}
+
+// Includ(): add a row stamped with the current time, then evict whatever
+// has fallen out of the window. The arguments are as for MillerLSQ.Includ(),
+// except that weight must be positive.
+func (w *WindowedLSQ) Includ(weight float64, xrow []float64, yrow []float64, nanapproach NanHandling) (rowIncluded bool) {
+	return w.IncludAt(time.Now(), weight, xrow, yrow, nanapproach)
+}
+
+// IncludRecursive(): as Includ(), also giving the recursive residuals of
+// the row against the window before it; see MillerLSQ.IncludRecursive().
+func (w *WindowedLSQ) IncludRecursive(weight float64, xrow []float64, yrow []float64, nanapproach NanHandling, resid []float64) (rowIncluded bool) {
+	return w.includAt(time.Now(), weight, xrow, yrow, nanapproach, resid)
+}
+
+// IncludAt(): as Includ(), for a row observed at time t. Rows should
+// arrive in time order; the age of every row is measured from the newest.
+func (w *WindowedLSQ) IncludAt(t time.Time, weight float64, xrow []float64, yrow []float64, nanapproach NanHandling) (rowIncluded bool) {
+	return w.includAt(t, weight, xrow, yrow, nanapproach, nil)
+}
+
+func (w *WindowedLSQ) includAt(t time.Time, weight float64, xrow []float64, yrow []float64, nanapproach NanHandling, resid []float64) (rowIncluded bool) {
+	if weight < 0 {
+		panic(fmt.Sprintf("WindowedLSQ rows must have positive weight, not %v; rows leaving the window are removed automatically", weight))
+	}
+
+	// MillerLSQ.Includ() fills in missing values in place, so keep our own copy, and
+	// remember the row exactly as it was included.
+	r := windowRow{t: t, w: weight, x: DeepCopy(xrow), y: DeepCopy(yrow)}
+	rowIncluded = w.MillerLSQ.IncludRecursive(weight, r.x, r.y, nanapproach, resid)
+	if !rowIncluded || weight == 0 || math.Abs(weight) < w.Vsmall {
+		return rowIncluded
+	}
+	w.push(r)
+	w.Expire(t)
+	return rowIncluded
+}
+
+// Expire(): evict the rows that are too old as of time now, or beyond
+// MaxRows. IncludAt() calls this itself; call it directly to age out
+// rows when none are arriving.
+func (w *WindowedLSQ) Expire(now time.Time) {
+	for w.count > 0 {
+		oldest := &w.buf[w.head]
+		tooMany := w.MaxRows > 0 && w.count > w.MaxRows
+		tooOld := w.MaxAge > 0 && !oldest.t.After(now.Add(-w.MaxAge))
+		if !tooMany && !tooOld {
+			break
+		}
+		w.evict()
+	}
+}
+
+// Len(): the number of rows in the window.
+func (w *WindowedLSQ) Len() int {
+	return w.count
+}
+
+// Rebuild(): discard the factorization and include every row in the
+// window afresh. This happens by itself when downdating is seen to have
+// gone wrong, or every RebuildEvery evictions.
+func (w *WindowedLSQ) Rebuild() {
+	old := w.MillerLSQ
+	fresh := NewMillerLSQIntercept(old.Nxvar, old.Nyvar, !old.NoIntercept)
+	fresh.NanApproach = old.NanApproach
+	fresh.XPolicy = old.XPolicy
+	fresh.YPolicy = old.YPolicy
+	fresh.Vsmall = old.Vsmall
+	if old.UseMeanSd {
+		fresh.SetMeanSd(old.Xmean, old.Xsd, old.Ymean, old.Ysd)
+	}
+	for i := 0; i < w.count; i++ {
+		r := &w.buf[(w.head+i)%len(w.buf)]
+		// missing values, if any, were filled in when the row was first
+		// included, but for y-targets left out, which must stay out
+		fresh.Includ(r.w, r.x, r.y, NAN_OMIT_TARGET)
+	}
+	fresh.RowsSeen = old.RowsSeen
+	fresh.CountNaNRowsSkipped = old.CountNaNRowsSkipped
+	fresh.XMissing = old.XMissing
+	fresh.YMissing = old.YMissing
+
+	// update in place, so that anyone holding w.MillerLSQ sees the new state
+	*old = *fresh
+	w.Rebuilds++
+	w.since = 0
+}
+
+func (w *WindowedLSQ) push(r windowRow) {
+	if w.count == len(w.buf) {
+		// only a window bounded by age alone can run out of room
+		bigger := make([]windowRow, 2*len(w.buf))
+		for i := 0; i < w.count; i++ {
+			bigger[i] = w.buf[(w.head+i)%len(w.buf)]
+		}
+		w.buf = bigger
+		w.head = 0
+	}
+	w.buf[(w.head+w.count)%len(w.buf)] = r
+	w.count++
+}
+
+// evict(): downdate the oldest row, and drop it from the window.
+func (w *WindowedLSQ) evict() {
+	r := w.buf[w.head]
+	w.buf[w.head] = windowRow{}
+	w.head = (w.head + 1) % len(w.buf)
+	w.count--
+	w.Evictions++
+	w.since++
+
+	w.MillerLSQ.Includ(-r.w, r.x, r.y, NAN_OMIT_TARGET)
+	// Includ() counts rows it is given, even to remove them
+	w.RowsSeen--
+
+	// an empty window is rebuilt too, to start over cleanly rather than
+	// from whatever rounding error is left behind
+	if w.count == 0 || w.lostDefiniteness() || (w.RebuildEvery > 0 && w.since >= w.RebuildEvery) {
+		w.Rebuild()
+	}
+}
+
+// lostDefiniteness(): true if downdating has left the factorization in a
+// state that no set of rows could produce: a negative or non-finite
+// multiplier in D, or a negative residual sum of squares.
+func (m *MillerLSQ) lostDefiniteness() bool {
+	for _, d := range m.D {
+		if d < 0 || math.IsNaN(d) || math.IsInf(d, 0) {
+			return true
+		}
+	}
+	for _, e := range m.Sserr {
+		if e < 0 || math.IsNaN(e) || math.IsInf(e, 0) {
+			return true
+		}
+	}
+	return false
+}
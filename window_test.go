@@ -0,0 +1,114 @@
+package lsq
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	cv "github.com/glycerine/goconvey/convey"
+)
+
+func TestWindowedLSQ(t *testing.T) {
+
+	df, err := readData("fuelcons.dat")
+	if err != nil {
+		panic(err)
+	}
+	last := df.Ncol - 1
+	nvar := 7
+
+	// the gold standard: a fresh fit to rows [from, to)
+	fitRows := func(from int, to int) *FitResult {
+		m := NewMillerLSQ(nvar, 1)
+		for i := from; i < to; i++ {
+			m.Includ(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		fit, err := m.Fit(0)
+		if err != nil {
+			panic(err)
+		}
+		return fit
+	}
+
+	close := func(a, b []float64) bool {
+		for i := range a {
+			if math.Abs(a[i]-b[i]) > 1e-6*(1+math.Abs(b[i])) {
+				return false
+			}
+		}
+		return true
+	}
+
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cv.Convey("Given a WindowedLSQ keeping the last 20 rows, each fit should match a fresh fit to the last 20 rows", t, func() {
+		w := NewWindowedLSQ(nvar, 1, 20, 0)
+		for i := range df.Rows {
+			w.IncludAt(start.Add(time.Duration(i)*time.Second), 1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+			if i < 20 {
+				continue
+			}
+			cv.So(w.Len(), cv.ShouldEqual, 20)
+			cv.So(w.Nobs, cv.ShouldEqual, 20)
+
+			fit, err := w.Fit(0)
+			cv.So(err, cv.ShouldBeNil)
+			want := fitRows(i-19, i+1)
+			cv.So(close(fit.Coef, want.Coef), cv.ShouldBeTrue)
+			cv.So(fit.Rss, cv.ShouldAlmostEqual, want.Rss, 1e-6*want.Rss)
+		}
+		cv.So(w.Evictions, cv.ShouldEqual, len(df.Rows)-20)
+		cv.So(w.RowsSeen, cv.ShouldEqual, len(df.Rows))
+	})
+
+	cv.Convey("Given a WindowedLSQ keeping the last 15 seconds of rows, older rows should be evicted, and Expire() should age them out with no new rows", t, func() {
+		w := NewWindowedLSQ(nvar, 1, 0, 15*time.Second)
+		for i := range df.Rows {
+			w.IncludAt(start.Add(time.Duration(i)*time.Second), 1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		cv.So(w.Len(), cv.ShouldEqual, 15)
+		fit, err := w.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(close(fit.Coef, fitRows(len(df.Rows)-15, len(df.Rows)).Coef), cv.ShouldBeTrue)
+
+		w.Expire(start.Add(time.Duration(len(df.Rows)+5) * time.Second))
+		cv.So(w.Len(), cv.ShouldEqual, 9) // newer than 38 seconds: rows 39 through 47
+		w.Expire(start.Add(time.Hour))
+		cv.So(w.Len(), cv.ShouldEqual, 0)
+		cv.So(w.Nobs, cv.ShouldEqual, 0)
+	})
+
+	cv.Convey("When downdating leaves a negative multiplier in D, the factorization should be rebuilt from the window", t, func() {
+		w := NewWindowedLSQ(nvar, 1, 20, 0)
+		for i := 0; i < 20; i++ {
+			w.IncludAt(start, 1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW)
+		}
+		cv.So(w.Rebuilds, cv.ShouldEqual, 0)
+
+		// simulate the precision loss of a long run of downdates
+		w.D[3] = -w.D[3]
+		w.IncludAt(start, 1.0, df.Rows[20][1:last], df.Rows[20][last:], NAN_OMIT_ROW)
+
+		cv.So(w.Rebuilds, cv.ShouldEqual, 1)
+		fit, err := w.Fit(0)
+		cv.So(err, cv.ShouldBeNil)
+		cv.So(close(fit.Coef, fitRows(1, 21).Coef), cv.ShouldBeTrue)
+	})
+
+	cv.Convey("IncludRecursive() should keep the window, and give each row's recursive residual against the window before it", t, func() {
+		w := NewWindowedLSQ(nvar, 1, 20, 0)
+		resid := make([]float64, 1)
+		for i := 0; i < 30; i++ {
+			w.IncludRecursive(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW, resid)
+		}
+		cv.So(w.Len(), cv.ShouldEqual, 20)
+
+		fresh := NewMillerLSQ(nvar, 1)
+		want := make([]float64, 1)
+		for i := 10; i < 31; i++ {
+			fresh.IncludRecursive(1.0, df.Rows[i][1:last], df.Rows[i][last:], NAN_OMIT_ROW, want)
+		}
+		w.IncludRecursive(1.0, df.Rows[30][1:last], df.Rows[30][last:], NAN_OMIT_ROW, resid)
+		cv.So(resid[0], cv.ShouldAlmostEqual, want[0], 1e-6*math.Abs(want[0]))
+	})
+}